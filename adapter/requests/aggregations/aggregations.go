@@ -30,6 +30,14 @@ type Bucket interface {
 type MetricAggregationData struct {
 	AggName string
 	Value float64
+	// values of percentiles aggregation
+	Percentiles []PercentileValue
+}
+
+// PercentileValue contains value of the single percentile.
+type PercentileValue struct {
+	Percent float64
+	Value   float64
 }
 
 // BucketAggregationData contains aggregated bucketing data.
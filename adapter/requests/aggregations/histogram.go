@@ -21,13 +21,16 @@ const (
 
 	preparedDataPeriod = int64(time.Minute * 5)
 
+	// maxHistogramBuckets limits number of empty buckets added to histogram
+	maxHistogramBuckets = 10000
+
 	DataHistogramAggType = "DateHistogram"
 )
 
 // CreateDateHistogramAgg returns new histogram aggregation struct
 func CreateDateHistogramAgg(
 	interval string,
-	fieldName string,
+	field models.CHField,
 	dataRange *queries.RangeClause,
 	optimization bool,
 ) (*DateHistogram, error) {
@@ -35,15 +38,16 @@ func CreateDateHistogramAgg(
 	if err != nil {
 		return nil, err
 	}
-	if dataRange == nil || dataRange.GetField() != fieldName {
+	if dataRange == nil || dataRange.GetField() != field.CHName {
 		return nil, errors.New("data range for histogram is not set")
 	}
 
 	histogram := &DateHistogram{
 		baseAggregation:  createBaseAggregation(),
-		fieldName:        fieldName,
+		fieldName:        field.TimeNanosExpression(),
 		interval:         intervalSettings.calcInterval(),
 		timeOptimization: optimization,
+		minDocCount:      1,
 	}
 
 	return histogram, nil
@@ -52,13 +56,50 @@ func CreateDateHistogramAgg(
 type DateHistogram struct {
 	baseAggregation
 	filters          *Filters
+	metrics          []*Metric
 	interval         int64
-	fieldName        string
+	fieldName        string // expression converting field values to nanoseconds
 	timeOptimization bool
+	minDocCount      uint64
+	bounds           *histogramBounds
+	keyFormat        string
+}
+
+// histogramBounds contains time range in nanoseconds covered by histogram buckets regardless of data.
+type histogramBounds struct {
+	min int64
+	max int64
+}
+
+// SetMinDocCount sets minimum number of documents in returned buckets, empty buckets are returned if it is zero.
+func (hs *DateHistogram) SetMinDocCount(count uint64) {
+	hs.minDocCount = count
+}
+
+// SetExtendedBounds sets time range in nanoseconds filled by empty buckets (min_doc_count 0 is required).
+func (hs *DateHistogram) SetExtendedBounds(min int64, max int64) {
+	hs.bounds = &histogramBounds{min: min, max: max}
+}
+
+// SetFormat sets format of buckets keys strings, "epoch_millis" is supported, other formats are ignored.
+func (hs *DateHistogram) SetFormat(format string) {
+	hs.keyFormat = format
 }
 
 func (hs *DateHistogram) optimizationRequired() bool {
-	return hs.timeOptimization && hs.interval >= preparedDataPeriod
+	return hs.timeOptimization && hs.interval >= preparedDataPeriod && len(hs.metrics) == 0
+}
+
+// usesPreparedData checks that histogram is calculated from prepared data with counts of log entries
+// per time period instead of logs table.
+func (hs *DateHistogram) usesPreparedData() bool {
+	return len(queries.GetSimpleClausesList(hs.commonFilter)) == 1 && hs.optimizationRequired()
+}
+
+// CountsAllDocuments checks that histogram buckets contain all documents matched by the common filter,
+// so sum of their doc counts is the exact number of found documents.
+func (hs *DateHistogram) CountsAllDocuments() bool {
+	return hs.minDocCount <= 1 && !hs.usesPreparedData()
 }
 
 func (hs *DateHistogram) aggType() string {
@@ -73,28 +114,22 @@ func (hs *DateHistogram) SetSubAgg(agg Aggregation) error {
 	case *Filters:
 		hs.filters = a
 		return nil
+	case *Metric:
+		hs.metrics = append(hs.metrics, a)
+		return nil
 	default:
 		return errors.New("unsupported sub aggregation type: " + agg.aggType())
 	}
 }
 
 func (hs *DateHistogram) Aggregate(conn db.DataProvider) (*BucketAggregationData, error) {
-	index := conn.DataTable()
-	if index == "" {
+	if conn.DataTable() == "" {
 		return nil, errors.New("index pattern is not set for data provider")
 	}
 
-	histogram := &BucketAggregationData {
-		AggName: hs.name,
-		Buckets: bucketsStringer{
-			Buckets: make([]Bucket, 0),
-			stringer: bucketsToArrayJSON,
-		},
-	}
-
-	request := hs.createDataAggregatingRequest(index)
+	request := hs.createDataAggregatingRequest(conn)
 	if request == nil {
-		return histogram, nil
+		return hs.createBuckets(nil), nil
 	}
 
 	query := request.Build()
@@ -105,38 +140,95 @@ func (hs *DateHistogram) Aggregate(conn db.DataProvider) (*BucketAggregationData
 		return nil, err
 	}
 
-	for {
-		rawBucketData, ok := histogramBuckets.fetch()
+	return hs.createBuckets(histogramBuckets.BucketsData), nil
+}
+
+// createBuckets converts selected rows to histogram buckets, empty buckets are added
+// between extended bounds and selected data if min_doc_count is zero.
+func (hs *DateHistogram) createBuckets(rows []histogramCounts) *BucketAggregationData {
+	histogram := &BucketAggregationData{
+		AggName: hs.name,
+		Buckets: bucketsStringer{
+			Buckets:  make([]Bucket, 0, len(rows)),
+			stringer: bucketsToArrayJSON,
+		},
+	}
+
+	// empty buckets are not added if interval is too small for the requested time range
+	first, last, ok := hs.keysRange(rows)
+	if hs.minDocCount > 0 || !ok || last-first >= maxHistogramBuckets {
+		for _, row := range rows {
+			if counts := row.Vals; len(counts) > 0 && counts[len(counts)-1] >= hs.minDocCount {
+				histogram.Buckets.Buckets = append(histogram.Buckets.Buckets, hs.createColumn(row))
+			}
+		}
+		return histogram
+	}
+
+	columns := make(map[int64]histogramCounts, len(rows))
+	for _, row := range rows {
+		columns[row.Key] = row
+	}
+	for key := first; key <= last; key++ {
+		row, ok := columns[key]
 		if !ok {
-			break
+			row = histogramCounts{Key: key}
 		}
+		histogram.Buckets.Buckets = append(histogram.Buckets.Buckets, hs.createColumn(row))
+	}
+	return histogram
+}
 
-		column := column{
-			bucket: bucket {
-				key: time.Unix(0, hs.interval*rawBucketData.Key).In(time.Local),
-			},
+// keysRange returns keys of the first and the last histogram buckets including empty ones,
+// rows are expected to be sorted by keys.
+func (hs *DateHistogram) keysRange(rows []histogramCounts) (int64, int64, bool) {
+	var first, last int64
+	ok := len(rows) > 0
+	if ok {
+		first, last = rows[0].Key, rows[len(rows)-1].Key
+	}
+	if hs.bounds != nil && hs.bounds.min <= hs.bounds.max {
+		min, max := hs.bounds.min/hs.interval, hs.bounds.max/hs.interval
+		if !ok || min < first {
+			first = min
 		}
+		if !ok || max > last {
+			last = max
+		}
+		ok = true
+	}
+	return first, last, ok
+}
+
+// createColumn creates histogram bucket from selected row, rows without values are used for empty buckets.
+func (hs *DateHistogram) createColumn(row histogramCounts) *column {
+	column := &column{
+		bucket: bucket{
+			key: time.Unix(0, hs.interval*row.Key).In(time.Local),
+		},
+		keyFormat: hs.keyFormat,
+	}
 
-		counts := rawBucketData.Vals
+	counts := row.Vals
+	if len(counts) > 0 {
+		column.docCount = counts[len(counts)-1]
+	}
 
-		if column.docCount = counts[len(counts)-1]; column.docCount > 0 {
-			var subAggBuckets BucketAggregationData
-			if hs.filters != nil {
-				subAggBuckets = hs.filters.createBuckets()
-			}
+	var subAggBuckets BucketAggregationData
+	if hs.filters != nil {
+		subAggBuckets = hs.filters.createBuckets()
+	}
 
-			if subAggBuckets.Buckets.Buckets != nil {
-				for j := range subAggBuckets.Buckets.Buckets {
-					subAggBuckets.Buckets.Buckets[j].SetDocCount(counts[j])
-				}
+	if subAggBuckets.Buckets.Buckets != nil {
+		for j := range subAggBuckets.Buckets.Buckets {
+			if j < len(counts) {
+				subAggBuckets.Buckets.Buckets[j].SetDocCount(counts[j])
 			}
-
-			column.subAggData = subAggBuckets
-			histogram.Buckets.Buckets = append(histogram.Buckets.Buckets, &column)
 		}
 	}
 
-	return histogram, nil
+	column.subAggData = append(subAggregations{subAggBuckets}, metricsResults(hs.metrics, row.Metrics)...)
+	return column
 }
 
 func (hs *DateHistogram) createAggFuncs() aggFuncs {
@@ -146,13 +238,16 @@ func (hs *DateHistogram) createAggFuncs() aggFuncs {
 	return aggFuncs{clickhouse.NewCountAggregation("")}
 }
 
-func (hs *DateHistogram) createDataAggregatingRequest(index string) *db.Request {
-	filterConditions := queries.GetSimpleClausesList(hs.commonFilter)
+// keyExpression returns clickhouse expression of histogram bucket key.
+func (hs *DateHistogram) keyExpression() string {
+	return fmt.Sprintf("toInt64((%s) / %d) as cur_key", hs.fieldName, hs.interval)
+}
 
+func (hs *DateHistogram) createDataAggregatingRequest(conn db.DataProvider) *db.Request {
 	// histogram calc optimization performs only for log entries count visualization (discover) without any additional filters.
 	var request *db.Request
-	if len(filterConditions) == 1 && hs.optimizationRequired() {
-		request = clickhouse.NewRequestTpl(models.PreparedHistogramDataTablePrefix + index)
+	if hs.usesPreparedData() {
+		request = clickhouse.NewPreparedHistogramRequestTpl(conn)
 		request.What(
 			fmt.Sprintf(
 				"%s, toInt64(key / %d) as cur_key",
@@ -160,25 +255,32 @@ func (hs *DateHistogram) createDataAggregatingRequest(index string) *db.Request
 				hs.interval/preparedDataPeriod,
 			),
 		)
-		timeRange := queries.NewRange(fmt.Sprintf("(key * %d)", preparedDataPeriod), false)
-		if origRange, ok := filterConditions[0].(*queries.RangeClause); ok {
+		timeRange := queries.NewTimeRange(models.CHField{
+			CHName:   fmt.Sprintf("(key * %d)", preparedDataPeriod),
+			CHType:   "UInt64",
+			TimeUnit: models.Nanoseconds,
+		}, false)
+		if origRange, ok := queries.GetSimpleClausesList(hs.commonFilter)[0].(*queries.RangeClause); ok {
 			// exclude upper bound value from interval, because key from prepared data contains interval lower bounds
-			upperBound, _ := origRange.GetUpper()
-			timeRange.AddUpper(upperBound, true)
-			timeRange.AddLower(origRange.GetLower())
+			upperBound, _ := origRange.GetTimeUpper()
+			timeRange.AddTimeUpper(upperBound, true)
+			timeRange.AddTimeLower(origRange.GetTimeLower())
 		}
 		hs.commonFilter = timeRange
 		print("\n Aggregation optimized ")
 	} else {
-		request = clickhouse.NewRequestTpl(index)
+		request = clickhouse.NewRequestTpl(conn)
 		request.What(hs.createAggFuncs().build())
-		request.AppendToWhat(fmt.Sprintf("toInt64((%s) / %d) as cur_key", hs.fieldName, hs.interval))
+		request.AppendToWhat(hs.keyExpression())
+		request.AppendToWhat(metricsExpression(hs.metrics))
 	}
 
 	request.GroupBy("cur_key")
 	request.OrderBy(queries.NewSortSection(map[string]queries.Order{"cur_key": queries.Asc}).String())
 
-	request.WhereAnd(hs.commonFilter.String())
+	if hs.commonFilter != nil {
+		request.WhereAnd(hs.commonFilter.String())
+	}
 
 	return request
 }
@@ -192,8 +294,20 @@ func (i *histogramInterval) calcInterval() int64 {
 	return i.timeUnit.Nanoseconds() * i.timeVal
 }
 
+// calendarIntervals are units of calendar_interval supported as fixed intervals.
+var calendarIntervals = map[string]string{
+	"second": "1s",
+	"minute": "1m",
+	"hour":   "1h",
+	"day":    "1d",
+	"week":   "1w",
+}
+
 func parseHistogramInterval(interval string) (histogramInterval, error) {
 	histogramInterval := histogramInterval{}
+	if fixed, ok := calendarIntervals[interval]; ok {
+		interval = fixed
+	}
 	for _, item := range []struct {
 		name     string
 		duration time.Duration
@@ -224,8 +338,11 @@ func parseHistogramInterval(interval string) (histogramInterval, error) {
 }
 
 type histogramCounts struct {
-	Vals []uint64 `db:"results"`
-	Key  int64    `db:"cur_key"`
+	Vals    []uint64  `db:"results"`
+	Key     int64     `db:"cur_key"`
+	Metrics []float64 `db:"metrics"`
+	// values of parent terms aggregations fields
+	Keys []string `db:"keys"`
 }
 
 
@@ -263,6 +380,7 @@ func (mac *HistogramRawBuckets) reset() {
 
 type column struct {
 	bucket
+	keyFormat string
 }
 
 func (c column) String() string {
@@ -270,10 +388,15 @@ func (c column) String() string {
 	// kibana requires integer representation of time in milliseconds
 	milliseconds := intervalTime.UnixNano() / int64(time.Millisecond)
 
+	keyString := intervalTime.Format(time.UnixDate)
+	if c.keyFormat == "epoch_millis" {
+		keyString = strconv.FormatInt(milliseconds, 10)
+	}
+
 	return fmt.Sprintf(
 		`{%s, "key_as_string":"%s","key":%d}`,
 		c.bucket.String(),
-		intervalTime.Format(time.UnixDate),
+		keyString,
 		milliseconds,
 	)
 }
\ No newline at end of file
@@ -0,0 +1,170 @@
+package aggregations
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+const MetricAggType = "Metric"
+
+// Supported kinds of metric aggregations.
+const (
+	AvgMetric         = "avg"
+	SumMetric         = "sum"
+	MinMetric         = "min"
+	MaxMetric         = "max"
+	ValueCountMetric  = "value_count"
+	CardinalityMetric = "cardinality"
+	PercentilesMetric = "percentiles"
+)
+
+// defaultPercents are percents calculated by percentiles aggregation if they are not set in request.
+var defaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+
+// Metric calculates single value (or several percentiles) of the field for every bucket of parent aggregation.
+type Metric struct {
+	baseAggregation
+	kind     string
+	field    string // clickhouse expression with field values
+	percents []float64
+}
+
+// CreateMetricAgg returns new metric aggregation of the field.
+func CreateMetricAgg(kind string, field models.CHField, percents []float64) (*Metric, error) {
+	switch kind {
+	case AvgMetric, SumMetric, MinMetric, MaxMetric:
+		if !field.IsNumeric() || field.IsArray() {
+			return nil, errors.New(kind + " aggregation field " + field.CHName + " is not numeric")
+		}
+	case ValueCountMetric, CardinalityMetric:
+	case PercentilesMetric:
+		if !field.IsNumeric() || field.IsArray() {
+			return nil, errors.New(kind + " aggregation field " + field.CHName + " is not numeric")
+		}
+		if len(percents) == 0 {
+			percents = defaultPercents
+		}
+	default:
+		return nil, errors.New("unsupported metric aggregation type: " + kind)
+	}
+
+	return &Metric{
+		baseAggregation: createBaseAggregation(),
+		kind:            kind,
+		field:           field.CHName,
+		percents:        percents,
+	}, nil
+}
+
+func (m *Metric) aggType() string {
+	return MetricAggType
+}
+
+func (m *Metric) SetSubAgg(agg Aggregation) error {
+	if agg == nil {
+		return nil
+	}
+	return errors.New("unsupported sub aggregation type: " + agg.aggType())
+}
+
+func (m *Metric) Aggregate(conn db.DataProvider) (*BucketAggregationData, error) {
+	return nil, errors.New("metric aggregation " + m.name + " should be nested into bucket aggregation")
+}
+
+// expressions returns clickhouse aggregate functions calculating metric values,
+// all values are converted to Float64 for selecting them as single array.
+func (m *Metric) expressions() []string {
+	switch m.kind {
+	case ValueCountMetric:
+		return []string{fmt.Sprintf("toFloat64(count(%s))", m.field)}
+	case CardinalityMetric:
+		return []string{fmt.Sprintf("toFloat64(uniq(%s))", m.field)}
+	case PercentilesMetric:
+		exprs := make([]string, len(m.percents))
+		for i, percent := range m.percents {
+			exprs[i] = fmt.Sprintf("toFloat64(quantile(%s)(%s))", strconv.FormatFloat(percent/100, 'f', -1, 64), m.field)
+		}
+		return exprs
+	}
+	return []string{fmt.Sprintf("toFloat64(%s(%s))", m.kind, m.field)}
+}
+
+// emptyValue returns metric value of the empty bucket.
+func (m *Metric) emptyValue() float64 {
+	switch m.kind {
+	case SumMetric, ValueCountMetric, CardinalityMetric:
+		return 0
+	}
+	return math.NaN()
+}
+
+// createResult converts selected values to metric aggregation data.
+func (m *Metric) createResult(values []float64) MetricAggregationData {
+	result := MetricAggregationData{AggName: m.name}
+	if m.kind != PercentilesMetric {
+		if len(values) > 0 {
+			result.Value = values[0]
+		}
+		return result
+	}
+	result.Percentiles = make([]PercentileValue, len(m.percents))
+	for i, percent := range m.percents {
+		result.Percentiles[i] = PercentileValue{Percent: percent, Value: math.NaN()}
+		if i < len(values) {
+			result.Percentiles[i].Value = values[i]
+		}
+	}
+	return result
+}
+
+// metricsExpression returns clickhouse expression selecting values of all metrics as single array.
+func metricsExpression(metrics []*Metric) string {
+	if len(metrics) == 0 {
+		return ""
+	}
+	exprs := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		exprs = append(exprs, metric.expressions()...)
+	}
+	return fmt.Sprintf("[ %s ] as metrics", strings.Join(exprs, ","))
+}
+
+// metricPosition returns position (starting from 1) of the metric value in array selected by metricsExpression.
+func metricPosition(metrics []*Metric, name string) (int, bool) {
+	pos := 1
+	for _, metric := range metrics {
+		if metric.name == name {
+			return pos, metric.kind != PercentilesMetric
+		}
+		pos += len(metric.expressions())
+	}
+	return 0, false
+}
+
+// metricsResults splits array selected by metricsExpression to results of every metric,
+// values of empty buckets are used if array is not set.
+func metricsResults(metrics []*Metric, values []float64) []fmt.Stringer {
+	results := make([]fmt.Stringer, len(metrics))
+	pos := 0
+	for i, metric := range metrics {
+		count := len(metric.expressions())
+		metricValues := make([]float64, count)
+		for j := range metricValues {
+			if pos+j < len(values) {
+				metricValues[j] = values[pos+j]
+			} else {
+				metricValues[j] = metric.emptyValue()
+			}
+		}
+		results[i] = metric.createResult(metricValues)
+		pos += count
+	}
+	return results
+}
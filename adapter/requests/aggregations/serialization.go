@@ -2,6 +2,8 @@ package aggregations
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -73,11 +75,50 @@ func bucketsToArrayJSON(buckets []Bucket) string {
 	return fmt.Sprintf("[%s]", strings.Join(strBuckets, ","))
 }
 
+// subAggregations joins results of several sub aggregations of the bucket.
+type subAggregations []fmt.Stringer
+
+func (sa subAggregations) String() string {
+	results := make([]string, 0, len(sa))
+	for _, agg := range sa {
+		if agg == nil {
+			continue
+		}
+		if result := agg.String(); result != "" {
+			results = append(results, result)
+		}
+	}
+	return strings.Join(results, ",")
+}
+
+// metricValueJSON returns JSON representation of metric value, values of empty buckets (NaN) are null.
+func metricValueJSON(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "null"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// percentKey returns key of percentile value, elastic formats percents with fractional part (e.g. "99.0").
+func percentKey(percent float64) string {
+	if percent == math.Trunc(percent) {
+		return strconv.FormatFloat(percent, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(percent, 'f', -1, 64)
+}
+
 func (mad MetricAggregationData) String() string {
 	if mad.AggName == "" {
 		return ""
 	}
-	return fmt.Sprintf(`"%s":{value:%f}`, mad.AggName, mad.Value)
+	if mad.Percentiles != nil {
+		values := make([]string, len(mad.Percentiles))
+		for i, percentile := range mad.Percentiles {
+			values[i] = fmt.Sprintf(`"%s":%s`, percentKey(percentile.Percent), metricValueJSON(percentile.Value))
+		}
+		return fmt.Sprintf(`"%s":{"values":{%s}}`, mad.AggName, strings.Join(values, ","))
+	}
+	return fmt.Sprintf(`"%s":{"value":%s}`, mad.AggName, metricValueJSON(mad.Value))
 }
 
 func (mad MetricAggregationData) MarshalJSON() ([]byte, error) {
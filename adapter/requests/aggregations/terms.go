@@ -0,0 +1,331 @@
+package aggregations
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"kibouse/clickhouse"
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+const TermsAggType = "Terms"
+
+// Orderings of terms buckets, buckets could also be ordered by values of metric sub aggregation.
+const (
+	OrderByCount = "_count"
+	OrderByKey   = "_key"
+)
+
+// keysSeparator joins values of terms fields of nested buckets.
+const keysSeparator = "\x00"
+
+// TermsSettings contains parameters of terms aggregation buckets.
+type TermsSettings struct {
+	// maximum number of buckets (for every parent bucket), all terms are returned if it is zero
+	Size int
+	// _count, _key or name of metric sub aggregation
+	Order       string
+	Desc        bool
+	MinDocCount uint64
+}
+
+// Terms groups documents by values of the field. Buckets of nested terms and date histogram
+// aggregations are calculated only for the top terms of every level.
+type Terms struct {
+	baseAggregation
+	field     models.CHField
+	settings  TermsSettings
+	metrics   []*Metric
+	terms     *Terms
+	histogram *DateHistogram
+}
+
+// CreateTermsAgg returns new terms aggregation of the field.
+func CreateTermsAgg(field models.CHField, settings TermsSettings) (*Terms, error) {
+	if field.IsArray() {
+		return nil, errors.New("terms aggregation of array field " + field.CHName + " is not supported")
+	}
+	// terms missing in documents could not be listed
+	if settings.MinDocCount == 0 {
+		settings.MinDocCount = 1
+	}
+	if settings.Order == "" {
+		settings.Order, settings.Desc = OrderByCount, true
+	}
+
+	return &Terms{
+		baseAggregation: createBaseAggregation(),
+		field:           field,
+		settings:        settings,
+	}, nil
+}
+
+func (t *Terms) aggType() string {
+	return TermsAggType
+}
+
+func (t *Terms) SetSubAgg(agg Aggregation) error {
+	if agg == nil {
+		return nil
+	}
+	switch a := agg.(type) {
+	case *Metric:
+		t.metrics = append(t.metrics, a)
+		return nil
+	case *Terms, *DateHistogram:
+		if t.terms != nil || t.histogram != nil {
+			return errors.New("more than one bucket sub aggregation of terms aggregation " + t.name)
+		}
+		if terms, ok := a.(*Terms); ok {
+			t.terms = terms
+		} else {
+			t.histogram = a.(*DateHistogram)
+		}
+		return nil
+	default:
+		return errors.New("unsupported sub aggregation type: " + agg.aggType())
+	}
+}
+
+// termsCounts contains data of single terms bucket.
+type termsCounts struct {
+	Keys     []string  `db:"keys"`
+	DocCount uint64    `db:"doc_count"`
+	Metrics  []float64 `db:"metrics"`
+}
+
+// termsResults contains selected buckets of all nested terms aggregations grouped by parent buckets keys.
+type termsResults struct {
+	levels     []map[string][]termsCounts
+	histograms map[string][]histogramCounts
+}
+
+func (t *Terms) Aggregate(conn db.DataProvider) (*BucketAggregationData, error) {
+	if conn.DataTable() == "" {
+		return nil, errors.New("index pattern is not set for data provider")
+	}
+
+	levels := t.levels()
+	results := termsResults{
+		levels:     make([]map[string][]termsCounts, len(levels)),
+		histograms: make(map[string][]histogramCounts),
+	}
+
+	// terms of every level are selected for the top terms of parent level only
+	var parents [][]string
+	for i, level := range levels {
+		request := level.createTermsRequest(conn, levels[:i+1], parents)
+		log.Debugf("terms aggregation request: %s", request.Build())
+
+		rows := make([]termsCounts, 0)
+		if err := conn.CreateDataSelector(request)(&rows); err != nil {
+			return nil, errors.Wrap(err, "SQL failed to execute while aggregating terms: "+request.Build())
+		}
+
+		results.levels[i] = make(map[string][]termsCounts)
+		parents = make([][]string, len(rows))
+		for j, row := range rows {
+			parent := strings.Join(row.Keys[:i], keysSeparator)
+			results.levels[i][parent] = append(results.levels[i][parent], row)
+			parents[j] = row.Keys
+		}
+		if len(rows) == 0 {
+			break
+		}
+	}
+
+	leaf := levels[len(levels)-1]
+	if leaf.histogram != nil && len(parents) > 0 {
+		request := leaf.createHistogramRequest(conn, levels, parents)
+		log.Debugf("histogram request of terms buckets: %s", request.Build())
+
+		histogramBuckets, err := calcHistogram(conn.CreateDataSelector(request))
+		if err != nil {
+			return nil, errors.Wrap(err, "SQL failed to execute while aggregating histogram: "+request.Build())
+		}
+		for _, row := range histogramBuckets.BucketsData {
+			parent := strings.Join(row.Keys, keysSeparator)
+			results.histograms[parent] = append(results.histograms[parent], row)
+		}
+	}
+
+	return t.createBuckets(&results, 0, ""), nil
+}
+
+// levels returns chain of nested terms aggregations starting from this one.
+func (t *Terms) levels() []*Terms {
+	levels := make([]*Terms, 0, 1)
+	for level := t; level != nil; level = level.terms {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// keyExpression returns clickhouse expression converting values of terms field to strings.
+func (t *Terms) keyExpression() string {
+	return fmt.Sprintf("ifNull(toString(%s), '')", t.field.CHName)
+}
+
+// orderExpression returns clickhouse expression of terms buckets sorting.
+func (t *Terms) orderExpression() string {
+	direction := db.ASC
+	if t.settings.Desc {
+		direction = db.DESC
+	}
+	switch t.settings.Order {
+	case OrderByKey, "_term":
+		return fmt.Sprintf("min(%s) %s", t.field.CHName, direction)
+	case OrderByCount:
+		return fmt.Sprintf("doc_count %s, keys", direction)
+	}
+	if pos, ok := metricPosition(t.metrics, t.settings.Order); ok {
+		return fmt.Sprintf("metrics[%d] %s, keys", pos, direction)
+	}
+	// buckets are sorted by the default order if metric is not found
+	return fmt.Sprintf("doc_count %s, keys", db.DESC)
+}
+
+// createTermsRequest returns request selecting top terms of the last level for every bucket of parent levels.
+func (t *Terms) createTermsRequest(conn db.DataProvider, levels []*Terms, parents [][]string) *db.Request {
+	request := clickhouse.NewRequestTpl(conn)
+	request.What(keysExpression(levels))
+	request.AppendToWhat("count() as doc_count")
+	request.AppendToWhat(metricsExpression(t.metrics))
+
+	if t.commonFilter != nil {
+		request.WhereAnd(t.commonFilter.String())
+	}
+	request.WhereAnd(notNullCondition(levels))
+	request.WhereAnd(keysCondition(levels[:len(levels)-1], parents))
+
+	request.GroupBy("keys")
+	request.Having(fmt.Sprintf("doc_count >= %d", t.settings.MinDocCount))
+	request.OrderBy(t.orderExpression())
+	if t.settings.Size > 0 {
+		if len(levels) > 1 {
+			request.LimitBy(t.settings.Size, fmt.Sprintf("arraySlice(keys, 1, %d)", len(levels)-1))
+		} else {
+			request.Limit(t.settings.Size)
+		}
+	}
+	return request
+}
+
+// createHistogramRequest returns request calculating date histogram for every bucket of the last terms level.
+func (t *Terms) createHistogramRequest(conn db.DataProvider, levels []*Terms, parents [][]string) *db.Request {
+	request := clickhouse.NewRequestTpl(conn)
+	request.What(t.histogram.createAggFuncs().build())
+	request.AppendToWhat(keysExpression(levels))
+	request.AppendToWhat(t.histogram.keyExpression())
+	request.AppendToWhat(metricsExpression(t.histogram.metrics))
+
+	if t.histogram.commonFilter != nil {
+		request.WhereAnd(t.histogram.commonFilter.String())
+	}
+	request.WhereAnd(notNullCondition(levels))
+	request.WhereAnd(keysCondition(levels, parents))
+
+	request.GroupBy("keys, cur_key")
+	request.OrderBy("keys, cur_key")
+	return request
+}
+
+// createBuckets converts selected rows to buckets of the terms aggregation of the nesting level.
+func (t *Terms) createBuckets(results *termsResults, depth int, parent string) *BucketAggregationData {
+	terms := &BucketAggregationData{
+		AggName: t.name,
+		Buckets: bucketsStringer{
+			Buckets:  make([]Bucket, 0),
+			stringer: bucketsToArrayJSON,
+		},
+	}
+	if depth >= len(results.levels) {
+		return terms
+	}
+
+	for _, row := range results.levels[depth][parent] {
+		bucket := &termsBucket{
+			bucket: bucket{
+				key:      row.Keys[depth],
+				docCount: row.DocCount,
+			},
+			numeric: t.field.IsNumeric(),
+		}
+
+		subAggs := subAggregations(metricsResults(t.metrics, row.Metrics))
+		keys := strings.Join(row.Keys, keysSeparator)
+		switch {
+		case t.terms != nil:
+			subAggs = append(subAggs, t.terms.createBuckets(results, depth+1, keys))
+		case t.histogram != nil:
+			subAggs = append(subAggs, t.histogram.createBuckets(results.histograms[keys]))
+		}
+		bucket.subAggData = subAggs
+
+		terms.Buckets.Buckets = append(terms.Buckets.Buckets, bucket)
+	}
+	return terms
+}
+
+// keysExpression returns clickhouse expression selecting values of terms fields of all levels as single array.
+func keysExpression(levels []*Terms) string {
+	exprs := make([]string, len(levels))
+	for i, level := range levels {
+		exprs[i] = level.keyExpression()
+	}
+	return fmt.Sprintf("[ %s ] as keys", strings.Join(exprs, ","))
+}
+
+// notNullCondition excludes documents without values of nullable terms fields.
+func notNullCondition(levels []*Terms) string {
+	conds := make([]string, 0)
+	for _, level := range levels {
+		if level.field.IsNullable() {
+			conds = append(conds, fmt.Sprintf("isNotNull(%s)", level.field.CHName))
+		}
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// keysCondition selects documents having values of terms fields equal to keys of one of the parent buckets.
+func keysCondition(levels []*Terms, parents [][]string) string {
+	if len(levels) == 0 {
+		return ""
+	}
+	exprs := make([]string, len(levels))
+	for i, level := range levels {
+		exprs[i] = level.keyExpression()
+	}
+	tuples := make([]string, len(parents))
+	for i, keys := range parents {
+		values := make([]string, len(keys))
+		for j, key := range keys {
+			values[j] = models.QuoteString(key)
+		}
+		tuples[i] = fmt.Sprintf("(%s)", strings.Join(values, ", "))
+	}
+	return fmt.Sprintf("(%s) IN (%s)", strings.Join(exprs, ", "), strings.Join(tuples, ", "))
+}
+
+type termsBucket struct {
+	bucket
+	numeric bool
+}
+
+func (tb termsBucket) String() string {
+	key := tb.key.(string)
+	keyJSON := key
+	// keys of numeric fields are numbers
+	if value, err := strconv.ParseFloat(key, 64); !tb.numeric || err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		bytes, _ := json.Marshal(key)
+		keyJSON = string(bytes)
+	}
+	return fmt.Sprintf(`{"key":%s,%s}`, keyJSON, tb.bucket.String())
+}
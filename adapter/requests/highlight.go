@@ -0,0 +1,111 @@
+package requests
+
+import (
+	"path"
+	"sort"
+
+	"kibouse/adapter/requests/queries"
+)
+
+const (
+	defaultHighlightPreTag            = "<em>"
+	defaultHighlightPostTag           = "</em>"
+	defaultHighlightFragmentSize      = 100
+	defaultHighlightNumberOfFragments = 5
+)
+
+// Highlight contains settings of search hits highlighting.
+type Highlight struct {
+	PreTag  string
+	PostTag string
+	// highlighted fields by clickhouse names
+	Fields map[string]HighlightField
+}
+
+// HighlightField contains terms highlighted in the field and size of returned fragments of its text,
+// whole text is returned as single fragment if number of fragments is zero.
+type HighlightField struct {
+	Terms             []string
+	FragmentSize      int
+	NumberOfFragments int
+}
+
+// fetchHighlight parses elastic request section "highlight", terms are taken from the parsed query,
+// so it should be called after fetchQuery.
+func (req *ElasticRequest) fetchHighlight() {
+	cfg, ok := req.config["highlight"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	fields, ok := cfg["fields"].(map[string]interface{})
+	if !ok || req.Query == nil {
+		return
+	}
+
+	highlight := Highlight{
+		PreTag:  fetchHighlightTag(cfg["pre_tags"], defaultHighlightPreTag),
+		PostTag: fetchHighlightTag(cfg["post_tags"], defaultHighlightPostTag),
+		Fields:  make(map[string]HighlightField),
+	}
+	defaults := fetchHighlightField(cfg, HighlightField{
+		FragmentSize:      defaultHighlightFragmentSize,
+		NumberOfFragments: defaultHighlightNumberOfFragments,
+	})
+
+	terms := queries.HighlightTerms(req.Query)
+	// terms of all fields are highlighted in every field if require_field_match is disabled (kibana does it)
+	requireFieldMatch := true
+	if require, ok := cfg["require_field_match"].(bool); ok {
+		requireFieldMatch = require
+	}
+	allTerms := make([]string, 0)
+	for _, fieldTerms := range terms {
+		allTerms = append(allTerms, fieldTerms...)
+	}
+	sort.Strings(allTerms)
+
+	for pattern, fieldCfg := range fields {
+		for name, field := range req.tableInfo.DataFields {
+			if matched, _ := path.Match(pattern, field.KibanaName); !matched || !field.IsText() {
+				continue
+			}
+			settings := fetchHighlightField(fieldCfg, defaults)
+			if settings.Terms = terms[name]; !requireFieldMatch {
+				settings.Terms = allTerms
+			}
+			if len(settings.Terms) > 0 {
+				highlight.Fields[name] = settings
+			}
+		}
+	}
+	if len(highlight.Fields) > 0 {
+		req.Highlight = &highlight
+	}
+}
+
+// fetchHighlightField parses fragments settings of highlighting, missing settings are taken from defaults.
+func fetchHighlightField(config interface{}, defaults HighlightField) HighlightField {
+	settings := defaults
+	if size, ok := fetchJsonParamFromInterface("fragment_size", config); ok {
+		if size, ok := fetchInt(size); ok && size > 0 {
+			settings.FragmentSize = size
+		}
+	}
+	if number, ok := fetchJsonParamFromInterface("number_of_fragments", config); ok {
+		if number, ok := fetchInt(number); ok && number >= 0 {
+			settings.NumberOfFragments = number
+		}
+	}
+	return settings
+}
+
+// fetchHighlightTag returns the first of highlighting tags, elasticsearch uses the following ones
+// for less important terms.
+func fetchHighlightTag(tags interface{}, defaultTag string) string {
+	if tags, ok := tags.([]interface{}); ok && len(tags) > 0 {
+		if tag, ok := tags[0].(string); ok {
+			return tag
+		}
+	}
+	return defaultTag
+}
@@ -0,0 +1,65 @@
+package requests
+
+import (
+	"reflect"
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestParseHighlight(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+	query := `"query":{"bool":{"must":[{"query_string":{"query":"message:timeout AND NOT file:worker"}},` +
+		`{"match_phrase":{"hostname":{"query":"gate-1"}}}],"must_not":[{"match_phrase":{"status":"failed"}}]}}`
+
+	testData := []struct {
+		caseName string
+		request  string
+		fields   map[string]HighlightField
+	}{
+		{
+			caseName: "highlighting of requested fields",
+			request:  `{` + query + `,"highlight":{"fields":{"message":{"number_of_fragments":0},"hostname":{},"status":{},"pid":{}}}}`,
+			fields: map[string]HighlightField{
+				"message":  {Terms: []string{"timeout"}, FragmentSize: 100, NumberOfFragments: 0},
+				"hostname": {Terms: []string{"gate-1"}, FragmentSize: 100, NumberOfFragments: 5},
+			},
+		},
+		{
+			caseName: "kibana highlighting without required field match",
+			request: `{` + query + `,"highlight":{"pre_tags":["@kibana-highlighted-field@"],"post_tags":["@/kibana-highlighted-field@"],` +
+				`"fields":{"host*":{}},"require_field_match":false,"fragment_size":2147483647}}`,
+			fields: map[string]HighlightField{
+				"hostname": {Terms: []string{"gate-1", "timeout"}, FragmentSize: 2147483647, NumberOfFragments: 5},
+			},
+		},
+		{
+			caseName: "negated terms only",
+			request:  `{"query":{"bool":{"must_not":[{"match_phrase":{"status":"failed"}}]}},"highlight":{"fields":{"*":{}}}}`,
+		},
+		{
+			caseName: "request without query",
+			request:  `{"highlight":{"fields":{"*":{}}}}`,
+		},
+	}
+
+	for _, test := range testData {
+		parsed, err := ParseElasticJSON([]byte(test.request), &gateModel)
+		var fields map[string]HighlightField
+		if parsed.Highlight != nil {
+			fields = parsed.Highlight.Fields
+		}
+		if err != nil || !reflect.DeepEqual(fields, test.fields) {
+			t.Error("For", test.caseName, "\n expected: ", test.fields, "\n got: ", fields, err)
+		}
+	}
+
+	parsed, _ := ParseElasticJSON([]byte(testData[1].request), &gateModel)
+	if parsed.Highlight == nil || parsed.Highlight.PreTag != "@kibana-highlighted-field@" || parsed.Highlight.PostTag != "@/kibana-highlighted-field@" {
+		t.Error("For", "kibana highlighting tags", "\n expected: ", "@kibana-highlighted-field@", "\n got: ", parsed.Highlight)
+	}
+}
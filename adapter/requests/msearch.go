@@ -0,0 +1,63 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MultiSearchItem contains single search request of elastic _msearch request.
+type MultiSearchItem struct {
+	// index set by header, index from url is used if it is empty
+	Index string
+	// missing index is reported as empty search result instead of error
+	IgnoreUnavailable bool
+	Body              []byte
+}
+
+// multiSearchHeader is the header of search request, other header parameters sent by kibana
+// and grafana (search_type, preference, max_concurrent_shard_requests) do not affect the response.
+type multiSearchHeader struct {
+	Index             interface{} `json:"index"`
+	IgnoreUnavailable bool        `json:"ignore_unavailable"`
+}
+
+// ParseMultiSearch splits newline delimited body of _msearch request into search requests,
+// every request is preceded by its header line.
+func ParseMultiSearch(body []byte) ([]MultiSearchItem, error) {
+	lines := make([][]byte, 0)
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines)%2 != 0 {
+		return nil, errors.New("_msearch request body should contain pairs of header and search request lines")
+	}
+
+	items := make([]MultiSearchItem, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		var header multiSearchHeader
+		if err := json.Unmarshal(lines[i], &header); err != nil {
+			return nil, errors.Wrap(err, "cannot parse header of _msearch request")
+		}
+
+		item := MultiSearchItem{IgnoreUnavailable: header.IgnoreUnavailable, Body: lines[i+1]}
+		switch index := header.Index.(type) {
+		case string:
+			item.Index = index
+		case []interface{}:
+			indices := make([]string, 0, len(index))
+			for _, name := range index {
+				if name, ok := name.(string); ok {
+					indices = append(indices, name)
+				}
+			}
+			item.Index = strings.Join(indices, ",")
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,148 @@
+package requests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"kibouse/data/models"
+	"kibouse/data/wrappers"
+	"kibouse/db"
+)
+
+func TestParseMultiSearch(t *testing.T) {
+	testData := []struct {
+		caseName string
+		body     string
+		items    []MultiSearchItem
+		err      bool
+	}{
+		{
+			caseName: "kibana request with index list",
+			body:     "{\"index\":[\"logs_2p_gate\"],\"ignore_unavailable\":true,\"preference\":1560124800000}\n{\"size\":0}\n",
+			items:    []MultiSearchItem{{Index: "logs_2p_gate", IgnoreUnavailable: true, Body: []byte(`{"size":0}`)}},
+		},
+		{
+			caseName: "grafana requests",
+			body: "{\"search_type\":\"query_then_fetch\",\"ignore_unavailable\":true,\"index\":\"logs_*\"}\n{\"size\":0}\n" +
+				"{\"search_type\":\"query_then_fetch\",\"index\":[\"logs_a\",\"logs_b\"]}\n{\"size\":500}\n",
+			items: []MultiSearchItem{
+				{Index: "logs_*", IgnoreUnavailable: true, Body: []byte(`{"size":0}`)},
+				{Index: "logs_a,logs_b", Body: []byte(`{"size":500}`)},
+			},
+		},
+		{
+			caseName: "index from url",
+			body:     "{}\r\n{\"query\":{\"match_all\":{}}}",
+			items:    []MultiSearchItem{{Body: []byte(`{"query":{"match_all":{}}}`)}},
+		},
+		{
+			caseName: "missing search request",
+			body:     "{\"index\":\"logs_2p_gate\"}\n",
+			err:      true,
+		},
+	}
+
+	for _, test := range testData {
+		items, err := ParseMultiSearch([]byte(test.body))
+		if (err != nil) != test.err || !reflect.DeepEqual(items, test.items) {
+			t.Error("For", test.caseName, "\n expected: ", test.items, "\n got: ", items, err)
+		}
+	}
+}
+
+// recordedSearch contains expected results of recorded _msearch request: clickhouse requests,
+// rows returned by clickhouse for every request and aggregations of elastic response.
+type recordedSearch struct {
+	Index        string            `json:"index"`
+	Queries      []string          `json:"queries"`
+	Rows         []json.RawMessage `json:"rows"`
+	Aggregations json.RawMessage   `json:"aggregations"`
+}
+
+// recordingProvider returns recorded rows for aggregating requests and keeps requests sent to clickhouse.
+type recordingProvider struct {
+	table   string
+	scheme  *models.ModelInfo
+	rows    []json.RawMessage
+	queries []string
+}
+
+func (p *recordingProvider) DataTable() string {
+	return p.table
+}
+
+func (p *recordingProvider) DataScheme() *models.ModelInfo {
+	return p.scheme
+}
+
+func (p *recordingProvider) TablesSchemes() map[string]*models.ModelInfo {
+	return map[string]*models.ModelInfo{p.table: p.scheme}
+}
+
+func (p *recordingProvider) FetchData(req *db.Request) (wrappers.ChDataWrapper, error) {
+	return nil, nil
+}
+
+func (p *recordingProvider) CreateDataSelector(req *db.Request) func(items interface{}) error {
+	return func(items interface{}) error {
+		p.queries = append(p.queries, strings.Join(strings.Fields(req.Build()), " "))
+		if len(p.rows) == 0 {
+			return nil
+		}
+		rows := p.rows[0]
+		p.rows = p.rows[1:]
+		return json.Unmarshal(rows, items)
+	}
+}
+
+func TestGrafanaRecordedRequests(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+
+	requests, err := filepath.Glob("testdata/grafana/*.msearch")
+	if err != nil || len(requests) == 0 {
+		t.Fatal("recorded grafana requests are not found", err)
+	}
+
+	for _, request := range requests {
+		asserts := assert.New(t)
+		name := strings.TrimSuffix(filepath.Base(request), ".msearch")
+
+		body, err := ioutil.ReadFile(request)
+		asserts.NoError(err, name)
+		expected, err := ioutil.ReadFile(strings.TrimSuffix(request, ".msearch") + ".json")
+		asserts.NoError(err, name)
+		var recorded recordedSearch
+		if !asserts.NoError(json.Unmarshal(expected, &recorded), name) {
+			continue
+		}
+
+		items, err := ParseMultiSearch(body)
+		if !asserts.NoError(err, name) || !asserts.Len(items, 1, name) {
+			continue
+		}
+		asserts.Equal(recorded.Index, items[0].Index, name)
+
+		parsed, err := ParseElasticJSON(items[0].Body, &gateModel)
+		if !asserts.NoError(err, name) || !asserts.NotNil(parsed.Aggregations, name) {
+			continue
+		}
+
+		provider := &recordingProvider{table: items[0].Index, scheme: &gateModel, rows: recorded.Rows}
+		result, err := parsed.Aggregations.Aggregate(provider)
+		if !asserts.NoError(err, name) {
+			continue
+		}
+		asserts.Equal(recorded.Queries, provider.queries, name)
+		asserts.JSONEq(string(recorded.Aggregations), "{"+result.String()+"}", name)
+	}
+}
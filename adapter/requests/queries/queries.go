@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -201,27 +202,21 @@ type MatchClause struct {
 }
 
 func (mc *MatchClause) String() string {
-	arrayField := mc.Field.IsArray()
-	if mc.Field.IsString() {
-		if arrayField {
-			return fmt.Sprintf("(has(%s, '%v'))", mc.Field, mc.Value)
-		}
-		return fmt.Sprintf("(%s = '%v')", mc.Field.CHName, mc.Value)
+	literal, ok := mc.Field.Literal(mc.Value)
+	if !ok {
+		log.Warn("required value in 'match_phrase' clause has incorrect type")
+		return ""
 	}
-
-	if mc.Field.IsNumeric() {
-		if arrayField {
-			return fmt.Sprintf("(has(%s, %v))", mc.Field.CHName, mc.Value)
-		}
-		return fmt.Sprintf("(%s = %v)", mc.Field.CHName, mc.Value)
+	if mc.Field.IsArray() {
+		return fmt.Sprintf("(has(%s, %s))", mc.Field.CHName, literal)
 	}
-
-	log.Warn("required value in 'match_phrase' clause has incorrect type")
-	return ""
+	return fmt.Sprintf("(%s = %s)", mc.Field.CHName, literal)
 }
 
 type threshold struct {
-	value  float64
+	value float64
+	// boundary of time range in nanoseconds, it is kept apart since float64 loses precision of nanoseconds
+	nanos  int64
 	strict bool
 }
 
@@ -232,6 +227,8 @@ type RangeClause struct {
 	high     threshold
 	format   string
 	ArrayVal bool
+	// boundaries of time ranges are kept in nanoseconds and converted to values of time column.
+	timeField *models.CHField
 }
 
 // NewRange creates new representation of elastic range clause.
@@ -245,6 +242,19 @@ func NewRange(name string, isArrayVal bool) *RangeClause {
 	}
 }
 
+// NewTimeRange creates new representation of elastic range clause for the column with dates or timestamps,
+// its boundaries should be set in nanoseconds.
+func NewTimeRange(field models.CHField, isArrayVal bool) *RangeClause {
+	rc := NewRange(field.CHName, isArrayVal)
+	rc.timeField = &field
+	return rc
+}
+
+// IsTimeRange checks that range boundaries are set in nanoseconds.
+func (rc *RangeClause) IsTimeRange() bool {
+	return rc.timeField != nil
+}
+
 // AddLower sets lower boundary of data range.
 func (rc *RangeClause) AddLower(value float64, strict bool) *RangeClause {
 	rc.low = threshold{value: value, strict: strict}
@@ -267,6 +277,28 @@ func (rc *RangeClause) GetUpper() (float64, bool) {
 	return rc.high.value, rc.high.strict
 }
 
+// AddTimeLower sets lower boundary of time range in nanoseconds.
+func (rc *RangeClause) AddTimeLower(nanos int64, strict bool) *RangeClause {
+	rc.low = threshold{value: float64(nanos), nanos: nanos, strict: strict}
+	return rc
+}
+
+// GetTimeLower returns lower boundary of time range in nanoseconds.
+func (rc *RangeClause) GetTimeLower() (int64, bool) {
+	return rc.low.nanos, rc.low.strict
+}
+
+// AddTimeUpper sets upper boundary of time range in nanoseconds.
+func (rc *RangeClause) AddTimeUpper(nanos int64, strict bool) *RangeClause {
+	rc.high = threshold{value: float64(nanos), nanos: nanos, strict: strict}
+	return rc
+}
+
+// GetTimeUpper returns upper boundary of time range in nanoseconds.
+func (rc *RangeClause) GetTimeUpper() (int64, bool) {
+	return rc.high.nanos, rc.high.strict
+}
+
 // AddFormat sets elastic data format, like epoch_millis, etc.
 func (rc *RangeClause) AddFormat(format string) *RangeClause {
 	rc.format = format
@@ -283,18 +315,25 @@ func (rc *RangeClause) GetField() string {
 	return rc.field
 }
 
+func (rc *RangeClause) buildValue(bound threshold) string {
+	if rc.timeField != nil {
+		return rc.timeField.TimeLiteral(bound.nanos)
+	}
+	return fmt.Sprintf("%v", bound.value)
+}
+
 func (rc *RangeClause) buildLow() string {
 	if rc.low.strict {
-		return fmt.Sprintf("%v < %s", rc.low.value, rc.field)
+		return fmt.Sprintf("%s < %s", rc.buildValue(rc.low), rc.field)
 	}
-	return fmt.Sprintf("%v <= %s", rc.low.value, rc.field)
+	return fmt.Sprintf("%s <= %s", rc.buildValue(rc.low), rc.field)
 }
 
 func (rc *RangeClause) buildHigh() string {
 	if rc.high.strict {
-		return fmt.Sprintf("%s < %v", rc.field, rc.high.value)
+		return fmt.Sprintf("%s < %s", rc.field, rc.buildValue(rc.high))
 	}
-	return fmt.Sprintf("%s <= %v", rc.field, rc.high.value)
+	return fmt.Sprintf("%s <= %s", rc.field, rc.buildValue(rc.high))
 }
 
 func (rc *RangeClause) String() string {
@@ -304,12 +343,22 @@ func (rc *RangeClause) String() string {
 	return ""
 }
 
-// ExistsClause represents elastic exists query.
+// ExistsClause represents elastic exists query, it is meaningful only for Nullable columns, Map and JSON sub-fields.
 type ExistsClause struct {
 	Field string
+	// key of Map column sub-field
+	MapKey string
+	// path of JSON payload sub-field
+	JSONPath string
 }
 
 func (rc *ExistsClause) String() string {
+	if rc.JSONPath != "" {
+		return fmt.Sprintf("(JSONHas(%s, %s))", rc.Field, models.JSONPathArgs(rc.JSONPath))
+	}
+	if rc.MapKey != "" {
+		return fmt.Sprintf("(mapContains(%s, %s))", rc.Field, models.QuoteString(rc.MapKey))
+	}
 	return fmt.Sprintf("(isNotNull(%s))", rc.Field)
 }
 
@@ -334,34 +383,127 @@ func (uc *UnknownClause) String() string {
 func NewTermsClause() *TermsClause {
 	clause := TermsClause{}
 	clause.terms = make(map[string][]string)
+	clause.fields = make(map[string]models.CHField)
 	return &clause
 }
 
 // TermsClause represents elastic terms query.
 type TermsClause struct {
-	terms map[string][]string
+	terms  map[string][]string
+	fields map[string]models.CHField
 }
 
 // AddTerm appends new term to query.
-func (tc *TermsClause) AddTerm(field string, value string) {
-	if _, ok := tc.terms[field]; !ok {
-		tc.terms[field] = make([]string, 0)
+func (tc *TermsClause) AddTerm(field models.CHField, value string) {
+	if _, ok := tc.terms[field.CHName]; !ok {
+		tc.terms[field.CHName] = make([]string, 0)
+		tc.fields[field.CHName] = field
 	}
-	tc.terms[field] = append(tc.terms[field], value)
+	tc.terms[field.CHName] = append(tc.terms[field.CHName], value)
 }
 
 func (tc *TermsClause) String() string {
 	conds := make([]string, 0, len(tc.terms))
 	for field := range tc.terms {
-		termConds := make([]string, len(tc.terms[field]))
+		termConds := make([]string, 0, len(tc.terms[field]))
 		for j := range tc.terms[field] {
-			termConds[j] = fmt.Sprintf("(%s = '%s')", field, tc.terms[field][j])
+			if literal, ok := tc.fields[field].Literal(tc.terms[field][j]); ok {
+				termConds = append(termConds, fmt.Sprintf("(%s = %s)", field, literal))
+			}
+		}
+		if len(termConds) == 0 {
+			log.Warn("required values in 'terms' clause have incorrect type")
+			continue
 		}
 		conds = append(conds, "("+strings.Join(termConds, " OR ")+")")
 	}
 	return strings.Join(conds, " AND ")
 }
 
+// NewIdsClause creates new elastic ids query, documents are identified by values of the uuid field.
+func NewIdsClause(uuid *models.CHField, ids []string) *IdsClause {
+	return &IdsClause{uuid: uuid, ids: ids}
+}
+
+// IdsClause represents elastic ids query.
+type IdsClause struct {
+	uuid *models.CHField
+	ids  []string
+}
+
+func (ic *IdsClause) String() string {
+	literals := make([]string, 0, len(ic.ids))
+	if ic.uuid != nil {
+		for _, id := range ic.ids {
+			if literal, ok := ic.uuid.Literal(id); ok {
+				literals = append(literals, literal)
+			}
+		}
+	}
+	// documents of models without uuid field could not be found by ids
+	if len(literals) == 0 {
+		return "(0)"
+	}
+	return fmt.Sprintf("(%s IN (%s))", ic.uuid.CHName, strings.Join(literals, ", "))
+}
+
+// NewSearchAfterClause creates new condition of elastic search_after parameter.
+func NewSearchAfterClause() *SearchAfterClause {
+	return &SearchAfterClause{keys: make([]searchAfterKey, 0)}
+}
+
+// SearchAfterClause selects documents following the document with given sort values,
+// documents having equal values of the first sort fields are compared by the next ones.
+type SearchAfterClause struct {
+	keys []searchAfterKey
+}
+
+type searchAfterKey struct {
+	expr    string
+	literal string
+	desc    bool
+}
+
+// AddValue appends sort value of the next sort field, false is returned if value has incorrect type.
+func (sa *SearchAfterClause) AddValue(field models.CHField, desc bool, value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	literal, ok := field.Literal(value)
+	if ok {
+		sa.keys = append(sa.keys, searchAfterKey{expr: field.CHName, literal: literal, desc: desc})
+	}
+	return ok
+}
+
+// AddTimeValue appends sort value of the next time field set in nanoseconds, elastic returns sort
+// values of dates in milliseconds, so field values are compared with milliseconds precision.
+func (sa *SearchAfterClause) AddTimeValue(field models.CHField, desc bool, nanos int64) {
+	sa.keys = append(sa.keys, searchAfterKey{
+		expr:    fmt.Sprintf("intDiv(%s, %d)", field.TimeNanosExpression(), time.Millisecond),
+		literal: strconv.FormatInt(nanos/int64(time.Millisecond), 10),
+		desc:    desc,
+	})
+}
+
+func (sa *SearchAfterClause) String() string {
+	conds := make([]string, 0, len(sa.keys))
+	equal := make([]string, 0, len(sa.keys))
+	for _, key := range sa.keys {
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		cond := append(equal[:len(equal):len(equal)], fmt.Sprintf("%s %s %s", key.expr, op, key.literal))
+		conds = append(conds, "("+strings.Join(cond, " AND ")+")")
+		equal = append(equal, fmt.Sprintf("%s = %s", key.expr, key.literal))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
 type queryItem interface {
 	toString(bool, RangeClause) string
 }
@@ -375,11 +517,11 @@ func (si *simpleItem) toString(wildCards bool, r RangeClause) string {
 }
 
 type fieldMatch struct {
-	logicalOp  string
-	field      models.CHField
-	expr       string
-	indexTable string
-	tsColumn   string
+	logicalOp string
+	field     models.CHField
+	expr      string
+	table     string
+	tsColumn  string
 }
 
 func (fm *fieldMatch) toString(wildCards bool, r RangeClause) string {
@@ -387,16 +529,12 @@ func (fm *fieldMatch) toString(wildCards bool, r RangeClause) string {
 	match := strings.Trim(fm.expr, "\"")
 
 	// column has been indexed
-	if fm.indexTable != "" && fm.tsColumn != "" {
-		// Request to inverted index returns timestamps of required log entries,
-		// after that we should remove all inappropriate logs with the same time
-		// using additional filtering conditions
-		invIndexRequest, filters := index.CreateFullTextSearchConditions(fm.expr, fm.field.CHName, fm.indexTable)
-		// add time range for search optimization
-		invIndexRequest.WhereAnd(r.String())
-		return fmt.Sprintf("%s (%s IN (%s) AND %s)", fm.logicalOp, fm.tsColumn, invIndexRequest.Build(), filters)
+	if fm.table != "" && fm.tsColumn != "" {
+		filter := index.CreateFullTextSearchFilter(fm.expr, fm.field, fm.table, fm.tsColumn, invertedIndexTimeRange(r))
+		return fmt.Sprintf("%s (%s)", fm.logicalOp, filter)
 	}
 
+	column := fm.field.CHName
 	if _, err := strconv.ParseFloat(fm.expr, 64); err == nil && fm.field.IsNumeric() {
 		format = "%s (%s = %s)"
 	} else if wildCards && strings.ContainsAny(match, "? & *") {
@@ -404,9 +542,34 @@ func (fm *fieldMatch) toString(wildCards bool, r RangeClause) string {
 		match = strings.Replace(match, "?", "_", -1)
 		match = "%" + match + "%"
 		format = "%s like(%s, '%s')"
+		if !fm.field.IsText() {
+			// dates, enums, uuids and ip addresses are matched by their text representation
+			column = fmt.Sprintf("toString(%s)", column)
+		}
+	} else if literal, ok := fm.field.Literal(match); ok && !fm.field.IsNumeric() && !fm.field.IsText() {
+		// dates, enums, uuids and ip addresses are compared as values of column type
+		return fmt.Sprintf("%s (%s = %s)", fm.logicalOp, column, literal)
 	}
 
-	return fmt.Sprintf(format, fm.logicalOp, fm.field.CHName, match)
+	return fmt.Sprintf(format, fm.logicalOp, column, match)
+}
+
+var invertedIndexTimestamp = models.CHField{
+	CHName:   index.InvertedIndexTimestampColumn,
+	CHType:   "UInt64",
+	TimeUnit: models.Nanoseconds,
+}
+
+// invertedIndexTimeRange converts logs time range to condition for inverted index table,
+// which always stores timestamps in nanoseconds.
+func invertedIndexTimeRange(r RangeClause) string {
+	if r.field == "" {
+		return ""
+	}
+	return NewTimeRange(invertedIndexTimestamp, false).
+		AddTimeLower(r.GetTimeLower()).
+		AddTimeUpper(r.GetTimeUpper()).
+		String()
 }
 
 // MatchQueryClause represents elastic expr query.
@@ -441,6 +604,39 @@ func NewMatchQueryClause(query string, analyzeWildCards bool, tableInfo *models.
 	}
 }
 
+// HighlightTerms returns terms searched by query_string and match_phrase conditions by clickhouse
+// names of fields, terms of negated conditions are skipped.
+func HighlightTerms(cond Clause) map[string][]string {
+	terms := make(map[string][]string)
+	collectHighlightTerms(cond, terms)
+	return terms
+}
+
+func collectHighlightTerms(cond Clause, terms map[string][]string) {
+	switch clause := cond.(type) {
+	case *BoolSection:
+		for _, section := range []Section{clause.Must, clause.Filter, clause.Should} {
+			collectHighlightTerms(section, terms)
+		}
+	case *MustNotSection:
+		return
+	case Section:
+		for _, child := range clause.Children() {
+			collectHighlightTerms(child, terms)
+		}
+	case *MatchQueryClause:
+		for _, item := range clause.items {
+			if match, ok := item.(*fieldMatch); ok && !strings.Contains(match.logicalOp, "NOT") {
+				terms[match.field.CHName] = append(terms[match.field.CHName], strings.Trim(match.expr, `"`))
+			}
+		}
+	case *MatchClause:
+		if value, ok := clause.Value.(string); ok {
+			terms[clause.Field.CHName] = append(terms[clause.Field.CHName], value)
+		}
+	}
+}
+
 func parseMatchQuery(query string, tableInfo *models.ModelInfo) []queryItem {
 	parts := splitQuery(query)
 	if len(parts) == 1 && tableInfo != nil {
@@ -457,11 +653,11 @@ func createAllIndexedColsSearchQuery(tableInfo *models.ModelInfo, expr string, o
 
 		if tableInfo.DataFields[name].FullTextSearch && tsOk {
 			items = append(items, &fieldMatch{
-				field:      tableInfo.DataFields[name].CHField,
-				expr:       expr,
-				logicalOp:  logicalOp,
-				indexTable: index.GetInvertedIndexTableName(tableInfo.DBName),
-				tsColumn:   tsColumn.CHName,
+				field:     tableInfo.DataFields[name].CHField,
+				expr:      expr,
+				logicalOp: logicalOp,
+				table:     tableInfo.DBName,
+				tsColumn:  tsColumn.TimeNanosExpression(),
 			})
 			logicalOp = "OR"
 		}
@@ -502,18 +698,18 @@ func parseWideNotation(parts []string, tableInfo *models.ModelInfo) []queryItem
 		} else if parts[i] == ":" {
 			currField = prev
 		} else if prev == ":" || (currField != "" && openedBraces > 0) {
-			currMatch.field.CHName = currField
 			currMatch.expr = parts[i]
 			currMatch.logicalOp = currLogicalOp
+			fieldInfo, ok := tableInfo.GetField(currField)
 			// incorrect field name.
-			if _, ok := tableInfo.DataFields[currField]; !ok {
+			if !ok {
 				return nil
-			} else if tableInfo.DataFields[currField].FullTextSearch && tsOk {
-				currMatch.indexTable = index.GetInvertedIndexTableName(tableInfo.DBName)
-				currMatch.tsColumn = tsField.CHName
+			} else if fieldInfo.FullTextSearch && tsOk {
+				currMatch.table = tableInfo.DBName
+				currMatch.tsColumn = tsField.TimeNanosExpression()
 			}
 
-			currMatch.field.CHType = tableInfo.DataFields[currField].CHType
+			currMatch.field = fieldInfo.CHField
 			items = append(items, currMatch)
 
 			currMatch = &fieldMatch{}
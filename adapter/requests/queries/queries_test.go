@@ -1 +1,241 @@
 package queries
+
+import (
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestClausesTypedLiterals(t *testing.T) {
+	mapField := models.CHField{CHName: "labels", CHType: "Map(String, String)"}
+	jsonModel := models.ModelInfo{
+		DataFields: map[string]*models.FieldProps{
+			"payload": {CHField: models.CHField{CHName: "payload", CHType: "String"}, JSONBlob: true},
+			"attrs":   {CHField: models.CHField{CHName: "attrs", CHType: "Object('json')"}},
+		},
+		JSONPaths: map[string]map[string]string{
+			"payload": {"user.id": models.JSONInt, "duration": models.JSONFloat},
+		},
+	}
+	enumModel := models.ModelInfo{
+		DataFields: map[string]*models.FieldProps{
+			"level": {CHField: models.CHField{CHName: "level", CHType: "Enum8('error' = 1, 'info' = 2)"}},
+		},
+	}
+	jsonSubField := func(name string) models.CHField {
+		field, _ := jsonModel.GetField(name)
+		return field.CHField
+	}
+	testData := []struct {
+		caseName string
+		clause   Clause
+		result   string
+	}{
+		{
+			caseName: "match nullable string",
+			clause:   NewMatchClause(models.CHField{CHName: "host", CHType: "Nullable(String)"}, "it's"),
+			result:   `(host = 'it\'s')`,
+		},
+		{
+			caseName: "match low cardinality array",
+			clause:   NewMatchClause(models.CHField{CHName: "tags", CHType: "Array(LowCardinality(String))"}, "prod"),
+			result:   `(has(tags, 'prod'))`,
+		},
+		{
+			caseName: "match decimal",
+			clause:   NewMatchClause(models.CHField{CHName: "amount", CHType: "Decimal(18, 2)"}, 10.5),
+			result:   `(amount = 10.5)`,
+		},
+		{
+			caseName: "match decimal with incorrect value",
+			clause:   NewMatchClause(models.CHField{CHName: "amount", CHType: "Decimal(18, 2)"}, "ten"),
+			result:   ``,
+		},
+		{
+			caseName: "match uuid",
+			clause:   NewMatchClause(models.CHField{CHName: "id", CHType: "UUID"}, "61f0c404-5cb3-11e7-907b-a6006ad3dba0"),
+			result:   `(id = toUUID('61f0c404-5cb3-11e7-907b-a6006ad3dba0'))`,
+		},
+		{
+			caseName: "match ipv4",
+			clause:   NewMatchClause(models.CHField{CHName: "remote_ip", CHType: "IPv4"}, "10.0.0.1"),
+			result:   `(remote_ip = toIPv4('10.0.0.1'))`,
+		},
+		{
+			caseName: "match datetime64",
+			clause:   NewMatchClause(models.CHField{CHName: "created", CHType: "DateTime64(6, 'UTC')"}, "2019-01-01 00:00:00"),
+			result:   `(created = toDateTime64('2019-01-01 00:00:00', 6))`,
+		},
+		{
+			caseName: "query string with enum value",
+			clause:   NewMatchQueryClause("level:error", true, &enumModel),
+			result:   ` (level = 'error')`,
+		},
+		{
+			caseName: "query string with enum wildcard",
+			clause:   NewMatchQueryClause("level:err*", true, &enumModel),
+			result:   ` like(toString(level), '%err%%')`,
+		},
+		{
+			caseName: "match map sub-field",
+			clause:   NewMatchClause(mapField.MapSubField("env"), "prod"),
+			result:   `(labels['env'] = 'prod')`,
+		},
+		{
+			caseName: "match integer path of json payload",
+			clause:   NewMatchClause(jsonSubField("payload.user.id"), 42),
+			result:   `(JSONExtractInt(payload, 'user', 'id') = 42)`,
+		},
+		{
+			caseName: "range of float path of json payload",
+			clause:   NewRange(jsonSubField("payload.duration").CHName, false).AddLower(0.5, false).AddUpper(2, false),
+			result:   `(0.5 <= JSONExtractFloat(payload, 'duration') AND JSONExtractFloat(payload, 'duration') <= 2)`,
+		},
+		{
+			caseName: "match path of json payload not found in data sample",
+			clause:   NewMatchClause(jsonSubField("payload.user.name"), "bob"),
+			result:   `(JSONExtractString(payload, 'user', 'name') = 'bob')`,
+		},
+		{
+			caseName: "match path of json column",
+			clause:   NewMatchClause(jsonSubField("attrs.env"), "prod"),
+			result:   `(JSONExtractString(toJSONString(attrs), 'env') = 'prod')`,
+		},
+		{
+			caseName: "exists path of json payload",
+			clause:   &ExistsClause{Field: "payload", JSONPath: "user.id"},
+			result:   `(JSONHas(payload, 'user', 'id'))`,
+		},
+		{
+			caseName: "exists nullable",
+			clause:   &ExistsClause{Field: "host"},
+			result:   `(isNotNull(host))`,
+		},
+		{
+			caseName: "exists map sub-field",
+			clause:   &ExistsClause{Field: "labels", MapKey: "env"},
+			result:   `(mapContains(labels, 'env'))`,
+		},
+	}
+
+	for _, test := range testData {
+		result := test.clause.String()
+		if result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result,
+			)
+		}
+	}
+}
+
+func TestTimeRanges(t *testing.T) {
+	testData := []struct {
+		caseName string
+		field    models.CHField
+		result   string
+	}{
+		{
+			caseName: "timestamp in nanoseconds",
+			field:    models.CHField{CHName: "ts", CHType: "UInt64", TimeUnit: models.Nanoseconds},
+			result:   `(1560124800123000000 <= ts AND ts < 1560211200000000000)`,
+		},
+		{
+			caseName: "timestamp in milliseconds",
+			field:    models.CHField{CHName: "ts", CHType: "UInt64", TimeUnit: models.Milliseconds},
+			result:   `(1560124800123 <= ts AND ts < 1560211200000)`,
+		},
+		{
+			caseName: "datetime",
+			field:    models.CHField{CHName: "created", CHType: "DateTime"},
+			result:   `(toDateTime(1560124800) <= created AND created < toDateTime(1560211200))`,
+		},
+		{
+			caseName: "datetime64 with milliseconds",
+			field:    models.CHField{CHName: "created", CHType: "DateTime64(3, 'UTC')"},
+			result: `(toDateTime64(toDecimal64('1560124800.123', 3), 3) <= created AND ` +
+				`created < toDateTime64(toDecimal64('1560211200.000', 3), 3))`,
+		},
+		{
+			caseName: "date",
+			field:    models.CHField{CHName: "day", CHType: "Date"},
+			result:   `(toDate(toDateTime(1560124800)) <= day AND day < toDate(toDateTime(1560211200)))`,
+		},
+	}
+
+	for _, test := range testData {
+		result := NewTimeRange(test.field, false).
+			AddTimeLower(1560124800123000000, false).
+			AddTimeUpper(1560211200000000000, true).
+			String()
+		if result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result,
+			)
+		}
+	}
+}
+
+func TestDocumentsPaging(t *testing.T) {
+	uuid := models.CHField{CHName: "uuid", CHType: "UInt64"}
+	ts := models.CHField{CHName: "ts", CHType: "UInt64", TimeUnit: models.Nanoseconds}
+	testData := []struct {
+		caseName string
+		clause   Clause
+		result   string
+	}{
+		{
+			caseName: "ids",
+			clause:   NewIdsClause(&uuid, []string{"18446744073709551615", "42"}),
+			result:   `(uuid IN (18446744073709551615, 42))`,
+		},
+		{
+			caseName: "ids with incorrect values",
+			clause:   NewIdsClause(&uuid, []string{"AWvRb3Lq"}),
+			result:   `(0)`,
+		},
+		{
+			caseName: "ids without uuid field",
+			clause:   NewIdsClause(nil, []string{"42"}),
+			result:   `(0)`,
+		},
+		{
+			caseName: "search after time and uuid",
+			clause: func() Clause {
+				clause := NewSearchAfterClause()
+				clause.AddTimeValue(ts, true, 1560124800123000000)
+				clause.AddValue(uuid, true, "18446744073709551615")
+				return clause
+			}(),
+			result: `((intDiv(ts, 1000000) < 1560124800123) OR ` +
+				`(intDiv(ts, 1000000) = 1560124800123 AND uuid < 18446744073709551615))`,
+		},
+		{
+			caseName: "search after datetime ascending",
+			clause: func() Clause {
+				clause := NewSearchAfterClause()
+				clause.AddTimeValue(models.CHField{CHName: "created", CHType: "DateTime"}, false, 1560124800000000000)
+				return clause
+			}(),
+			result: `((intDiv((toUInt64(created) * 1000000000), 1000000) > 1560124800000))`,
+		},
+		{
+			caseName: "search after without values",
+			clause: func() Clause {
+				clause := NewSearchAfterClause()
+				clause.AddValue(uuid, false, nil)
+				return clause
+			}(),
+			result: ``,
+		},
+	}
+
+	for _, test := range testData {
+		if result := test.clause.String(); result != test.result {
+			t.Error("For", test.caseName, "\n expected: ", test.result, "\n got: ", result)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package requests
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"kibouse/data/models"
@@ -20,21 +27,69 @@ type ElasticRequest struct {
 	matchQueries []*queries.MatchQueryClause
 	Index          string
 	Size           int
+	From           int
+	TrackTotalHits int
 	Query          queries.Clause
 	Sorting        queries.SortSection
 	SortingFields  []string
+	SearchAfter    *queries.SearchAfterClause
 	DocValueFields []string
+	DocValueFormat map[string]string
+	ScriptFields   map[string]string
+	Source         SourceFilter
+	Highlight      *Highlight
 	Aggregations   aggregations.Aggregation
 }
 
+// DefaultMaxResultWindow is the default max number of hits could be paged through by from and size
+// of search request (index.max_result_window of elasticsearch).
+const DefaultMaxResultWindow = 10000
+
+var maxResultWindow = DefaultMaxResultWindow
+var maxResultWindowMutex = &sync.RWMutex{}
+
+// SetMaxResultWindow sets max value of from + size of search requests, deeper pages
+// should be requested by search_after or scroll.
+func SetMaxResultWindow(window int) {
+	if window <= 0 {
+		window = DefaultMaxResultWindow
+	}
+	maxResultWindowMutex.Lock()
+	maxResultWindow = window
+	maxResultWindowMutex.Unlock()
+}
+
+// ResultWindowError is returned for search requests of hits beyond the max result window.
+type ResultWindowError struct {
+	Window int
+	Result int
+}
+
+func (e *ResultWindowError) Error() string {
+	return fmt.Sprintf("Result window is too large, from + size must be less than or equal to: [%d] but was [%d]. "+
+		"See the scroll api for a more efficient way to request large data sets.", e.Window, e.Result)
+}
+
 // UpdateLogsLowerTimeRange sets new logs time range lower bound.
 func (req *ElasticRequest) UpdateLogsLowerTimeRange(low time.Time) {
 	if timeRange := req.getLogsTimeRange(); timeRange != nil {
-		timeRange.AddLower(float64(low.UnixNano()), true)
+		timeRange.AddTimeLower(low.UnixNano(), true)
 		req.addTimeRangesToQuery()
 	}
 }
 
+// QueryCondition returns clickhouse condition of the request query enclosed in brackets,
+// conditions of query string are not enclosed in brackets by themselves.
+func (req *ElasticRequest) QueryCondition() string {
+	if req.Query == nil {
+		return ""
+	}
+	if query := req.Query.String(); query != "" {
+		return "(" + query + ")"
+	}
+	return ""
+}
+
 // ParseElasticJSON parses request to elasticsearch.
 func ParseElasticJSON(jsonCfg []byte, tableInfo *models.ModelInfo) (elasticCfg ElasticRequest, err error) {
 	if err = json.Unmarshal(jsonCfg, &elasticCfg.config); err != nil {
@@ -52,6 +107,8 @@ func ParseElasticJSON(jsonCfg []byte, tableInfo *models.ModelInfo) (elasticCfg E
 	elasticCfg.ranges = make(map[string]*queries.RangeClause)
 	elasticCfg.SortingFields = make([]string, 0)
 	elasticCfg.DocValueFields = make([]string, 0)
+	elasticCfg.DocValueFormat = make(map[string]string)
+	elasticCfg.ScriptFields = make(map[string]string)
 	elasticCfg.matchQueries = make([]*queries.MatchQueryClause, 0)
 
 	// json with elasticsearch index doesn't contain any important data
@@ -60,9 +117,19 @@ func ParseElasticJSON(jsonCfg []byte, tableInfo *models.ModelInfo) (elasticCfg E
 	}
 
 	elasticCfg.fetchSize()
+	elasticCfg.fetchFrom()
+	elasticCfg.fetchTrackTotalHits()
+	if err = elasticCfg.checkResultWindow(); err != nil {
+		return
+	}
 	elasticCfg.fetchSortingInfo()
+	elasticCfg.addTieBreaker()
+	elasticCfg.fetchSearchAfter(jsonCfg)
 	elasticCfg.fetchDocValueFields()
+	elasticCfg.fetchScriptFields()
+	elasticCfg.fetchSource()
 	elasticCfg.fetchQuery()
+	elasticCfg.fetchHighlight()
 	elasticCfg.fetchAggregationSettings()
 
 	elasticCfg.addTimeRangesToQuery()
@@ -95,6 +162,40 @@ func (req *ElasticRequest) fetchSize() {
 	}
 }
 
+func (req *ElasticRequest) fetchFrom() {
+	if param, found := fetchJsonParamFromMap("from", req.config); found {
+		if from, ok := fetchInt(param); ok && from > 0 {
+			req.From = from
+		}
+	}
+}
+
+// fetchTrackTotalHits parses track_total_hits: true (default) requests exact number of matching documents,
+// false disables counting, integer value sets number of documents counted exactly.
+func (req *ElasticRequest) fetchTrackTotalHits() {
+	param, found := fetchJsonParamFromMap("track_total_hits", req.config)
+	if !found {
+		return
+	}
+	if track, ok := param.(bool); ok && !track {
+		req.TrackTotalHits = TrackTotalHitsDisabled
+	} else if limit, ok := fetchInt(param); ok {
+		req.TrackTotalHits = limit
+		if limit <= 0 {
+			req.TrackTotalHits = TrackTotalHitsDisabled
+		}
+	}
+}
+
+func (req *ElasticRequest) checkResultWindow() error {
+	maxResultWindowMutex.RLock()
+	defer maxResultWindowMutex.RUnlock()
+	if req.From+req.Size > maxResultWindow {
+		return errors.WithStack(&ResultWindowError{Window: maxResultWindow, Result: req.From + req.Size})
+	}
+	return nil
+}
+
 func (req *ElasticRequest) fetchIndex() bool {
 	if param, found := fetchJsonParamFromMap("index", req.config); found {
 		if index, ok := param.(string); ok {
@@ -112,44 +213,160 @@ func (req *ElasticRequest) fetchIndex() bool {
 }
 
 func (req *ElasticRequest) fieldExists(name string) bool {
-	_, ok := req.tableInfo.DataFields[name]
+	_, ok := req.tableInfo.GetField(name)
 	return ok
 }
 
 // fetchSortingInfo parses section with info about requested data sorting
-// "sort":[{<field_name>:{"order":<asc\desc>}}, ...]
+// "sort":[{<field_name>:{"order":<asc\desc>}}, ...] or "sort":[{<field_name>:<asc\desc>}, ...]
 func (req *ElasticRequest) fetchSortingInfo() {
 	sortCfg, ok := fetchJsonParamFromMap("sort", req.config)
 	if !ok {
 		return
 	}
-	sortCfgArr := sortCfg.([]interface{})
+	// grafana sends sorting by single field as object: "sort":{<field_name>:{"order":<asc\desc>}}
+	sortCfgArr, ok := sortCfg.([]interface{})
+	if !ok {
+		sortCfgArr = []interface{}{sortCfg}
+	}
 
 	req.Sorting = queries.SortSection{}
 	for i := range sortCfgArr {
-		fieldSorting := sortCfgArr[i].(map[string]interface{})
+		fieldSorting, ok := sortCfgArr[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
 		for fieldName := range fieldSorting {
 			correctedName := correctFieldName(fieldName)
-			if !req.fieldExists(correctedName) {
+			// kibana context view breaks ties of documents with equal time by their index order
+			if fieldName == "_doc" {
+				if uuid, ok := req.tableInfo.GetUuidField(); ok {
+					correctedName = uuid.KibanaName
+				}
+			}
+			field, ok := req.tableInfo.GetField(correctedName)
+			if !ok {
+				continue
+			}
+			order, ok := fieldSorting[fieldName].(string)
+			if fieldSortingCfg, isMap := fieldSorting[fieldName].(map[string]interface{}); isMap {
+				order, ok = fieldSortingCfg["order"].(string)
+			}
+			if !ok {
 				continue
 			}
-			fieldSortingCfg := fieldSorting[fieldName].(map[string]interface{})
 			req.SortingFields = append(req.SortingFields, correctedName)
-			req.Sorting.AppendChild(&queries.SortClause{Field: correctedName, Sorting: fieldSortingCfg["order"].(string)})
+			req.Sorting.AppendChild(&queries.SortClause{Field: field.CHName, Sorting: order})
 		}
 	}
 }
 
+// addTieBreaker appends sorting by uuid field to the requested sorting, so documents with equal values
+// of sort fields are returned in the same order by every request (required for paging through them).
+func (req *ElasticRequest) addTieBreaker() {
+	uuid, ok := req.tableInfo.GetUuidField()
+	if !ok || len(req.SortingFields) == 0 || req.HasTieBreaker() {
+		return
+	}
+	sorting := req.Sorting.Children()
+	order := sorting[len(sorting)-1].(*queries.SortClause).Sorting
+	req.SortingFields = append(req.SortingFields, uuid.KibanaName)
+	req.Sorting.AppendChild(&queries.SortClause{Field: uuid.CHName, Sorting: order})
+}
+
+// HasTieBreaker checks that hits are sorted by uuid field, so sort values of every hit are unique.
+func (req *ElasticRequest) HasTieBreaker() bool {
+	uuid, ok := req.tableInfo.GetUuidField()
+	if !ok {
+		return false
+	}
+	for _, name := range req.SortingFields {
+		if name == uuid.KibanaName {
+			return true
+		}
+	}
+	return false
+}
+
+// SortByTieBreaker sorts hits by uuid field if sorting is not requested,
+// so pages of scroll are selected by sort values instead of offset.
+func (req *ElasticRequest) SortByTieBreaker() {
+	if uuid, ok := req.tableInfo.GetUuidField(); ok && len(req.SortingFields) == 0 {
+		req.SortingFields = append(req.SortingFields, uuid.KibanaName)
+		req.Sorting.AppendChild(&queries.SortClause{Field: uuid.CHName, Sorting: string(queries.Asc)})
+	}
+}
+
+// fetchSearchAfter parses sort values of the last document of previous page: "search_after":[<value>, ...].
+// Numbers are decoded without conversion to float64 for keeping precision of 64-bit ids.
+func (req *ElasticRequest) fetchSearchAfter(jsonCfg []byte) {
+	var cfg struct {
+		SearchAfter []interface{} `json:"search_after"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonCfg))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cfg); err == nil && len(cfg.SearchAfter) > 0 {
+		req.SetSearchAfter(cfg.SearchAfter)
+	}
+}
+
+// SetSearchAfter sets sort values of the document preceding requested hits,
+// values are matched with sort fields by their positions.
+func (req *ElasticRequest) SetSearchAfter(values []interface{}) {
+	req.SearchAfter = queries.NewSearchAfterClause()
+	sorting := req.Sorting.Children()
+	for i, value := range values {
+		if i >= len(req.SortingFields) {
+			break
+		}
+		field, _ := req.tableInfo.GetField(req.SortingFields[i])
+		desc := strings.EqualFold(sorting[i].(*queries.SortClause).Sorting, "desc")
+
+		if number, ok := value.(json.Number); ok && field.IsTime() {
+			value, _ = number.Float64()
+		}
+		if field.IsTime() {
+			// sort values of dates are returned in milliseconds
+			if nanos, ok := convertTimeBound(value, "epoch_millis", field.CHField); ok {
+				req.SearchAfter.AddTimeValue(field.CHField, desc, nanos)
+				continue
+			}
+		} else if req.SearchAfter.AddValue(field.CHField, desc, value) {
+			continue
+		}
+		// following values are meaningless without the previous ones
+		log.Warnf("couldn't parse 'search_after' value of field %s: %v", req.SortingFields[i], value)
+		break
+	}
+}
+
 // fetchDocValueFields fetches list of document value fields from elasticsearch request json.
 func (req *ElasticRequest) fetchDocValueFields() {
 	docFields, ok := fetchJsonParamFromMap("docvalue_fields", req.config)
 	if !ok {
 		return
 	}
-	docFieldsArr := docFields.([]interface{})
-	req.DocValueFields = make([]string, len(docFieldsArr))
+	docFieldsArr, ok := docFields.([]interface{})
+	if !ok {
+		return
+	}
+	req.DocValueFields = make([]string, 0, len(docFieldsArr))
 	for i := range docFieldsArr {
-		req.DocValueFields[i] = correctFieldName(docFieldsArr[i].(string))
+		// kibana 6.4+ requests fields with format: {"field": "ts", "format": "date_time"}
+		switch field := docFieldsArr[i].(type) {
+		case string:
+			req.DocValueFields = append(req.DocValueFields, correctFieldName(field))
+		case map[string]interface{}:
+			name, ok := field["field"].(string)
+			if !ok {
+				continue
+			}
+			name = correctFieldName(name)
+			req.DocValueFields = append(req.DocValueFields, name)
+			if format, ok := field["format"].(string); ok {
+				req.DocValueFormat[name] = format
+			}
+		}
 	}
 }
 
@@ -185,6 +402,10 @@ func (req *ElasticRequest) parseBool(config interface{}) queries.Clause {
 }
 
 func (req *ElasticRequest) parseBoolSection(config interface{}, section queries.Section) queries.Section {
+	// kibana 7.x sets single clause of section without array
+	if clause, ok := config.(map[string]interface{}); ok {
+		config = []interface{}{clause}
+	}
 	if clauses, ok := config.([]interface{}); !ok {
 		return &queries.EmptySection{}
 	} else {
@@ -213,6 +434,10 @@ func (req *ElasticRequest) parseSimpleQueryClause(config interface{}) queries.Cl
 			return req.parseTerms(value)
 		case "term":
 			return req.parseTerms(value)
+		case "ids":
+			return req.parseIds(value)
+		case "constant_score":
+			return req.parseConstantScore(value)
 		case "match_all":
 			// no special conditions required
 			return nil
@@ -225,7 +450,14 @@ func (req *ElasticRequest) parseSimpleQueryClause(config interface{}) queries.Cl
 func (req *ElasticRequest) parseExists(config interface{}) queries.Clause {
 	if field, ok := fetchJsonParamFromInterface("field", config); ok {
 		if fieldStr, ok := field.(string); ok {
-			return &queries.ExistsClause{Field: correctFieldName(fieldStr)}
+			name := correctFieldName(fieldStr)
+			if fieldInfo, ok := req.tableInfo.GetField(name); ok && fieldInfo.MapColumn != "" {
+				return &queries.ExistsClause{Field: fieldInfo.MapColumn, MapKey: fieldInfo.MapKey}
+			}
+			if fieldInfo, ok := req.tableInfo.GetField(name); ok && fieldInfo.JSONColumn != "" {
+				return &queries.ExistsClause{Field: fieldInfo.JSONColumn, JSONPath: fieldInfo.JSONPath}
+			}
+			return &queries.ExistsClause{Field: name}
 		}
 	}
 	log.Warnf("couldn't parse query 'exists' clause")
@@ -238,7 +470,7 @@ func (req *ElasticRequest) parseMatchPhrase(config interface{}) queries.Clause {
 			// match_phrase section could be like this: "match_phrase": { "name": "Bob" }
 			// or like this: "match_phrase": { "name": { "query": "Bob" } }
 			name := correctFieldName(fieldName)
-			if fieldInfo, ok := req.tableInfo.DataFields[name]; ok {
+			if fieldInfo, ok := req.tableInfo.GetField(name); ok {
 				if matchValue, ok := match[fieldName].(map[string]interface{}); ok {
 					return queries.NewMatchClause(fieldInfo.CHField, matchValue["query"])
 				} else {
@@ -255,13 +487,16 @@ func (req *ElasticRequest) parseRange(config interface{}) queries.Clause {
 	if rangeMap, ok := config.(map[string]interface{}); ok {
 		for fieldName := range rangeMap {
 			name := correctFieldName(fieldName)
-			field, ok := req.tableInfo.DataFields[name]
+			field, ok := req.tableInfo.GetField(name)
 			if !ok {
 				break
 			}
-			rangeClause := queries.NewRange(name, field.IsArray())
+			rangeClause := queries.NewRange(field.CHName, field.IsArray())
+			if field.IsTime() {
+				rangeClause = queries.NewTimeRange(field.CHField, field.IsArray())
+			}
 			if rangeParams, ok := rangeMap[fieldName].(map[string]interface{}); ok {
-				req.ranges[name] = fetchRangeParams(rangeParams, rangeClause)
+				req.ranges[name] = fetchRangeParams(rangeParams, rangeClause, field.CHField)
 				return req.ranges[name]
 			}
 		}
@@ -270,28 +505,81 @@ func (req *ElasticRequest) parseRange(config interface{}) queries.Clause {
 	return &queries.UnknownClause{}
 }
 
-func convertByFormat(val float64, format string) float64 {
-	// converting milliseconds (elasticsearch timestamp format) to nanoseconds (logs timestamp format) for range of time
-	if format == "epoch_millis" {
-		return val * 1000000
+// date formats of time range boundaries set by strings.
+var rangeDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// convertTimeBound converts boundary of time range to nanoseconds. Numbers are treated according to the range format
+// (epoch_millis or epoch_second), values of time column units are expected if format is not set.
+func convertTimeBound(val interface{}, format string, field models.CHField) (int64, bool) {
+	switch value := val.(type) {
+	case float64:
+		switch format {
+		case "epoch_millis":
+			return scaleTimeBound(value, time.Millisecond), true
+		case "epoch_second":
+			return scaleTimeBound(value, time.Second), true
+		}
+		return scaleTimeBound(value, field.TimeUnitDuration()), true
+	case string:
+		for _, layout := range rangeDateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.UnixNano(), true
+			}
+		}
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return convertTimeBound(number, format, field)
+		}
 	}
-	return val
+	return 0, false
 }
 
-func fetchRangeParams(config map[string]interface{}, rc *queries.RangeClause) *queries.RangeClause {
-	if format, ok := config["format"]; ok {
-		rc.AddFormat(format.(string))
+// scaleTimeBound converts number of time units to nanoseconds, integer numbers are multiplied exactly
+// and fractions of units are rounded to nanoseconds.
+func scaleTimeBound(value float64, unit time.Duration) int64 {
+	whole := math.Trunc(value)
+	return int64(whole)*int64(unit) + int64(math.Round((value-whole)*float64(unit)))
+}
+
+func fetchRangeParams(config map[string]interface{}, rc *queries.RangeClause, field models.CHField) *queries.RangeClause {
+	if format, ok := config["format"].(string); ok {
+		rc.AddFormat(format)
 	}
 	for name, val := range config {
+		if name != "lt" && name != "lte" && name != "gt" && name != "gte" {
+			continue
+		}
+		if rc.IsTimeRange() {
+			nanos, ok := convertTimeBound(val, rc.GetFormat(), field)
+			if !ok {
+				log.Warnf("couldn't parse '%s' boundary of 'range' clause: %v", name, val)
+				continue
+			}
+			switch name {
+			case "lt":
+				rc.AddTimeUpper(nanos, true)
+			case "lte":
+				rc.AddTimeUpper(nanos, false)
+			case "gt":
+				rc.AddTimeLower(nanos, true)
+			case "gte":
+				rc.AddTimeLower(nanos, false)
+			}
+			continue
+		}
+		bound, ok := val.(float64)
+		if !ok {
+			log.Warnf("couldn't parse '%s' boundary of 'range' clause: %v", name, val)
+			continue
+		}
 		switch name {
 		case "lt":
-			rc.AddUpper(convertByFormat(val.(float64), rc.GetFormat()), true)
+			rc.AddUpper(bound, true)
 		case "lte":
-			rc.AddUpper(convertByFormat(val.(float64), rc.GetFormat()), false)
+			rc.AddUpper(bound, false)
 		case "gt":
-			rc.AddLower(convertByFormat(val.(float64), rc.GetFormat()), true)
+			rc.AddLower(bound, true)
 		case "gte":
-			rc.AddLower(convertByFormat(val.(float64), rc.GetFormat()), false)
+			rc.AddLower(bound, false)
 		}
 	}
 	return rc
@@ -319,19 +607,53 @@ func (req *ElasticRequest) parseQueryString(config interface{}) queries.Clause {
 	return &queries.UnknownClause{}
 }
 
+// parseIds parses elastic ids query: "ids":{"values":[<id>, ...]}, documents are identified
+// by values of the model uuid field.
+func (req *ElasticRequest) parseIds(config interface{}) queries.Clause {
+	values, ok := fetchJsonParamFromInterface("values", config)
+	idsList, isList := values.([]interface{})
+	if !ok || !isList {
+		log.Warnf("couldn't parse query 'ids' clause")
+		return &queries.UnknownClause{}
+	}
+	ids := make([]string, 0, len(idsList))
+	for _, id := range idsList {
+		if number, ok := id.(float64); ok {
+			id = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		ids = append(ids, fmt.Sprintf("%v", id))
+	}
+	if uuid, ok := req.tableInfo.GetUuidField(); ok {
+		return queries.NewIdsClause(&uuid.CHField, ids)
+	}
+	return queries.NewIdsClause(nil, ids)
+}
+
+// parseConstantScore parses filter of elastic constant_score query, scores of documents are not calculated.
+func (req *ElasticRequest) parseConstantScore(config interface{}) queries.Clause {
+	if filter, ok := fetchJsonParamFromInterface("filter", config); ok {
+		if _, ok := filter.(map[string]interface{}); ok {
+			return req.parseSimpleQueryClause(filter)
+		}
+	}
+	log.Warnf("couldn't parse query 'constant_score' clause")
+	return &queries.UnknownClause{}
+}
+
 func (req *ElasticRequest) parseTerms(config interface{}) queries.Clause {
 	if termsCfg, ok := config.(map[string]interface{}); ok {
 		terms := queries.NewTermsClause()
 		for fieldName := range termsCfg {
-			if !req.fieldExists(fieldName) {
+			field, ok := req.tableInfo.GetField(fieldName)
+			if !ok {
 				continue
 			}
 			if fieldVals, ok := termsCfg[fieldName].([]interface{}); ok {
 				for i := range fieldVals {
-					terms.AddTerm(fieldName, fieldVals[i].(string))
+					terms.AddTerm(field.CHField, fmt.Sprintf("%v", fieldVals[i]))
 				}
 			} else if fieldVal, ok := termsCfg[fieldName].(string); ok {
-				terms.AddTerm(fieldName, fieldVal)
+				terms.AddTerm(field.CHField, fieldVal)
 			} else {
 				log.Warnf("couldn't parse field values list in terms clause")
 			}
@@ -343,30 +665,41 @@ func (req *ElasticRequest) parseTerms(config interface{}) queries.Clause {
 }
 
 func (req *ElasticRequest) fetchAggregationSettings() {
-	req.Aggregations = req.parseAggregation(req.config)
+	aggs := req.parseAggregations(req.config)
+	if len(aggs) > 1 {
+		log.Warnf("more than one aggregation found on single nesting level")
+	}
+	if len(aggs) > 0 {
+		req.Aggregations = aggs[0]
+	}
 }
 
-func (req *ElasticRequest) parseAggregation(config map[string]interface{}) aggregations.Aggregation {
+// parseAggregations parses all aggregations of single nesting level ordered by their names.
+func (req *ElasticRequest) parseAggregations(config map[string]interface{}) []aggregations.Aggregation {
 	aggs, ok := fetchJsonParamFromMap("aggs", config)
 	if !ok {
 		return nil
 	}
-	if aggsMap, ok := aggs.(map[string]interface{}); ok {
-		if len(aggsMap) > 1 {
-			log.Warnf("more than one aggregation found on single nesting level")
-		}
-		for aggName := range aggsMap {
-			aggregation := aggsMap[aggName]
-			if aggSettings, ok := aggregation.(map[string]interface{}); ok {
-				aggregation := req.parseAggregationSettings(aggSettings)
-				if aggregation != nil {
-					aggregation.SetAggName(aggName)
-				}
-				return aggregation
+	aggsMap, ok := aggs.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(aggsMap))
+	for aggName := range aggsMap {
+		names = append(names, aggName)
+	}
+	sort.Strings(names)
+
+	parsed := make([]aggregations.Aggregation, 0, len(names))
+	for _, aggName := range names {
+		if aggSettings, ok := aggsMap[aggName].(map[string]interface{}); ok {
+			if aggregation := req.parseAggregationSettings(aggSettings); aggregation != nil {
+				aggregation.SetAggName(aggName)
+				parsed = append(parsed, aggregation)
 			}
 		}
 	}
-	return nil
+	return parsed
 }
 
 func (req *ElasticRequest) parseAggregationSettings(aggSettings map[string]interface{}) aggregations.Aggregation {
@@ -377,17 +710,30 @@ func (req *ElasticRequest) parseAggregationSettings(aggSettings map[string]inter
 			agg = req.parseDateHistogramSettings(aggSettings[aggType])
 		case "filters":
 			agg = req.parseFiltersSettings(aggSettings[aggType])
+		case "terms":
+			agg = req.parseTermsSettings(aggSettings[aggType])
+		case aggregations.AvgMetric, aggregations.SumMetric, aggregations.MinMetric, aggregations.MaxMetric,
+			aggregations.ValueCountMetric, aggregations.CardinalityMetric, aggregations.PercentilesMetric:
+			agg = req.parseMetricSettings(aggType, aggSettings[aggType])
 		}
 	}
 
 	if agg != nil {
-		agg.SetSubAgg(req.parseAggregation(aggSettings))
+		for _, subAgg := range req.parseAggregations(aggSettings) {
+			if err := agg.SetSubAgg(subAgg); err != nil {
+				log.Warnf(err.Error())
+			}
+		}
 		agg.AddCommonFilter(req.Query)
 	}
 
 	return agg
 }
 
+// histogramIntervalParams lists parameters of histogram interval: interval is used by elasticsearch
+// before 7.2, fixed_interval and calendar_interval are used by later versions.
+var histogramIntervalParams = []string{"interval", "fixed_interval", "calendar_interval"}
+
 func (req *ElasticRequest) parseDateHistogramSettings(settings interface{}) aggregations.Aggregation {
 	if histogramCfg, ok := settings.(map[string]interface{}); ok {
 		field, ok := histogramCfg["field"].(string)
@@ -395,19 +741,46 @@ func (req *ElasticRequest) parseDateHistogramSettings(settings interface{}) aggr
 			log.Warnf("couldn't find timestamp field for histogram aggregation")
 			return nil
 		}
-		interval, ok := histogramCfg["interval"].(string)
-		if !ok {
+		interval := ""
+		for _, param := range histogramIntervalParams {
+			if value, ok := histogramCfg[param].(string); ok {
+				interval = value
+				break
+			}
+		}
+		if interval == "" {
 			log.Warnf("couldn't find interval field for histogram aggregation")
 			return nil
 		}
 		correctedName := correctFieldName(field)
+		fieldInfo, ok := req.tableInfo.GetField(correctedName)
+		if !ok || !fieldInfo.IsTime() {
+			log.Warnf("histogram aggregation field %s is not a time field", correctedName)
+			return nil
+		}
 
 		if fieldRange, ok := req.ranges[correctedName]; ok {
-			agg, err := aggregations.CreateDateHistogramAgg(interval, correctedName, fieldRange, req.Size > 0)
+			agg, err := aggregations.CreateDateHistogramAgg(interval, fieldInfo.CHField, fieldRange, req.Size > 0)
 			if err != nil {
 				log.Warnf(err.Error())
 				return nil
 			}
+			if minDocCount, ok := fetchInt(histogramCfg["min_doc_count"]); ok && minDocCount >= 0 {
+				agg.SetMinDocCount(uint64(minDocCount))
+			}
+			if format, ok := histogramCfg["format"].(string); ok {
+				agg.SetFormat(format)
+			}
+			// bounds are set in milliseconds (or as dates) regardless of histogram format
+			if bounds, ok := histogramCfg["extended_bounds"].(map[string]interface{}); ok {
+				min, minOk := convertTimeBound(bounds["min"], "epoch_millis", fieldInfo.CHField)
+				max, maxOk := convertTimeBound(bounds["max"], "epoch_millis", fieldInfo.CHField)
+				if minOk && maxOk {
+					agg.SetExtendedBounds(min, max)
+				} else {
+					log.Warnf("couldn't parse extended bounds of histogram aggregation")
+				}
+			}
 			return agg
 		} else {
 			log.Warnf("couldn't find time range settings for histogram")
@@ -418,6 +791,88 @@ func (req *ElasticRequest) parseDateHistogramSettings(settings interface{}) aggr
 	return nil
 }
 
+// defaultTermsSize is the number of terms buckets returned if size is not set.
+const defaultTermsSize = 10
+
+// parseTermsSettings parses terms aggregation section:
+// "terms":{"field":"hostname","size":10,"order":{"_count":"desc"},"min_doc_count":1}.
+func (req *ElasticRequest) parseTermsSettings(settings interface{}) aggregations.Aggregation {
+	termsCfg, ok := settings.(map[string]interface{})
+	if !ok {
+		log.Warnf("couldn't parse terms aggregation settings")
+		return nil
+	}
+	field, ok := termsCfg["field"].(string)
+	if !ok {
+		log.Warnf("couldn't find field of terms aggregation")
+		return nil
+	}
+	fieldInfo, ok := req.tableInfo.GetField(correctFieldName(field))
+	if !ok {
+		log.Warnf("terms aggregation field %s is not found", field)
+		return nil
+	}
+
+	termsSettings := aggregations.TermsSettings{Size: defaultTermsSize}
+	if size, ok := fetchInt(termsCfg["size"]); ok && size >= 0 {
+		termsSettings.Size = size
+	}
+	if minDocCount, ok := fetchInt(termsCfg["min_doc_count"]); ok && minDocCount >= 0 {
+		termsSettings.MinDocCount = uint64(minDocCount)
+	}
+	if order, ok := termsCfg["order"].(map[string]interface{}); ok {
+		for name, direction := range order {
+			termsSettings.Order = name
+			termsSettings.Desc = direction == "desc"
+		}
+	}
+
+	agg, err := aggregations.CreateTermsAgg(fieldInfo.CHField, termsSettings)
+	if err != nil {
+		log.Warnf(err.Error())
+		return nil
+	}
+	return agg
+}
+
+// parseMetricSettings parses metric aggregation section, e.g. "avg":{"field":"duration"}
+// or "percentiles":{"field":"duration","percents":[50,95,99]}.
+func (req *ElasticRequest) parseMetricSettings(kind string, settings interface{}) aggregations.Aggregation {
+	field, ok := fetchJsonParamFromInterface("field", settings)
+	if !ok {
+		log.Warnf("couldn't find field of %s aggregation", kind)
+		return nil
+	}
+	fieldName, _ := field.(string)
+	fieldInfo, ok := req.tableInfo.GetField(correctFieldName(fieldName))
+	if !ok {
+		log.Warnf("%s aggregation field %s is not found", kind, fieldName)
+		return nil
+	}
+
+	percents := make([]float64, 0)
+	if param, ok := fetchJsonParamFromInterface("percents", settings); ok {
+		values, _ := param.([]interface{})
+		for _, value := range values {
+			switch percent := value.(type) {
+			case float64:
+				percents = append(percents, percent)
+			case string:
+				if number, err := strconv.ParseFloat(percent, 64); err == nil {
+					percents = append(percents, number)
+				}
+			}
+		}
+	}
+
+	agg, err := aggregations.CreateMetricAgg(kind, fieldInfo.CHField, percents)
+	if err != nil {
+		log.Warnf(err.Error())
+		return nil
+	}
+	return agg
+}
+
 func (req *ElasticRequest) parseFiltersSettings(settings interface{}) aggregations.Aggregation {
 	// filters section has the following format
 	//"filters": {
@@ -448,6 +903,18 @@ func (req *ElasticRequest) parseFiltersList(settings interface{}) *aggregations.
 	return nil
 }
 
+// fetchInt returns integer parameter of request, grafana sends some numbers as strings.
+func fetchInt(param interface{}) (int, bool) {
+	switch value := param.(type) {
+	case float64:
+		return int(value), true
+	case string:
+		number, err := strconv.Atoi(value)
+		return number, err == nil
+	}
+	return 0, false
+}
+
 func fetchJsonParamFromMap(name string, config map[string]interface{}) (interface{}, bool) {
 	if param, ok := config[name]; ok {
 		return param, ok
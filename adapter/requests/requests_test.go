@@ -6,6 +6,7 @@ import (
 	"time"
 	"reflect"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
 	"kibouse/data/models"
@@ -216,6 +217,12 @@ func TestParseElasticJSON(t *testing.T) {
 				},
 			),
 		},
+		{
+			descr: "fetch grafana sorting by single field",
+			request: []byte(`{"sort":{"ts":{"order":"desc","unmapped_type":"boolean"}}}`),
+			tableInfo: &gateModel,
+			parsedCfg: emptyCfgWithSorting(map[string]queries.Order{"ts": queries.Desc, "uuid": queries.Desc}),
+		},
 		{
 			descr: "fetch kibana match filter condition for unknown data attribute",
 			request: []byte(`{"query":{"match_phrase":{"pd":{"query":41671}}}}`),
@@ -251,9 +258,9 @@ func TestParseElasticJSON(t *testing.T) {
 			request: []byte(`{"query":{"range":{"ts":{"gt":1560124800000,"lte":1560211200000}}}}`),
 			tableInfo: &gateModel,
 			parsedCfg: emptyCfgWithQuery(
-				queries.NewRange("ts", false).
-					AddLower(1560124800000, true).
-					AddUpper(1560211200000, false).
+				queries.NewTimeRange(gateModel.DataFields["ts"].CHField, false).
+					AddTimeLower(1560124800000, true).
+					AddTimeUpper(1560211200000, false).
 					AddFormat("epoch_millis")),
 		},
 		{
@@ -261,9 +268,9 @@ func TestParseElasticJSON(t *testing.T) {
 			request: []byte(`{"query":{"range":{"@ts.keyword":{"gte":1560124800000,"lt":1560211200000,"format":"epoch_millis"}}}}`),
 			tableInfo: &gateModel,
 			parsedCfg: emptyCfgWithQuery(
-				queries.NewRange("ts", false).
-					AddLower(1560124800000000000, false).
-					AddUpper(1560211200000000000, true).
+				queries.NewTimeRange(gateModel.DataFields["ts"].CHField, false).
+					AddTimeLower(1560124800000000000, false).
+					AddTimeUpper(1560211200000000000, true).
 					AddFormat("epoch_millis")),
 		},
 		{
@@ -287,9 +294,9 @@ func TestParseElasticJSON(t *testing.T) {
 					AppendChild(queries.NewMatchClause(gateModel.DataFields["pid"].CHField, 41671)).
 					AppendChild(queries.NewRange("line", false).AddUpper(500, true).AddLower(100, false)).
 					AppendChild(
-						queries.NewRange("ts", false).
-							AddLower(1560124800000000000, false).
-							AddUpper(1560211200000000000, false).
+						queries.NewTimeRange(gateModel.DataFields["ts"].CHField, false).
+							AddTimeLower(1560124800000000000, false).
+							AddTimeUpper(1560211200000000000, false).
 							AddFormat("epoch_millis")).
 					AppendChild(&queries.ExistsClause{Field: "pid"}).
 					AppendChild(&queries.BoolSection{
@@ -349,4 +356,119 @@ func TestParseElasticJSON(t *testing.T) {
 			asserts.Equal(test.parsedCfg.Query.String(), parsedCfg.Query.String(), caseName(i, test.descr, "Query detailed"))
 		}
 	}
-}
\ No newline at end of file
+}
+func TestParseContextRequests(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+
+	testData := []struct {
+		caseName      string
+		request       string
+		query         string
+		sorting       string
+		sortingFields []string
+		searchAfter   string
+	}{
+		{
+			caseName: "kibana 6.x anchor document",
+			request: `{"size":1,"query":{"bool":{"must":[{"constant_score":{"filter":{"ids":{"type":"doc","values":["42"]}}}}]}},` +
+				`"sort":[{"ts":{"order":"desc","unmapped_type":"boolean"}},{"_doc":{"order":"desc","unmapped_type":"boolean"}}]}`,
+			query:         `((uuid IN (42)))`,
+			sorting:       `ts DESC,uuid DESC`,
+			sortingFields: []string{"ts", "uuid"},
+		},
+		{
+			caseName: "kibana 7.x successors of anchor document",
+			request: `{"size":5,"query":{"bool":{"must":{"constant_score":{"filter":{"range":{"ts":{"format":"epoch_millis","gte":1560124800000,"lte":1560124900000}}}}},` +
+				`"must_not":{"ids":{"values":["42"]}}}},"search_after":[1560124800123,"18446744073709551615"],"sort":[{"ts":"desc"},{"_doc":"desc"}]}`,
+			query:         `((1560124800000000000 <= ts AND ts <= 1560124900000000000)) AND ( NOT (uuid IN (42)))`,
+			sorting:       `ts DESC,uuid DESC`,
+			sortingFields: []string{"ts", "uuid"},
+			searchAfter: `((intDiv(ts, 1000000) < 1560124800123) OR ` +
+				`(intDiv(ts, 1000000) = 1560124800123 AND uuid < 18446744073709551615))`,
+		},
+		{
+			caseName:      "search after number of uuid",
+			request:       `{"size":5,"search_after":[1560124800123,18446744073709551615],"sort":[{"ts":"asc"},{"_doc":"asc"}]}`,
+			sorting:       `ts ASC,uuid ASC`,
+			sortingFields: []string{"ts", "uuid"},
+			searchAfter: `((intDiv(ts, 1000000) > 1560124800123) OR ` +
+				`(intDiv(ts, 1000000) = 1560124800123 AND uuid > 18446744073709551615))`,
+		},
+	}
+
+	for _, test := range testData {
+		asserts := assert.New(t)
+		parsed, err := ParseElasticJSON([]byte(test.request), &gateModel)
+		if !asserts.NoError(err, test.caseName) {
+			continue
+		}
+		query := ""
+		if parsed.Query != nil {
+			query = parsed.Query.String()
+		}
+		searchAfter := ""
+		if parsed.SearchAfter != nil {
+			searchAfter = parsed.SearchAfter.String()
+		}
+		asserts.Equal(test.query, query, test.caseName)
+		asserts.Equal(test.sorting, parsed.Sorting.String(), test.caseName)
+		asserts.Equal(test.sortingFields, parsed.SortingFields, test.caseName)
+		asserts.Equal(test.searchAfter, searchAfter, test.caseName)
+	}
+}
+
+func TestParsePaging(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+	defer SetMaxResultWindow(DefaultMaxResultWindow)
+	SetMaxResultWindow(1000)
+
+	testData := []struct {
+		caseName string
+		request  string
+		from     int
+		sorting  string
+		err      bool
+	}{
+		{
+			caseName: "from and size within result window",
+			request:  `{"from":500,"size":500,"sort":[{"ts":{"order":"desc"}}]}`,
+			from:     500,
+			sorting:  `ts DESC,uuid DESC`,
+		},
+		{
+			caseName: "from and size beyond result window",
+			request:  `{"from":501,"size":500}`,
+			err:      true,
+		},
+		{
+			caseName: "sorting by uuid without tie breaker",
+			request:  `{"size":10,"sort":[{"uuid":{"order":"asc"}},{"ts":{"order":"desc"}}]}`,
+			sorting:  `uuid ASC,ts DESC`,
+		},
+	}
+
+	for _, test := range testData {
+		parsed, err := ParseElasticJSON([]byte(test.request), &gateModel)
+		if test.err {
+			if _, ok := errors.Cause(err).(*ResultWindowError); !ok {
+				t.Error("For", test.caseName, "\n expected result window error", "\n got: ", err)
+			}
+			continue
+		}
+		if err != nil || parsed.From != test.from || parsed.Sorting.String() != test.sorting {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.from, test.sorting,
+				"\n got: ", parsed.From, parsed.Sorting.String(), err,
+			)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package requests
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxScrollKeepAlive is the default max time scroll context is kept between requests of its pages
+// (search.max_keep_alive of elasticsearch).
+const DefaultMaxScrollKeepAlive = 24 * time.Hour
+
+// ScrollContext contains search request of scroll and position of its next page. Pages of hits are
+// selected by sort values of the last returned hit, hits without sorting are skipped by offset.
+type ScrollContext struct {
+	Index       string
+	Body        []byte
+	SearchAfter []interface{}
+	From        int
+	expires     time.Time
+}
+
+var scrolls = map[string]*ScrollContext{}
+var maxScrollKeepAlive = DefaultMaxScrollKeepAlive
+var scrollsMutex = &sync.Mutex{}
+
+// SetMaxScrollKeepAlive sets max time scroll context could be kept between requests of its pages.
+func SetMaxScrollKeepAlive(keepAlive time.Duration) {
+	if keepAlive <= 0 {
+		keepAlive = DefaultMaxScrollKeepAlive
+	}
+	scrollsMutex.Lock()
+	maxScrollKeepAlive = keepAlive
+	scrollsMutex.Unlock()
+}
+
+// elastic time units not supported by time.ParseDuration.
+var keepAliveUnits = map[string]string{"d": "h", "micros": "us", "nanos": "ns"}
+
+// ParseKeepAlive parses time scroll context is kept set in elastic time units, e.g. "1m" or "30s".
+func ParseKeepAlive(value string) (time.Duration, error) {
+	for unit, goUnit := range keepAliveUnits {
+		if number := strings.TrimSuffix(value, unit); number != value {
+			if days, err := strconv.Atoi(number); err == nil && unit == "d" {
+				value = strconv.Itoa(days*24) + goUnit
+			} else {
+				value = number + goUnit
+			}
+			break
+		}
+	}
+	keepAlive, err := time.ParseDuration(value)
+	if err != nil || keepAlive <= 0 {
+		return 0, errors.New("failed to parse scroll keep alive: " + value)
+	}
+
+	scrollsMutex.Lock()
+	defer scrollsMutex.Unlock()
+	if keepAlive > maxScrollKeepAlive {
+		return 0, errors.Errorf("keep alive for scroll (%s) is too large, it must be less than (%s)", keepAlive, maxScrollKeepAlive)
+	}
+	return keepAlive, nil
+}
+
+// OpenScroll creates new scroll context kept for keepAlive and returns its id, expired contexts are removed.
+func OpenScroll(index string, body []byte, keepAlive time.Duration) (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", errors.Wrap(err, "cannot generate scroll id")
+	}
+	id := base64.RawURLEncoding.EncodeToString(bytes)
+
+	scrollsMutex.Lock()
+	defer scrollsMutex.Unlock()
+	removeExpiredScrolls(time.Now())
+	scrolls[id] = &ScrollContext{Index: index, Body: body, expires: time.Now().Add(keepAlive)}
+	return id, nil
+}
+
+// GetScroll returns scroll context by its id, context is prolonged for keepAlive if it is set.
+func GetScroll(id string, keepAlive time.Duration) (ScrollContext, bool) {
+	scrollsMutex.Lock()
+	defer scrollsMutex.Unlock()
+	removeExpiredScrolls(time.Now())
+	scroll, ok := scrolls[id]
+	if !ok {
+		return ScrollContext{}, false
+	}
+	if keepAlive > 0 {
+		scroll.expires = time.Now().Add(keepAlive)
+	}
+	return *scroll, true
+}
+
+// SetScrollPage sets position of the scroll page in the search request. Page follows sort values of the last
+// hit of previous page only if hits are sorted by unique tie-breaker, otherwise hits with the same sort values
+// (e.g. timestamps of the same millisecond) could be skipped, so the page is selected by offset.
+func (req *ElasticRequest) SetScrollPage(scroll ScrollContext) {
+	req.SortByTieBreaker()
+	if scroll.SearchAfter != nil && req.HasTieBreaker() {
+		req.SetSearchAfter(scroll.SearchAfter)
+	} else {
+		req.From = scroll.From
+	}
+}
+
+// MoveScroll moves scroll context to the page following returned hits, searchAfter contains
+// sort values of the last hit (nil for hits without sorting).
+func MoveScroll(id string, searchAfter []interface{}, hits int) {
+	scrollsMutex.Lock()
+	defer scrollsMutex.Unlock()
+	if scroll, ok := scrolls[id]; ok && hits > 0 {
+		scroll.SearchAfter = searchAfter
+		scroll.From += hits
+	}
+}
+
+// ClearScrolls removes scroll contexts by their ids (all contexts if ids are not set)
+// and returns number of removed contexts.
+func ClearScrolls(ids []string) int {
+	scrollsMutex.Lock()
+	defer scrollsMutex.Unlock()
+	removeExpiredScrolls(time.Now())
+	if len(ids) == 0 {
+		freed := len(scrolls)
+		scrolls = map[string]*ScrollContext{}
+		return freed
+	}
+	freed := 0
+	for _, id := range ids {
+		if _, ok := scrolls[id]; ok {
+			delete(scrolls, id)
+			freed++
+		}
+	}
+	return freed
+}
+
+func removeExpiredScrolls(now time.Time) {
+	for id, scroll := range scrolls {
+		if scroll.expires.Before(now) {
+			delete(scrolls, id)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package requests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"kibouse/data/models"
+)
+
+func TestParseKeepAlive(t *testing.T) {
+	testData := []struct {
+		value     string
+		keepAlive time.Duration
+		valid     bool
+	}{
+		{value: "1m", keepAlive: time.Minute, valid: true},
+		{value: "30s", keepAlive: 30 * time.Second, valid: true},
+		{value: "500ms", keepAlive: 500 * time.Millisecond, valid: true},
+		{value: "1d", keepAlive: 24 * time.Hour, valid: true},
+		{value: "2d", valid: false},
+		{value: "0s", valid: false},
+		{value: "1 minute", valid: false},
+	}
+
+	for _, test := range testData {
+		keepAlive, err := ParseKeepAlive(test.value)
+		if (err == nil) != test.valid || keepAlive != test.keepAlive {
+			t.Error("For", test.value, "\n expected: ", test.keepAlive, test.valid, "\n got: ", keepAlive, err)
+		}
+	}
+}
+
+func TestScrollContexts(t *testing.T) {
+	defer ClearScrolls(nil)
+
+	id, err := OpenScroll("logs_2p_gate", []byte(`{"size":2}`), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	MoveScroll(id, []interface{}{"1560124800123", "42"}, 2)
+	MoveScroll(id, nil, 0)
+	scroll, ok := GetScroll(id, 0)
+	expected := ScrollContext{Index: "logs_2p_gate", Body: []byte(`{"size":2}`), SearchAfter: []interface{}{"1560124800123", "42"}, From: 2}
+	scroll.expires = time.Time{}
+	if !ok || !reflect.DeepEqual(scroll, expected) {
+		t.Error("For moved scroll", "\n expected: ", expected, "\n got: ", scroll, ok)
+	}
+
+	expiring, err := OpenScroll("logs_2p_gate", nil, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := GetScroll(expiring, 0); ok {
+		t.Error("For expired scroll", "\n expected: removed", "\n got: ", expiring)
+	}
+
+	if freed := ClearScrolls([]string{id, "missing"}); freed != 1 {
+		t.Error("For cleared scroll", "\n expected: ", 1, "\n got: ", freed)
+	}
+	if _, ok := GetScroll(id, time.Minute); ok {
+		t.Error("For cleared scroll", "\n expected: removed", "\n got: ", id)
+	}
+}
+
+type accessLog struct {
+	TS      uint64 `db:"ts" json:"ts" type:"UInt64" timestamp:"true"`
+	Message string `db:"message" json:"message" type:"String"`
+}
+
+func TestSetScrollPage(t *testing.T) {
+	gateFields, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	accessFields, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(accessLog{}))
+	scroll := ScrollContext{SearchAfter: []interface{}{float64(1560124800123), "42"}, From: 20}
+
+	testData := []struct {
+		caseName    string
+		model       models.ModelInfo
+		body        string
+		from        int
+		searchAfter bool
+	}{
+		{
+			caseName:    "sorted hits with uuid tie-breaker",
+			model:       models.ModelInfo{DBName: "gate", DataFields: gateFields},
+			body:        `{"size":10,"sort":[{"ts":"desc"}]}`,
+			searchAfter: true,
+		},
+		{
+			caseName:    "unsorted hits with uuid tie-breaker",
+			model:       models.ModelInfo{DBName: "gate", DataFields: gateFields},
+			body:        `{"size":10}`,
+			searchAfter: true,
+		},
+		{
+			caseName: "sorted hits without uuid",
+			model:    models.ModelInfo{DBName: "access", DataFields: accessFields},
+			body:     `{"size":10,"sort":[{"ts":"desc"}]}`,
+			from:     20,
+		},
+		{
+			caseName: "unsorted hits without uuid",
+			model:    models.ModelInfo{DBName: "access", DataFields: accessFields},
+			body:     `{"size":10}`,
+			from:     20,
+		},
+	}
+
+	for _, test := range testData {
+		req, err := ParseElasticJSON([]byte(test.body), &test.model)
+		if err != nil {
+			t.Error("For", test.caseName, "\n unexpected error: ", err)
+			continue
+		}
+		req.SetScrollPage(scroll)
+		if req.From != test.from || (req.SearchAfter != nil) != test.searchAfter || req.HasTieBreaker() != test.searchAfter {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.from, test.searchAfter,
+				"\n got: ", req.From, req.SearchAfter, req.HasTieBreaker(),
+			)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package requests
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"kibouse/data/models"
+)
+
+// tableNameColumn contains name of the table of logs row, it is not returned in documents source.
+const tableNameColumn = "_table"
+
+// SourceFilter contains fields returned in source of hits, fields are matched by wildcard patterns
+// of their kibana names. All fields are returned if includes are not set.
+type SourceFilter struct {
+	Disabled bool
+	Includes []string
+	Excludes []string
+}
+
+// Match checks that field is returned in source of hits, patterns of nested fields include
+// the whole parent field (e.g. Map column).
+func (sf *SourceFilter) Match(name string) bool {
+	if sf == nil {
+		return true
+	}
+	if sf.Disabled {
+		return false
+	}
+	for _, pattern := range sf.Excludes {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	if len(sf.Includes) == 0 {
+		return true
+	}
+	for _, pattern := range sf.Includes {
+		if matched, _ := path.Match(pattern, name); matched || strings.HasPrefix(pattern, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptFieldPattern matches painless scripts returning value of the document field,
+// kibana scripted fields of other kinds could not be calculated.
+var scriptFieldPattern = regexp.MustCompile(`^\s*(?:return\s+)?doc\[\s*['"]([^'"]+)['"]\s*\]\.value\s*;?\s*$`)
+
+// fetchSource parses "_source" of elastic request: boolean, pattern, list of patterns or object
+// with includes and excludes. Source is not returned if stored_fields are requested without it.
+func (req *ElasticRequest) fetchSource() {
+	param, found := fetchJsonParamFromMap("_source", req.config)
+	if !found {
+		if _, stored := fetchJsonParamFromMap("stored_fields", req.config); stored {
+			req.Source.Disabled = true
+		}
+		return
+	}
+	switch source := param.(type) {
+	case bool:
+		req.Source.Disabled = !source
+	case string, []interface{}:
+		req.Source.Includes = fetchPatterns(source)
+	case map[string]interface{}:
+		// elasticsearch 5.x and 6.x also accept singular names
+		for _, name := range []string{"includes", "include"} {
+			if patterns, ok := source[name]; ok {
+				req.Source.Includes = fetchPatterns(patterns)
+			}
+		}
+		for _, name := range []string{"excludes", "exclude"} {
+			if patterns, ok := source[name]; ok {
+				req.Source.Excludes = fetchPatterns(patterns)
+			}
+		}
+	}
+}
+
+// fetchPatterns returns list of field patterns set by string or array of strings.
+func fetchPatterns(param interface{}) []string {
+	switch value := param.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		patterns := make([]string, 0, len(value))
+		for _, pattern := range value {
+			if pattern, ok := pattern.(string); ok {
+				patterns = append(patterns, pattern)
+			}
+		}
+		return patterns
+	}
+	return nil
+}
+
+// fetchScriptFields parses "script_fields" of elastic request, only scripts returning value
+// of the document field are supported.
+func (req *ElasticRequest) fetchScriptFields() {
+	fields, ok := req.config["script_fields"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, settings := range fields {
+		script, _ := fetchJsonParamFromInterface("script", settings)
+		source, ok := script.(string)
+		if !ok {
+			// "inline" is used by elasticsearch 5.x
+			for _, key := range []string{"source", "inline"} {
+				if value, found := fetchJsonParamFromInterface(key, script); found {
+					source, ok = value.(string)
+					break
+				}
+			}
+		}
+		match := scriptFieldPattern.FindStringSubmatch(source)
+		if !ok || match == nil {
+			log.Warnf("script of field '%s' is not supported: %v", name, script)
+			continue
+		}
+		if field, ok := req.tableInfo.GetField(correctFieldName(match[1])); ok {
+			req.ScriptFields[name] = field.CHName
+		}
+	}
+}
+
+// HitsColumns returns columns selected for hits: fields of returned source and fields used for
+// sorting, highlighting, docvalue and script fields. Nil is returned if all columns are required,
+// e.g. ids of models without uuid field are calculated from values of all columns.
+func (req *ElasticRequest) HitsColumns() []string {
+	uuid, ok := req.tableInfo.GetUuidField()
+	if !ok {
+		return nil
+	}
+	required := map[string]struct{}{uuid.CHName: {}}
+	names := make([]string, 0, len(req.SortingFields)+len(req.DocValueFields)+len(req.ScriptFields))
+	names = append(names, req.SortingFields...)
+	names = append(names, req.DocValueFields...)
+	for _, name := range req.ScriptFields {
+		names = append(names, name)
+	}
+	if req.Highlight != nil {
+		for name := range req.Highlight.Fields {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if column, ok := req.fieldColumn(name); ok {
+			required[column] = struct{}{}
+		}
+	}
+
+	columns := make([]string, 0, len(req.tableInfo.DataFields))
+	all := true
+	for name, field := range req.tableInfo.DataFields {
+		if name == tableNameColumn {
+			continue
+		}
+		if _, ok := required[name]; ok || req.Source.Match(field.KibanaName) {
+			columns = append(columns, name)
+		} else {
+			all = false
+		}
+	}
+	if all {
+		return nil
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// fieldColumn returns column containing values of the model field, sub-fields of Map and JSON
+// columns are read from their parent columns.
+func (req *ElasticRequest) fieldColumn(name string) (string, bool) {
+	field, ok := req.tableInfo.GetField(name)
+	if !ok {
+		return "", false
+	}
+	switch {
+	case field.MapColumn != "":
+		return field.MapColumn, true
+	case field.JSONColumn != "":
+		for column, parent := range req.tableInfo.DataFields {
+			if parent.IsJSONBlob() && parent.JSONSource() == field.JSONColumn {
+				return column, true
+			}
+		}
+		return "", false
+	}
+	return field.CHName, true
+}
+
+// SourceFields returns fields of model returned in source of hits by clickhouse names.
+func SourceFields(filter *SourceFilter, scheme *models.ModelInfo) []string {
+	fields := make([]string, 0, len(scheme.DataFields))
+	for name, field := range scheme.DataFields {
+		if name != tableNameColumn && filter.Match(field.KibanaName) {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
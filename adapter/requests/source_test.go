@@ -0,0 +1,149 @@
+package requests
+
+import (
+	"reflect"
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestParseSourceFiltering(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+
+	testData := []struct {
+		caseName  string
+		request   string
+		source    SourceFilter
+		docValues map[string]string
+		scripts   map[string]string
+		columns   []string
+	}{
+		{
+			caseName: "kibana 6.x discover request",
+			request: `{"size":500,"sort":[{"ts":{"order":"desc"}}],"_source":{"excludes":[]},"stored_fields":["*"],` +
+				`"script_fields":{},"docvalue_fields":[{"field":"ts","format":"date_time"}]}`,
+			source:    SourceFilter{Excludes: []string{}},
+			docValues: map[string]string{"ts": "date_time"},
+			scripts:   map[string]string{},
+		},
+		{
+			caseName:  "kibana 5.x discover request",
+			request:   `{"size":500,"_source":true,"stored_fields":["*"],"docvalue_fields":["ts"]}`,
+			docValues: map[string]string{},
+			scripts:   map[string]string{},
+		},
+		{
+			caseName:  "source includes and excludes with wildcards",
+			request:   `{"size":10,"sort":[{"ts":{"order":"desc"}}],"_source":{"includes":["pares*","message"],"excludes":["pares_xml"]}}`,
+			source:    SourceFilter{Includes: []string{"pares*", "message"}, Excludes: []string{"pares_xml"}},
+			docValues: map[string]string{},
+			scripts:   map[string]string{},
+			columns:   []string{"message", "pares", "pares_encoded", "ts", "uuid"},
+		},
+		{
+			caseName:  "list of source fields",
+			request:   `{"size":10,"_source":["hostname","status"]}`,
+			source:    SourceFilter{Includes: []string{"hostname", "status"}},
+			docValues: map[string]string{},
+			scripts:   map[string]string{},
+			columns:   []string{"hostname", "status", "uuid"},
+		},
+		{
+			caseName: "stored fields and script fields without source",
+			request: `{"size":10,"stored_fields":["_none_"],"script_fields":{"host":{"script":{"source":"doc['hostname'].value","lang":"painless"}},` +
+				`"length":{"script":{"source":"doc['message'].value.length()","lang":"painless"}},"line_no":{"script":{"inline":"return doc[\"line\"].value;"}}}}`,
+			source:    SourceFilter{Disabled: true},
+			docValues: map[string]string{},
+			scripts:   map[string]string{"host": "hostname", "line_no": "line"},
+			columns:   []string{"hostname", "line", "uuid"},
+		},
+		{
+			caseName:  "disabled source",
+			request:   `{"size":10,"_source":false,"docvalue_fields":[{"field":"ts","format":"epoch_millis"},"@hostname"]}`,
+			source:    SourceFilter{Disabled: true},
+			docValues: map[string]string{"ts": "epoch_millis"},
+			scripts:   map[string]string{},
+			columns:   []string{"hostname", "ts", "uuid"},
+		},
+	}
+
+	for _, test := range testData {
+		parsed, err := ParseElasticJSON([]byte(test.request), &gateModel)
+		columns := parsed.HitsColumns()
+		if err != nil ||
+			!reflect.DeepEqual(parsed.Source, test.source) ||
+			!reflect.DeepEqual(parsed.DocValueFormat, test.docValues) ||
+			!reflect.DeepEqual(parsed.ScriptFields, test.scripts) ||
+			!reflect.DeepEqual(columns, test.columns) {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.source, test.docValues, test.scripts, test.columns,
+				"\n got: ", parsed.Source, parsed.DocValueFormat, parsed.ScriptFields, columns, err,
+			)
+		}
+	}
+}
+
+func TestSourceFilterMatch(t *testing.T) {
+	filter := &SourceFilter{Includes: []string{"http.*", "labels.app", "message"}, Excludes: []string{"http.body"}}
+	testData := map[string]bool{
+		"http.status": true,
+		"http.body":   false,
+		"labels":      true,
+		"message":     true,
+		"hostname":    false,
+	}
+	for name, expected := range testData {
+		if matched := filter.Match(name); matched != expected {
+			t.Error("For", name, "\n expected: ", expected, "\n got: ", matched)
+		}
+	}
+}
+
+func TestHitsColumnsOfSubFields(t *testing.T) {
+	field := func(name string, chType string) *models.FieldProps {
+		return &models.FieldProps{CHField: models.CHField{CHName: name, CHType: chType}, KibanaName: name}
+	}
+	model := models.ModelInfo{
+		DBName: "events",
+		DataFields: map[string]*models.FieldProps{
+			"uuid":    field("uuid", "UUID"),
+			"ts":      field("ts", "DateTime64(3)"),
+			"message": field("message", "String"),
+			"labels":  field("labels", "Map(String, String)"),
+			"payload": field("payload", "String"),
+			"attrs":   field("attrs", "Object('json')"),
+		},
+	}
+	model.DataFields["uuid"].IsUUID = true
+	model.DataFields["payload"].JSONBlob = true
+
+	testData := []struct {
+		caseName string
+		request  string
+		columns  []string
+	}{
+		{
+			caseName: "sorting by map sub-field",
+			request:  `{"size":10,"_source":["message"],"sort":[{"labels.app":"asc"}]}`,
+			columns:  []string{"labels", "message", "uuid"},
+		},
+		{
+			caseName: "docvalue fields of json paths",
+			request:  `{"size":10,"_source":false,"docvalue_fields":["payload.user.id","attrs.env"]}`,
+			columns:  []string{"attrs", "payload", "uuid"},
+		},
+	}
+
+	for _, test := range testData {
+		parsed, err := ParseElasticJSON([]byte(test.request), &model)
+		columns := parsed.HitsColumns()
+		if err != nil || !reflect.DeepEqual(columns, test.columns) {
+			t.Error("For", test.caseName, "\n expected: ", test.columns, "\n got: ", columns, parsed.SortingFields, parsed.DocValueFields, err)
+		}
+	}
+}
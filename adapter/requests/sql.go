@@ -0,0 +1,600 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"kibouse/clickhouse"
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+// kinds of elasticsearch SQL statements supported by kibouse
+const (
+	SQLSelect     = "select"
+	SQLShowTables = "show_tables"
+	SQLDescribe   = "describe"
+)
+
+// DefaultSQLFetchSize is the default number of rows returned by single page of SQL query results.
+const DefaultSQLFetchSize = 1000
+
+// sqlMaxExecutionTime is the max number of seconds clickhouse executes query of SQL results page.
+const sqlMaxExecutionTime = 60
+
+// SQLRequest contains parameters of elasticsearch _sql request, query is not set if the next page
+// of results is requested by cursor.
+type SQLRequest struct {
+	Query       string        `json:"query"`
+	Cursor      string        `json:"cursor"`
+	FetchSize   int           `json:"fetch_size"`
+	PageTimeout string        `json:"page_timeout"`
+	Params      []interface{} `json:"params"`
+}
+
+// ParseSQLRequest parses body of _sql request, either query or cursor should be set.
+func ParseSQLRequest(body []byte) (*SQLRequest, error) {
+	req := SQLRequest{FetchSize: DefaultSQLFetchSize}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.Wrap(err, "cannot parse body of SQL request")
+	}
+	if req.Query == "" && req.Cursor == "" {
+		return nil, errors.New("either query or cursor should be specified")
+	}
+	if req.FetchSize <= 0 {
+		return nil, errors.New("fetch_size must be positive")
+	}
+	// parameters are set as plain values or objects with type and value
+	for i, param := range req.Params {
+		if typed, ok := param.(map[string]interface{}); ok {
+			req.Params[i] = typed["value"]
+		}
+	}
+	return &req, nil
+}
+
+// kinds of SQL tokens
+const (
+	sqlIdentifier = iota
+	sqlQuotedIdentifier
+	sqlString
+	sqlNumber
+	sqlSymbol
+	sqlParam
+)
+
+type sqlToken struct {
+	kind  int
+	value string
+	// token is separated from the previous one by spaces
+	spaced bool
+}
+
+// sqlSymbols are operators and punctuation allowed in queries, longer symbols go first.
+var sqlSymbols = []string{"<=", ">=", "<>", "!=", "=", "<", ">", "+", "-", "*", "/", "%", ",", "(", ")", ";"}
+
+// tokenizeSQL splits query into tokens, comments are skipped. Strings and quoted identifiers
+// are unquoted, quotes are escaped by doubling them.
+func tokenizeSQL(query string) ([]sqlToken, error) {
+	tokens := make([]sqlToken, 0)
+	spaced := true
+	for pos := 0; pos < len(query); {
+		c := rune(query[pos])
+		switch {
+		case unicode.IsSpace(c):
+			spaced = true
+			pos++
+			continue
+		case strings.HasPrefix(query[pos:], "--"):
+			end := strings.IndexByte(query[pos:], '\n')
+			if end == -1 {
+				end = len(query) - pos
+			}
+			spaced = true
+			pos += end
+			continue
+		case strings.HasPrefix(query[pos:], "/*"):
+			end := strings.Index(query[pos+2:], "*/")
+			if end == -1 {
+				return nil, errors.New("unterminated comment in SQL query")
+			}
+			spaced = true
+			pos += end + 4
+			continue
+		}
+
+		token := sqlToken{spaced: spaced}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			value, end, ok := unquoteSQL(query, pos)
+			if !ok {
+				return nil, errors.Errorf("unterminated %c in SQL query", c)
+			}
+			token.kind, token.value = sqlQuotedIdentifier, value
+			if c == '\'' {
+				token.kind = sqlString
+			}
+			pos = end
+		case c == '?':
+			token.kind, token.value = sqlParam, "?"
+			pos++
+		case c >= '0' && c <= '9' || c == '.' && pos+1 < len(query) && query[pos+1] >= '0' && query[pos+1] <= '9':
+			number := sqlNumberPattern.FindString(query[pos:])
+			token.kind, token.value = sqlNumber, number
+			pos += len(number)
+		case c == '_' || c == '@' || c > unicode.MaxASCII || unicode.IsLetter(c):
+			end := pos
+			for end < len(query) && isSQLIdentifierByte(query[end]) {
+				end++
+			}
+			token.kind, token.value = sqlIdentifier, query[pos:end]
+			pos = end
+		default:
+			token.kind = sqlSymbol
+			for _, symbol := range sqlSymbols {
+				if strings.HasPrefix(query[pos:], symbol) {
+					token.value = symbol
+					break
+				}
+			}
+			if token.value == "" {
+				return nil, errors.Errorf("unexpected symbol %c in SQL query", c)
+			}
+			pos += len(token.value)
+		}
+		tokens = append(tokens, token)
+		spaced = false
+	}
+	return tokens, nil
+}
+
+var sqlNumberPattern = regexp.MustCompile(`^(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?`)
+
+func isSQLIdentifierByte(b byte) bool {
+	return b == '_' || b == '@' || b == '.' || b >= 0x80 || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// unquoteSQL returns value of string or quoted identifier starting at pos and position following it.
+func unquoteSQL(query string, pos int) (string, int, bool) {
+	quote := query[pos]
+	value := strings.Builder{}
+	for i := pos + 1; i < len(query); i++ {
+		if query[i] != quote {
+			value.WriteByte(query[i])
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			value.WriteByte(quote)
+			i++
+			continue
+		}
+		return value.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+// sqlKeywords are keywords of elasticsearch SQL passed to clickhouse as is.
+var sqlKeywords = map[string]struct{}{
+	"SELECT": {}, "FROM": {}, "WHERE": {}, "AND": {}, "OR": {}, "NOT": {}, "AS": {}, "GROUP": {}, "BY": {},
+	"ORDER": {}, "ASC": {}, "DESC": {}, "LIMIT": {}, "HAVING": {}, "DISTINCT": {}, "IN": {}, "IS": {}, "NULL": {},
+	"LIKE": {}, "BETWEEN": {}, "CASE": {}, "WHEN": {}, "THEN": {}, "ELSE": {}, "END": {}, "TRUE": {}, "FALSE": {},
+	"NULLS": {}, "FIRST": {}, "LAST": {}, "ALL": {},
+}
+
+// sqlForbidden are keywords of clickhouse statements and clauses which could modify data, change settings
+// or read data other than logs tables, queries containing them are rejected.
+var sqlForbidden = map[string]struct{}{
+	"INSERT": {}, "UPDATE": {}, "DELETE": {}, "ALTER": {}, "DROP": {}, "CREATE": {}, "RENAME": {}, "ATTACH": {},
+	"DETACH": {}, "OPTIMIZE": {}, "KILL": {}, "GRANT": {}, "REVOKE": {}, "SYSTEM": {}, "SET": {}, "USE": {},
+	"EXCHANGE": {}, "SETTINGS": {}, "FORMAT": {}, "INTO": {}, "OUTFILE": {}, "UNION": {}, "JOIN": {},
+	"ARRAY": {}, "INTERSECT": {}, "EXCEPT": {}, "WITH": {}, "SAMPLE": {}, "PREWHERE": {}, "FINAL": {}, "GLOBAL": {},
+}
+
+// sqlFunctions maps functions of elasticsearch SQL to clickhouse ones, other functions are not allowed.
+var sqlFunctions = map[string]string{
+	// aggregations
+	"AVG": "avg", "COUNT": "count", "SUM": "sum", "MIN": "min", "MAX": "max",
+	"STDDEV_POP": "stddevPop", "VAR_POP": "varPop",
+	// math
+	"ABS": "abs", "CEIL": "ceil", "CEILING": "ceil", "FLOOR": "floor", "ROUND": "round", "TRUNCATE": "trunc",
+	"POWER": "pow", "SQRT": "sqrt", "EXP": "exp", "LOG": "log", "LOG10": "log10", "MOD": "modulo", "SIGN": "sign",
+	"PI": "pi",
+	// strings
+	"LENGTH": "lengthUTF8", "CHAR_LENGTH": "lengthUTF8", "LCASE": "lower", "LOWER": "lower", "UCASE": "upper",
+	"UPPER": "upper", "CONCAT": "concat", "SUBSTRING": "substringUTF8", "TRIM": "trimBoth", "LTRIM": "trimLeft",
+	"RTRIM": "trimRight", "REPLACE": "replaceAll", "STARTS_WITH": "startsWith",
+	// dates
+	"YEAR": "toYear", "QUARTER": "toQuarter", "MONTH": "toMonth", "MONTH_OF_YEAR": "toMonth",
+	"DAY": "toDayOfMonth", "DAY_OF_MONTH": "toDayOfMonth", "DOM": "toDayOfMonth", "DAY_OF_YEAR": "toDayOfYear",
+	"DOY": "toDayOfYear", "WEEK": "toISOWeek", "WEEK_OF_YEAR": "toISOWeek", "HOUR": "toHour",
+	"HOUR_OF_DAY": "toHour", "MINUTE": "toMinute", "MINUTE_OF_HOUR": "toMinute", "SECOND": "toSecond",
+	"SECOND_OF_MINUTE": "toSecond", "NOW": "now", "CURRENT_TIMESTAMP": "now", "TODAY": "today",
+	"CURDATE": "today", "CURRENT_DATE": "today", "DATE_TRUNC": "dateTrunc",
+	// conditions and conversions
+	"COALESCE": "coalesce", "IFNULL": "ifNull", "NULLIF": "nullIf", "GREATEST": "greatest", "LEAST": "least",
+	"IIF": "if", "CAST": "CAST",
+}
+
+// sqlCastTypes maps elasticsearch SQL types of CAST function to clickhouse types.
+var sqlCastTypes = map[string]string{
+	"BYTE": "Int8", "TINYINT": "Int8", "SHORT": "Int16", "SMALLINT": "Int16", "INTEGER": "Int32", "INT": "Int32",
+	"LONG": "Int64", "BIGINT": "Int64", "DOUBLE": "Float64", "FLOAT": "Float32", "REAL": "Float32",
+	"KEYWORD": "String", "TEXT": "String", "VARCHAR": "String", "STRING": "String", "BOOLEAN": "UInt8",
+	"DATETIME": "DateTime64(3)", "TIMESTAMP": "DateTime64(3)", "DATE": "Date",
+}
+
+// SQLStatement is the parsed elasticsearch SQL statement. Select statements are restricted to single
+// query of single index without subqueries, unions and joins.
+type SQLStatement struct {
+	Kind string
+	// index of select and describe statements, tables pattern of show tables statement
+	Index string
+	// limit of selected rows, -1 if not set
+	Limit int
+
+	tokens []sqlToken
+	// positions of index name tokens in the select statement
+	indexStart int
+	indexEnd   int
+	alias      string
+}
+
+// ParseSQL parses and validates elasticsearch SQL statement, question marks are replaced by parameters.
+func ParseSQL(query string, params []interface{}) (*SQLStatement, error) {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(tokens); n > 0 && tokens[n-1].kind == sqlSymbol && tokens[n-1].value == ";" {
+		tokens = tokens[:n-1]
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("SQL query is empty")
+	}
+	if tokens, err = bindSQLParams(tokens, params); err != nil {
+		return nil, err
+	}
+
+	statement := &SQLStatement{Limit: -1, tokens: tokens}
+	switch keyword(tokens[0]) {
+	case "SELECT":
+		statement.Kind = SQLSelect
+		return statement, statement.validateSelect()
+	case "SHOW":
+		if len(tokens) > 1 && keyword(tokens[1]) == "TABLES" {
+			statement.Kind = SQLShowTables
+			return statement, statement.parseShowTables(tokens[2:])
+		}
+		if len(tokens) > 2 && keyword(tokens[1]) == "COLUMNS" && (keyword(tokens[2]) == "FROM" || keyword(tokens[2]) == "IN") {
+			statement.Kind = SQLDescribe
+			return statement, statement.parseDescribe(tokens[3:])
+		}
+	case "DESCRIBE", "DESC":
+		statement.Kind = SQLDescribe
+		return statement, statement.parseDescribe(tokens[1:])
+	}
+	return nil, errors.New("only SELECT, SHOW TABLES, SHOW COLUMNS and DESCRIBE statements are supported")
+}
+
+// keyword returns upper cased name of not quoted identifier.
+func keyword(token sqlToken) string {
+	if token.kind != sqlIdentifier {
+		return ""
+	}
+	return strings.ToUpper(token.value)
+}
+
+// bindSQLParams replaces question marks of query by literals of parameters.
+func bindSQLParams(tokens []sqlToken, params []interface{}) ([]sqlToken, error) {
+	next := 0
+	for i := range tokens {
+		if tokens[i].kind != sqlParam {
+			continue
+		}
+		if next >= len(params) {
+			return nil, errors.New("not enough parameters for SQL query")
+		}
+		switch value := params[next].(type) {
+		case string:
+			tokens[i].kind, tokens[i].value = sqlString, value
+		case float64:
+			tokens[i].kind, tokens[i].value = sqlNumber, strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			tokens[i].kind, tokens[i].value = sqlIdentifier, strings.ToUpper(strconv.FormatBool(value))
+		case nil:
+			tokens[i].kind, tokens[i].value = sqlIdentifier, "NULL"
+		default:
+			return nil, errors.Errorf("unsupported parameter of SQL query: %v", value)
+		}
+		next++
+	}
+	return tokens, nil
+}
+
+// parseShowTables parses optional LIKE pattern of tables, SQL wildcards are converted to index pattern ones.
+func (s *SQLStatement) parseShowTables(tokens []sqlToken) error {
+	s.Index = "*"
+	if len(tokens) == 0 {
+		return nil
+	}
+	if len(tokens) != 2 || keyword(tokens[0]) != "LIKE" || tokens[1].kind != sqlString {
+		return errors.New("SHOW TABLES supports only LIKE pattern")
+	}
+	s.Index = strings.NewReplacer("%", "*", "_", "?").Replace(tokens[1].value)
+	return nil
+}
+
+func (s *SQLStatement) parseDescribe(tokens []sqlToken) error {
+	index, end := sqlIndexName(tokens, 0)
+	if index == "" || end != len(tokens) {
+		return errors.New("single index should be described")
+	}
+	s.Index = index
+	return nil
+}
+
+// sqlIndexName returns index name starting at pos and position following it, unquoted index patterns
+// are split into several tokens by wildcards and dashes.
+func sqlIndexName(tokens []sqlToken, pos int) (string, int) {
+	if pos >= len(tokens) {
+		return "", pos
+	}
+	switch tokens[pos].kind {
+	case sqlQuotedIdentifier, sqlString:
+		return tokens[pos].value, pos + 1
+	case sqlSymbol, sqlParam:
+		if tokens[pos].value != "*" {
+			return "", pos
+		}
+	}
+	name := strings.Builder{}
+	name.WriteString(tokens[pos].value)
+	end := pos + 1
+	for ; end < len(tokens) && !tokens[end].spaced; end++ {
+		token := tokens[end]
+		if token.kind != sqlIdentifier && token.kind != sqlNumber && token.value != "*" && token.value != "-" {
+			break
+		}
+		name.WriteString(token.value)
+	}
+	return name.String(), end
+}
+
+// validateSelect checks that select statement uses allowed keywords and functions only and reads single index,
+// the index and the limit of selected rows are taken from the statement.
+func (s *SQLStatement) validateSelect() error {
+	depth := 0
+	for i := 0; i < len(s.tokens); i++ {
+		token := s.tokens[i]
+		if token.kind != sqlSymbol && i+1 < len(s.tokens) && s.tokens[i+1].kind == sqlSymbol && s.tokens[i+1].value == "(" {
+			// functions are called by unquoted names only, so quoting doesn't bypass the list of supported ones
+			name := keyword(token)
+			_, isKeyword := sqlKeywords[name]
+			_, isFunction := sqlFunctions[name]
+			if !isKeyword && !isFunction {
+				return errors.Errorf("function %s is not supported", token.value)
+			}
+		}
+		switch token.kind {
+		case sqlSymbol:
+			switch token.value {
+			case "(":
+				depth++
+			case ")":
+				if depth--; depth < 0 {
+					return errors.New("unbalanced parentheses in SQL query")
+				}
+			case ";":
+				return errors.New("multiple SQL statements are not allowed")
+			}
+			continue
+		case sqlIdentifier:
+		default:
+			continue
+		}
+
+		name := keyword(token)
+		if _, ok := sqlForbidden[name]; ok {
+			return errors.Errorf("%s is not allowed in SQL query", name)
+		}
+		if name == "SELECT" && i > 0 {
+			return errors.New("subqueries are not supported")
+		}
+		// clickhouse treats identifier following IN as a table, the list of values is allowed only
+		if name == "IN" && (i+1 >= len(s.tokens) || s.tokens[i+1].kind != sqlSymbol || s.tokens[i+1].value != "(") {
+			return errors.New("IN should be followed by the list of values in parentheses")
+		}
+		if name == "FROM" && depth == 0 {
+			if s.indexEnd > 0 {
+				return errors.New("only single index could be queried")
+			}
+			if s.Index, s.indexEnd = sqlIndexName(s.tokens, i+1); s.Index == "" {
+				return errors.New("index name is expected after FROM")
+			}
+			s.indexStart = i + 1
+			s.parseAlias()
+			if s.indexEnd < len(s.tokens) && s.tokens[s.indexEnd].kind == sqlSymbol && s.tokens[s.indexEnd].value == "," {
+				return errors.New("only single index could be queried")
+			}
+			i = s.indexEnd - 1
+		}
+	}
+	if depth != 0 {
+		return errors.New("unbalanced parentheses in SQL query")
+	}
+
+	// limit of rows is applied by pages of results
+	if n := len(s.tokens); n > 2 && keyword(s.tokens[n-2]) == "LIMIT" && s.tokens[n-1].kind == sqlNumber {
+		limit, err := strconv.Atoi(s.tokens[n-1].value)
+		if err != nil || limit < 0 {
+			return errors.New("incorrect limit of SQL query: " + s.tokens[n-1].value)
+		}
+		s.Limit, s.tokens = limit, s.tokens[:n-2]
+	}
+	return nil
+}
+
+// parseAlias takes alias of the index following its name, columns could be qualified by the alias.
+func (s *SQLStatement) parseAlias() {
+	pos := s.indexEnd
+	if pos < len(s.tokens) && keyword(s.tokens[pos]) == "AS" {
+		pos++
+	}
+	if pos >= len(s.tokens) || s.tokens[pos].kind != sqlIdentifier && s.tokens[pos].kind != sqlQuotedIdentifier {
+		return
+	}
+	if _, ok := sqlKeywords[keyword(s.tokens[pos])]; ok {
+		return
+	}
+	if _, ok := sqlForbidden[keyword(s.tokens[pos])]; ok {
+		return
+	}
+	s.alias = s.tokens[pos].value
+	s.indexEnd = pos + 1
+}
+
+// Query returns clickhouse query of select statement reading rows of index from the source,
+// fields are referenced by their kibana names and rewritten to clickhouse columns of the model.
+func (s *SQLStatement) Query(scheme *models.ModelInfo, source string) string {
+	parts := make([]string, 0, len(s.tokens))
+	depth := 0
+	for i := 0; i < len(s.tokens); i++ {
+		if i == s.indexStart && s.indexEnd > 0 {
+			parts = append(parts, "("+source+")")
+			i = s.indexEnd - 1
+			continue
+		}
+		token := s.tokens[i]
+		afterAs := i > 0 && keyword(s.tokens[i-1]) == "AS"
+		switch token.kind {
+		case sqlString:
+			parts = append(parts, models.QuoteString(token.value))
+		case sqlNumber:
+			parts = append(parts, token.value)
+		case sqlSymbol:
+			switch token.value {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			parts = append(parts, token.value)
+		case sqlQuotedIdentifier:
+			if column, ok := sqlColumn(scheme, s.unqualified(token.value)); ok && !afterAs {
+				parts = append(parts, column)
+			} else {
+				parts = append(parts, quoteSQLIdentifier(token.value))
+			}
+		case sqlIdentifier:
+			name := strings.ToUpper(token.value)
+			if castType, ok := sqlCastTypes[name]; ok && afterAs && depth > 0 {
+				parts = append(parts, castType)
+				continue
+			}
+			if _, ok := sqlKeywords[name]; ok {
+				parts = append(parts, name)
+				continue
+			}
+			if function, ok := sqlFunctions[name]; ok && i+1 < len(s.tokens) && s.tokens[i+1].value == "(" {
+				parts = append(parts, function)
+				continue
+			}
+			if column, ok := sqlColumn(scheme, s.unqualified(token.value)); ok && !afterAs {
+				parts = append(parts, column)
+			} else {
+				parts = append(parts, quoteSQLIdentifier(token.value))
+			}
+		}
+	}
+
+	query := strings.Builder{}
+	for i, part := range parts {
+		// function arguments are not separated from function names
+		if i > 0 && part != ")" && part != "," && parts[i-1] != "(" && !(part == "(" && isSQLName(parts[i-1])) {
+			query.WriteString(" ")
+		}
+		query.WriteString(part)
+	}
+	return query.String()
+}
+
+var sqlNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func isSQLName(part string) bool {
+	if _, ok := sqlKeywords[part]; ok {
+		return false
+	}
+	return sqlNamePattern.MatchString(part)
+}
+
+// unqualified removes alias of the index from column name.
+func (s *SQLStatement) unqualified(name string) string {
+	if s.alias != "" {
+		return strings.TrimPrefix(name, s.alias+".")
+	}
+	return name
+}
+
+// sqlColumn returns clickhouse expression of the model field referenced by its kibana or clickhouse name.
+func sqlColumn(scheme *models.ModelInfo, name string) (string, bool) {
+	if scheme == nil || name == tableNameColumn {
+		return "", false
+	}
+	for _, field := range scheme.DataFields {
+		if field.KibanaName == name {
+			return quoteSQLColumn(field.CHName), true
+		}
+	}
+	if field, ok := scheme.GetField(name); ok {
+		return quoteSQLColumn(field.CHName), true
+	}
+	return "", false
+}
+
+// quoteSQLColumn quotes name of clickhouse column, expressions of sub-fields are not quoted.
+func quoteSQLColumn(name string) string {
+	if sqlNamePattern.MatchString(name) {
+		return quoteSQLIdentifier(name)
+	}
+	return name
+}
+
+func quoteSQLIdentifier(name string) string {
+	return "`" + strings.Replace(strings.Replace(name, `\`, `\\`, -1), "`", "\\`", -1) + "`"
+}
+
+// SQLSource returns clickhouse query selecting columns of the model from tables of the provider index,
+// it is used as the source of rows of SQL select statement.
+func SQLSource(provider db.DataProvider) string {
+	scheme := provider.DataScheme()
+	columns := make([]string, 0, len(scheme.DataFields))
+	for name := range scheme.DataFields {
+		if name != tableNameColumn {
+			columns = append(columns, quoteSQLIdentifier(name))
+		}
+	}
+	sort.Strings(columns)
+	return clickhouse.NewRequestTpl(provider).What(strings.Join(columns, ", ")).Build()
+}
+
+// SQLPageQuery returns query of the page of results starting at offset, rows beyond the limit
+// of statement are not selected. Query is executed in read-only mode with limited execution time.
+func SQLPageQuery(query string, limit int, offset int, size int) string {
+	if limit >= 0 && offset+size > limit {
+		size = limit - offset
+	}
+	if size < 0 {
+		size = 0
+	}
+	return fmt.Sprintf(
+		"%s LIMIT %d OFFSET %d SETTINGS readonly = 1, max_execution_time = %d",
+		query, size, offset, sqlMaxExecutionTime,
+	)
+}
@@ -0,0 +1,130 @@
+package requests
+
+import (
+	"reflect"
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestSQLQuery(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+
+	testData := []struct {
+		caseName string
+		query    string
+		params   []interface{}
+		index    string
+		limit    int
+		expected string
+	}{
+		{
+			caseName: "fields and functions",
+			query:    `SELECT hostname, COUNT(*) AS "count" FROM gate WHERE type = 'error' GROUP BY hostname ORDER BY 2 DESC LIMIT 10`,
+			index:    "gate",
+			limit:    10,
+			expected: "SELECT `hostname`, count(*) AS `count` FROM (source) WHERE `type` = 'error' GROUP BY `hostname` ORDER BY 2 DESC",
+		},
+		{
+			caseName: "quoted index pattern with alias",
+			query:    `select g.message, "file" from "gate*" g where g.pid in (1, 2) and message like '%time''out%';`,
+			index:    "gate*",
+			limit:    -1,
+			expected: "SELECT `message`, `file` FROM (source) WHERE `pid` IN (1, 2) AND `message` LIKE '%time\\'out%'",
+		},
+		{
+			caseName: "unquoted index pattern and parameters",
+			query:    `SELECT YEAR(day), CAST(line AS LONG) FROM logs-gate-* WHERE hostname = ? AND pid > ? -- comment`,
+			params:   []interface{}{"gate-1", float64(100)},
+			index:    "logs-gate-*",
+			limit:    -1,
+			expected: "SELECT toYear(`day`), CAST(`line` AS Int64) FROM (source) WHERE `hostname` = 'gate-1' AND `pid` > 100",
+		},
+		{
+			caseName: "unknown identifiers are quoted",
+			query:    "SELECT `x``y` FROM gate ORDER BY unknown",
+			index:    "gate",
+			limit:    -1,
+			expected: "SELECT `x\\`y` FROM (source) ORDER BY `unknown`",
+		},
+	}
+
+	for _, test := range testData {
+		statement, err := ParseSQL(test.query, test.params)
+		if err != nil {
+			t.Error("For", test.caseName, "\n unexpected error: ", err)
+			continue
+		}
+		query := statement.Query(&gateModel, "source")
+		if query != test.expected || statement.Index != test.index || statement.Limit != test.limit {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.expected, test.index, test.limit,
+				"\n got: ", query, statement.Index, statement.Limit,
+			)
+		}
+	}
+}
+
+func TestParseSQLStatements(t *testing.T) {
+	testData := []struct {
+		caseName string
+		query    string
+		kind     string
+		index    string
+		valid    bool
+	}{
+		{caseName: "show tables", query: "SHOW TABLES", kind: SQLShowTables, index: "*", valid: true},
+		{caseName: "show tables like", query: "SHOW TABLES LIKE 'logs_g%'", kind: SQLShowTables, index: "logs?g*", valid: true},
+		{caseName: "describe", query: `DESCRIBE "logs-*"`, kind: SQLDescribe, index: "logs-*", valid: true},
+		{caseName: "show columns", query: "SHOW COLUMNS IN gate", kind: SQLDescribe, index: "gate", valid: true},
+		{caseName: "select without index", query: "SELECT 1", kind: SQLSelect, valid: true},
+		{caseName: "data modification", query: "DROP TABLE gate"},
+		{caseName: "multiple statements", query: "SELECT 1; DROP TABLE gate"},
+		{caseName: "subquery", query: "SELECT * FROM (SELECT * FROM system.users)"},
+		{caseName: "table function", query: "SELECT * FROM gate WHERE pid IN (SELECT pid FROM url('http://host', CSV))"},
+		{caseName: "in list", query: "SELECT * FROM gate WHERE pid IN (1, 2)", kind: SQLSelect, index: "gate", valid: true},
+		{caseName: "in table", query: "SELECT * FROM gate WHERE message IN kibana"},
+		{caseName: "in quoted table", query: "SELECT * FROM gate WHERE message NOT IN `kibana`"},
+		{caseName: "not allowed function", query: "SELECT file('/etc/passwd') FROM gate"},
+		{caseName: "double quoted function", query: `SELECT "sleepEachRow"(3) FROM logs_gate`},
+		{caseName: "backquoted function", query: "SELECT `file`('x') FROM logs_gate"},
+		{caseName: "quoted function in condition", query: "SELECT * FROM gate WHERE `url`('http://host', CSV) = 1"},
+		{caseName: "settings", query: "SELECT * FROM gate SETTINGS max_threads = 100"},
+		{caseName: "output format", query: "SELECT * FROM gate FORMAT TSV"},
+		{caseName: "join", query: "SELECT * FROM gate JOIN other USING (pid)"},
+		{caseName: "several indices", query: "SELECT * FROM gate, other"},
+		{caseName: "unbalanced parentheses", query: "SELECT count(* FROM gate"},
+		{caseName: "unterminated string", query: "SELECT * FROM gate WHERE type = 'error"},
+	}
+
+	for _, test := range testData {
+		statement, err := ParseSQL(test.query, nil)
+		if test.valid != (err == nil) || err == nil && (statement.Kind != test.kind || statement.Index != test.index) {
+			t.Error("For", test.caseName, "\n expected: ", test.valid, test.kind, test.index, "\n got: ", statement, err)
+		}
+	}
+}
+
+func TestSQLPageQuery(t *testing.T) {
+	testData := []struct {
+		caseName string
+		limit    int
+		offset   int
+		expected string
+	}{
+		{caseName: "without limit", limit: -1, offset: 20, expected: "SELECT 1 LIMIT 11 OFFSET 20 SETTINGS readonly = 1, max_execution_time = 60"},
+		{caseName: "page within limit", limit: 100, offset: 20, expected: "SELECT 1 LIMIT 11 OFFSET 20 SETTINGS readonly = 1, max_execution_time = 60"},
+		{caseName: "last page of limit", limit: 25, offset: 20, expected: "SELECT 1 LIMIT 5 OFFSET 20 SETTINGS readonly = 1, max_execution_time = 60"},
+	}
+
+	for _, test := range testData {
+		if got := SQLPageQuery("SELECT 1", test.limit, test.offset, 11); got != test.expected {
+			t.Error("For", test.caseName, "\n expected: ", test.expected, "\n got: ", got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package requests
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSQLPageTimeout is the default time SQL cursor is kept between requests of its pages.
+const DefaultSQLPageTimeout = 45 * time.Second
+
+// SQLCursor contains clickhouse query of SQL statement, limit of its rows and position of the next page of results.
+type SQLCursor struct {
+	Query     string
+	Limit     int
+	Offset    int
+	FetchSize int
+	timeout   time.Duration
+	expires   time.Time
+}
+
+var sqlCursors = map[string]*SQLCursor{}
+var sqlCursorsMutex = &sync.Mutex{}
+
+// OpenSQLCursor keeps position of the next page of query results for timeout and returns id of the cursor,
+// expired cursors are removed.
+func OpenSQLCursor(cursor SQLCursor, timeout time.Duration) (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", errors.Wrap(err, "cannot generate SQL cursor id")
+	}
+	id := base64.RawURLEncoding.EncodeToString(bytes)
+
+	sqlCursorsMutex.Lock()
+	defer sqlCursorsMutex.Unlock()
+	removeExpiredSQLCursors(time.Now())
+	cursor.timeout = timeout
+	cursor.expires = time.Now().Add(timeout)
+	sqlCursors[id] = &cursor
+	return id, nil
+}
+
+// GetSQLCursor returns cursor by its id, cursor is prolonged for its page timeout.
+func GetSQLCursor(id string) (SQLCursor, bool) {
+	sqlCursorsMutex.Lock()
+	defer sqlCursorsMutex.Unlock()
+	removeExpiredSQLCursors(time.Now())
+	cursor, ok := sqlCursors[id]
+	if !ok {
+		return SQLCursor{}, false
+	}
+	cursor.expires = time.Now().Add(cursor.timeout)
+	return *cursor, true
+}
+
+// MoveSQLCursor moves cursor to the page following returned rows.
+func MoveSQLCursor(id string, rows int) {
+	sqlCursorsMutex.Lock()
+	defer sqlCursorsMutex.Unlock()
+	if cursor, ok := sqlCursors[id]; ok {
+		cursor.Offset += rows
+	}
+}
+
+// CloseSQLCursor removes cursor by its id, false is returned if cursor is not found.
+func CloseSQLCursor(id string) bool {
+	sqlCursorsMutex.Lock()
+	defer sqlCursorsMutex.Unlock()
+	removeExpiredSQLCursors(time.Now())
+	if _, ok := sqlCursors[id]; !ok {
+		return false
+	}
+	delete(sqlCursors, id)
+	return true
+}
+
+func removeExpiredSQLCursors(now time.Time) {
+	for id, cursor := range sqlCursors {
+		if cursor.expires.Before(now) {
+			delete(sqlCursors, id)
+		}
+	}
+}
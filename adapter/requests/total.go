@@ -0,0 +1,133 @@
+package requests
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"kibouse/adapter/requests/aggregations"
+	"kibouse/clickhouse"
+	"kibouse/db"
+)
+
+const (
+	// TrackTotalHitsDisabled disables counting of documents matching search request.
+	TrackTotalHitsDisabled = -1
+
+	// DefaultTotalHitsCacheTTL is the default time number of matching documents is cached for.
+	DefaultTotalHitsCacheTTL = 10 * time.Second
+
+	// relations of reported number of found documents to the real one
+	TotalHitsEqual          = "eq"
+	TotalHitsGreaterOrEqual = "gte"
+)
+
+// cachedCount contains number of documents matching filter of the cached counting request.
+type cachedCount struct {
+	count   int
+	expires time.Time
+}
+
+var totalHitsCache = map[string]cachedCount{}
+var totalHitsCacheTTL = DefaultTotalHitsCacheTTL
+var totalHitsCacheMutex = &sync.Mutex{}
+
+// SetTotalHitsCacheTTL sets time number of documents matching identical filters is reused
+// without requesting clickhouse, zero ttl disables caching.
+func SetTotalHitsCacheTTL(ttl time.Duration) {
+	totalHitsCacheMutex.Lock()
+	totalHitsCacheTTL = ttl
+	totalHitsCache = map[string]cachedCount{}
+	totalHitsCacheMutex.Unlock()
+}
+
+// hitsCount is the result of counting request.
+type hitsCount struct {
+	Count uint64 `db:"total"`
+}
+
+// TotalHits returns number of documents matching the search request according to its track_total_hits
+// and relation of the number to the real one, relation is empty if counting is disabled. Doc count of the
+// date histogram aggregation is used if the histogram contains all found documents.
+func (req *ElasticRequest) TotalHits(conn db.DataProvider, aggregated *aggregations.BucketAggregationData) (int, string, error) {
+	if req.TrackTotalHits == TrackTotalHitsDisabled {
+		return 0, "", nil
+	}
+
+	var total int
+	if histogram, ok := req.Aggregations.(*aggregations.DateHistogram); ok && aggregated != nil && histogram.CountsAllDocuments() {
+		total = int(aggregated.DocCount())
+	} else {
+		limit := 0
+		if req.TrackTotalHits > 0 {
+			limit = req.TrackTotalHits
+		}
+		count, err := req.CountHits(conn, limit)
+		if err != nil {
+			return 0, "", err
+		}
+		total = count
+	}
+
+	if req.TrackTotalHits > 0 && total > req.TrackTotalHits {
+		return req.TrackTotalHits, TotalHitsGreaterOrEqual, nil
+	}
+	return total, TotalHitsEqual, nil
+}
+
+// CountHits returns number of documents matching query of the search request, counting is stopped
+// after the limit is exceeded (exact number is returned if limit is not set). Numbers are cached per
+// data table and query for a short time.
+func (req *ElasticRequest) CountHits(conn db.DataProvider, limit int) (int, error) {
+	request := clickhouse.NewRequestTpl(conn)
+	request.What("count() as total")
+	if condition := req.QueryCondition(); condition != "" {
+		request.Where(condition)
+	}
+	if limit > 0 {
+		// one more document is selected for checking that the limit is exceeded
+		request.What("1").Limit(limit + 1)
+		request = db.NewRequest("("+request.Build()+")", "count() as total")
+	}
+	query := request.Build()
+
+	if count, ok := cachedTotalHits(query, time.Now()); ok {
+		return count, nil
+	}
+
+	rows := make([]hitsCount, 0, 1)
+	if err := conn.CreateDataSelector(request)(&rows); err != nil {
+		return 0, errors.Wrap(err, "SQL failed to execute while counting documents: "+query)
+	}
+	count := 0
+	if len(rows) > 0 {
+		count = int(rows[0].Count)
+	}
+	cacheTotalHits(query, count, time.Now())
+	return count, nil
+}
+
+func cachedTotalHits(query string, now time.Time) (int, bool) {
+	totalHitsCacheMutex.Lock()
+	defer totalHitsCacheMutex.Unlock()
+	cached, ok := totalHitsCache[query]
+	if !ok || !cached.expires.After(now) {
+		return 0, false
+	}
+	return cached.count, true
+}
+
+func cacheTotalHits(query string, count int, now time.Time) {
+	totalHitsCacheMutex.Lock()
+	defer totalHitsCacheMutex.Unlock()
+	if totalHitsCacheTTL <= 0 {
+		return
+	}
+	for key, cached := range totalHitsCache {
+		if !cached.expires.After(now) {
+			delete(totalHitsCache, key)
+		}
+	}
+	totalHitsCache[query] = cachedCount{count: count, expires: now.Add(totalHitsCacheTTL)}
+}
@@ -0,0 +1,131 @@
+package requests
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"kibouse/adapter/requests/aggregations"
+	"kibouse/data/models"
+)
+
+func TestTotalHits(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+	defer SetTotalHitsCacheTTL(DefaultTotalHitsCacheTTL)
+	SetTotalHitsCacheTTL(DefaultTotalHitsCacheTTL)
+
+	testData := []struct {
+		caseName string
+		request  string
+		rows     []json.RawMessage
+		total    int
+		relation string
+		queries  int
+		query    string
+	}{
+		{
+			caseName: "exact total by default",
+			request:  `{"size":10,"query":{"term":{"type":"error"}}}`,
+			rows:     []json.RawMessage{json.RawMessage(`[{"Count":12345}]`)},
+			total:    12345,
+			relation: TotalHitsEqual,
+			queries:  1,
+		},
+		{
+			caseName: "total limited by track_total_hits",
+			request:  `{"size":10,"track_total_hits":10000,"query":{"term":{"type":"warning"}}}`,
+			rows:     []json.RawMessage{json.RawMessage(`[{"Count":12345}]`)},
+			total:    10000,
+			relation: TotalHitsGreaterOrEqual,
+			queries:  1,
+			query:    "SELECT count() as total FROM (SELECT 1 FROM merge(logs, '^gate') WHERE (((type = 'warning'))) LIMIT 10001)",
+		},
+		{
+			caseName: "total below track_total_hits limit",
+			request:  `{"size":10,"track_total_hits":10000,"query":{"term":{"type":"info"}}}`,
+			rows:     []json.RawMessage{json.RawMessage(`[{"Count":500}]`)},
+			total:    500,
+			relation: TotalHitsEqual,
+			queries:  1,
+		},
+		{
+			caseName: "counting disabled",
+			request:  `{"size":10,"track_total_hits":false}`,
+		},
+		{
+			caseName: "total of date histogram",
+			request: `{"size":10,"track_total_hits":true,"query":{"range":{"ts":{"gte":0,"lte":60000,"format":"epoch_millis"}}},` +
+				`"aggs":{"2":{"date_histogram":{"field":"ts","interval":"30s","min_doc_count":1}}}}`,
+			rows:     []json.RawMessage{json.RawMessage(`[{"Vals":[3],"Key":0},{"Vals":[4],"Key":1}]`)},
+			total:    7,
+			relation: TotalHitsEqual,
+			queries:  1,
+		},
+	}
+
+	for _, test := range testData {
+		parsed, err := ParseElasticJSON([]byte(test.request), &gateModel)
+		if err != nil {
+			t.Error("For", test.caseName, "\n unexpected error: ", err)
+			continue
+		}
+		provider := &recordingProvider{table: "logs.gate", scheme: &gateModel, rows: test.rows}
+		var aggregated *aggregations.BucketAggregationData
+		if parsed.Aggregations != nil {
+			aggregated, _ = parsed.Aggregations.Aggregate(provider)
+		}
+		total, relation, err := parsed.TotalHits(provider, aggregated)
+		if err != nil || total != test.total || relation != test.relation || len(provider.queries) != test.queries ||
+			test.query != "" && provider.queries[0] != test.query {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.total, test.relation, test.queries,
+				"\n got: ", total, relation, provider.queries, err,
+			)
+		}
+	}
+}
+
+func TestTotalHitsCache(t *testing.T) {
+	dbFieldsMapping, _ := models.CreateDBFieldsInfoMap(reflect.TypeOf(gate{}))
+	gateModel := models.ModelInfo{
+		DBName:     "gate",
+		DataFields: dbFieldsMapping,
+	}
+	defer SetTotalHitsCacheTTL(DefaultTotalHitsCacheTTL)
+	SetTotalHitsCacheTTL(DefaultTotalHitsCacheTTL)
+
+	count := func(request string) (int, []string) {
+		parsed, _ := ParseElasticJSON([]byte(request), &gateModel)
+		provider := &recordingProvider{
+			table:  "logs.gate",
+			scheme: &gateModel,
+			rows:   []json.RawMessage{json.RawMessage(`[{"Count":42}]`)},
+		}
+		total, _ := parsed.CountHits(provider, 0)
+		return total, provider.queries
+	}
+
+	total, queries := count(`{"query":{"term":{"hostname":"gate-1"}}}`)
+	expected := []string{"SELECT count() as total FROM merge(logs, '^gate') WHERE (((hostname = 'gate-1')))"}
+	if total != 42 || !reflect.DeepEqual(queries, expected) {
+		t.Error("For", "first count", "\n expected: ", 42, expected, "\n got: ", total, queries)
+	}
+	// paging parameters do not affect number of found documents
+	if total, queries = count(`{"from":10,"size":10,"query":{"term":{"hostname":"gate-1"}}}`); total != 42 || len(queries) != 0 {
+		t.Error("For", "cached count", "\n expected: ", 42, "without requests", "\n got: ", total, queries)
+	}
+	if _, queries = count(`{"query":{"term":{"hostname":"gate-2"}}}`); len(queries) != 1 {
+		t.Error("For", "count of other query", "\n expected: ", 1, "request", "\n got: ", queries)
+	}
+
+	SetTotalHitsCacheTTL(0)
+	count(`{"query":{"term":{"hostname":"gate-1"}}}`)
+	if _, queries = count(`{"query":{"term":{"hostname":"gate-1"}}}`); len(queries) != 1 {
+		t.Error("For", "disabled cache", "\n expected: ", 1, "request", "\n got: ", queries)
+	}
+}
@@ -4,17 +4,25 @@ import (
 	"encoding/json"
 	"reflect"
 
+	"kibouse/data/models"
 	"kibouse/data/wrappers"
 	"kibouse/adapter/requests/aggregations"
 )
 
 type allHits struct {
-	Total    int   `json:"total"`
-	MaxScore int   `json:"max_score"`
-	Hits     []hit `json:"hits"`
+	Total    interface{} `json:"total,omitempty"`
+	MaxScore int         `json:"max_score"`
+	Hits     []hit       `json:"hits"`
+}
+
+// totalHitsObject is the number of found documents reported by elasticsearch 7.x.
+type totalHitsObject struct {
+	Value    int    `json:"value"`
+	Relation string `json:"relation"`
 }
 
 type fullElasticResponse struct {
+	ScrollID string                               `json:"_scroll_id,omitempty"`
 	TimedOut bool                                 `json:"timed_out"`
 	Took     int                                  `json:"took"`
 	Shards   shardsStat                           `json:"_shards"`
@@ -56,32 +64,35 @@ type all struct {
 func (f *all) CreateElasticJSON() (string, error) {
 	response := getNewResponseTemplate()
 	response.Aggs = f.aggregation
+	response.ScrollID = f.scrollID
+	total := 0
 
 	if f.rows != nil {
 		response.Hits.Hits = make([]hit, 0, f.rows.Items())
 		f.rows.Reset()
 
 		for item := f.rows.NextItem(); item != nil; item = f.rows.NextItem(){
-			response.Hits.Total++
+			total++
 
 			sortSection := fetchFieldValuesByName(f.sorting, item)
-			docValsSection := fetchFieldValuesByName(f.docValueFields, item)
 			response.Hits.Hits = append(response.Hits.Hits, hit{
-				Index:   f.index,
-				Type:    item.ChTableName(),
-				Version: 1,
-				ID:      item.ID(),
-				Score:   1,
-				Found:   true,
-				Source:  item.Data(),
-				Sort:    &sortingSectionMarshaller{values: sortSection},
-				Fields:  &docValueFieldsSectionMarshaller{values: docValsSection, fields: f.docValueFields},
+				Index:     f.index,
+				Type:      f.documentFormat().DocumentType(item.ChTableName()),
+				Version:   1,
+				ID:        item.ID(),
+				Score:     1,
+				Found:     true,
+				Source:    f.hitSource(item),
+				Sort:      &sortingSectionMarshaller{values: sortSection},
+				Fields:    f.hitFields(item),
+				Highlight: highlightHit(f.highlight, item),
 			})
 		}
 
 	} else if f.aggregation != nil {
-		response.Hits.Total = int(f.aggregation.DocCount())
+		total = int(f.aggregation.DocCount())
 	}
+	response.Hits.Total = f.totalHits(total)
 
 	response.Debug = f.debug
 
@@ -113,8 +124,8 @@ func NewFullResponseBuilder(multiple bool) Builder {
 }
 
 type FieldData struct {
-	value    *reflect.Value
-	dataType string
+	value *reflect.Value
+	field models.CHField
 }
 
 func fetchFieldValuesByName(fields []string, item wrappers.DataItem) []FieldData {
@@ -123,7 +134,7 @@ func fetchFieldValuesByName(fields []string, item wrappers.DataItem) []FieldData
 		if fieldVal, ok := item.AttrValue(field); ok {
 			fieldValues = append(
 				fieldValues,
-				FieldData{value: fieldVal, dataType: item.ModelScheme().DataFields[field].CHType},
+				FieldData{value: fieldVal, field: item.ModelScheme().DataFields[field].CHField},
 			)
 		}
 	}
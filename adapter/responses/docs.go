@@ -3,6 +3,7 @@ package responses
 import (
 	"encoding/json"
 
+	"kibouse/adapter/settings"
 	"kibouse/data/wrappers"
 )
 
@@ -23,7 +24,7 @@ func (di *docs) CreateElasticJSON() (string, error) {
 	}
 
 	for item := di.rows.NextItem(); item != nil; item = di.rows.NextItem() {
-		response.Docs = append(response.Docs, newHit(di.index, item))
+		response.Docs = append(response.Docs, newHit(di.index, item, di.documentFormat()))
 	}
 	bytes, err := json.Marshal(response)
 	if err != nil {
@@ -36,10 +37,19 @@ func NewDocItemsResponseBuilder() Builder {
 	return &docs{}
 }
 
-func newHit(index string, source wrappers.DataItem) *hit {
+// CreateDocJSON converts single document to the response of elastic GET request.
+func CreateDocJSON(index string, item wrappers.DataItem, format settings.Format) (string, error) {
+	bytes, err := json.Marshal(newHit(index, item, format))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func newHit(index string, source wrappers.DataItem, format settings.Format) *hit {
 	return &hit{
 		Index:   index,
-		Type:    source.ChTableName(),
+		Type:    format.DocumentType(source.ChTableName()),
 		Version: 1,
 		ID:      source.ID(),
 		Score:   1,
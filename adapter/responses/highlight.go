@@ -0,0 +1,193 @@
+package responses
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"kibouse/adapter/requests"
+	"kibouse/data/wrappers"
+	"kibouse/index"
+)
+
+// wordPattern finds words of text the same way as index analyzers split it into tokens.
+var wordPattern = regexp.MustCompile(`\w+`)
+
+// wildcardDelimiter splits searched terms containing wildcards into words keeping wildcard symbols.
+var wildcardDelimiter = regexp.MustCompile(`[^\w*?]+`)
+
+// termsMatcher checks that words of highlighted text are searched by request terms.
+type termsMatcher struct {
+	tokens    map[string]struct{}
+	wildcards []*regexp.Regexp
+}
+
+// newTermsMatcher splits searched terms into tokens by analyzer of the logs table,
+// words containing wildcards are matched by patterns.
+func newTermsMatcher(terms []string, analyzer index.Analyzer) *termsMatcher {
+	matcher := &termsMatcher{tokens: make(map[string]struct{})}
+	for _, term := range terms {
+		if !strings.ContainsAny(term, "*?") {
+			for _, token := range analyzer(term) {
+				matcher.tokens[token] = struct{}{}
+			}
+			continue
+		}
+		for _, word := range wildcardDelimiter.Split(strings.ToLower(term), -1) {
+			if !strings.ContainsAny(word, "*?") {
+				for _, token := range analyzer(word) {
+					matcher.tokens[token] = struct{}{}
+				}
+			} else if strings.Trim(word, "*?") != "" {
+				pattern := strings.NewReplacer(`\*`, `\w*`, `\?`, `\w`).Replace(regexp.QuoteMeta(word))
+				matcher.wildcards = append(matcher.wildcards, regexp.MustCompile("^"+pattern+"$"))
+			}
+		}
+	}
+	return matcher
+}
+
+func (m *termsMatcher) match(word string) bool {
+	word = strings.ToLower(word)
+	if _, ok := m.tokens[word]; ok {
+		return true
+	}
+	for _, wildcard := range m.wildcards {
+		if wildcard.MatchString(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightHit returns highlighted fragments of hit fields by their kibana names.
+func highlightHit(highlight *requests.Highlight, item wrappers.DataItem) map[string][]string {
+	if highlight == nil {
+		return nil
+	}
+	scheme := item.ModelScheme()
+	analyzer := index.GetTableAnalyzer(scheme.DBName)
+
+	fragments := make(map[string][]string)
+	for name, field := range highlight.Fields {
+		value, ok := item.AttrValue(name)
+		props, known := scheme.DataFields[name]
+		if !ok || !known {
+			continue
+		}
+		matcher := newTermsMatcher(field.Terms, analyzer)
+		for _, text := range fieldTexts(*value) {
+			fieldFragments := fragments[props.KibanaName]
+			if field.NumberOfFragments > 0 && len(fieldFragments) >= field.NumberOfFragments {
+				break
+			}
+			fragments[props.KibanaName] = append(fieldFragments, highlightText(text, matcher, highlight, field)...)
+		}
+		if field.NumberOfFragments > 0 && len(fragments[props.KibanaName]) > field.NumberOfFragments {
+			fragments[props.KibanaName] = fragments[props.KibanaName][:field.NumberOfFragments]
+		}
+		if len(fragments[props.KibanaName]) == 0 {
+			delete(fragments, props.KibanaName)
+		}
+	}
+	if len(fragments) == 0 {
+		return nil
+	}
+	return fragments
+}
+
+// fieldTexts returns text of string field or texts of array elements.
+func fieldTexts(value reflect.Value) []string {
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return []string{value.String()}
+	case reflect.Slice, reflect.Array:
+		texts := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			texts = append(texts, fieldTexts(value.Index(i))...)
+		}
+		return texts
+	}
+	return nil
+}
+
+// highlightText returns fragments of text containing searched words wrapped with highlighting tags,
+// fragments are about fragment size long and do not split words.
+func highlightText(text string, matcher *termsMatcher, highlight *requests.Highlight, field requests.HighlightField) []string {
+	matches := make([][]int, 0)
+	for _, word := range wordPattern.FindAllStringIndex(text, -1) {
+		if matcher.match(text[word[0]:word[1]]) {
+			matches = append(matches, word)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	if field.NumberOfFragments == 0 || field.FragmentSize >= len(text) {
+		return []string{wrapMatches(text, 0, len(text), matches, highlight)}
+	}
+
+	fragments := make([]string, 0, field.NumberOfFragments)
+	for i := 0; i < len(matches) && len(fragments) < field.NumberOfFragments; {
+		start, end := matches[i][0], matches[i][0]+field.FragmentSize
+		if end > len(text) {
+			// the last fragment is filled by text preceding the match
+			start, end = len(text)-field.FragmentSize, len(text)
+			if start > matches[i][0] {
+				start = matches[i][0]
+			}
+		}
+		start, end = wordsBoundaries(text, start, end)
+		if end < matches[i][1] {
+			end = matches[i][1]
+		}
+
+		j := i
+		for j < len(matches) && matches[j][1] <= end {
+			j++
+		}
+		fragments = append(fragments, wrapMatches(text, start, end, matches[i:j], highlight))
+		i = j
+	}
+	return fragments
+}
+
+// wordsBoundaries moves start of text fragment to the beginning of the word and its end to the end of the word.
+func wordsBoundaries(text string, start int, end int) (int, int) {
+	for start > 0 && (!utf8.RuneStart(text[start]) || isWordByte(text[start-1]) && isWordByte(text[start])) {
+		start--
+	}
+	for end < len(text) && (!utf8.RuneStart(text[end]) || isWordByte(text[end-1]) && isWordByte(text[end])) {
+		end++
+	}
+	return start, end
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// wrapMatches returns text fragment between start and end with matched words wrapped by highlighting tags.
+func wrapMatches(text string, start int, end int, matches [][]int, highlight *requests.Highlight) string {
+	fragment := strings.Builder{}
+	pos := start
+	for _, match := range matches {
+		if match[0] < start || match[1] > end {
+			continue
+		}
+		fragment.WriteString(text[pos:match[0]])
+		fragment.WriteString(highlight.PreTag)
+		fragment.WriteString(text[match[0]:match[1]])
+		fragment.WriteString(highlight.PostTag)
+		pos = match[1]
+	}
+	fragment.WriteString(text[pos:end])
+	return fragment.String()
+}
@@ -0,0 +1,72 @@
+package responses
+
+import (
+	"reflect"
+	"testing"
+
+	"kibouse/adapter/requests"
+	"kibouse/index"
+)
+
+func TestHighlightText(t *testing.T) {
+	highlight := &requests.Highlight{PreTag: "<em>", PostTag: "</em>"}
+	testData := []struct {
+		caseName  string
+		text      string
+		terms     []string
+		field     requests.HighlightField
+		fragments []string
+	}{
+		{
+			caseName:  "whole text",
+			text:      "Connection timeout: gate-1 is not responding, timeout 30s",
+			terms:     []string{"timeout", "GATE"},
+			field:     requests.HighlightField{FragmentSize: 2147483647, NumberOfFragments: 5},
+			fragments: []string{"Connection <em>timeout</em>: <em>gate</em>-1 is not responding, <em>timeout</em> 30s"},
+		},
+		{
+			caseName:  "no fragments",
+			text:      "Connection timeout",
+			terms:     []string{"timeout"},
+			field:     requests.HighlightField{FragmentSize: 5, NumberOfFragments: 0},
+			fragments: []string{"Connection <em>timeout</em>"},
+		},
+		{
+			caseName: "fragments of long text",
+			text:     "request failed with timeout after retrying the request three times, the last timeout was fatal",
+			terms:    []string{"timeout"},
+			field:    requests.HighlightField{FragmentSize: 20, NumberOfFragments: 5},
+			fragments: []string{
+				"<em>timeout</em> after retrying",
+				"last <em>timeout</em> was fatal",
+			},
+		},
+		{
+			caseName:  "number of fragments",
+			text:      "timeout one, timeout two, timeout three",
+			terms:     []string{"timeout"},
+			field:     requests.HighlightField{FragmentSize: 5, NumberOfFragments: 2},
+			fragments: []string{"<em>timeout</em>", "<em>timeout</em>"},
+		},
+		{
+			caseName:  "wildcard term",
+			text:      "Timeouts and timed out requests",
+			terms:     []string{"time*", "req?ests"},
+			field:     requests.HighlightField{FragmentSize: 100, NumberOfFragments: 5},
+			fragments: []string{"<em>Timeouts</em> and <em>timed</em> out <em>requests</em>"},
+		},
+		{
+			caseName: "not matched text",
+			text:     "everything is fine",
+			terms:    []string{"timeout"},
+			field:    requests.HighlightField{FragmentSize: 100, NumberOfFragments: 5},
+		},
+	}
+
+	for _, test := range testData {
+		fragments := highlightText(test.text, newTermsMatcher(test.terms, index.GetTokens), highlight, test.field)
+		if !reflect.DeepEqual(fragments, test.fragments) {
+			t.Error("For", test.caseName, "\n expected: ", test.fragments, "\n got: ", fragments)
+		}
+	}
+}
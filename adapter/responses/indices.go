@@ -0,0 +1,198 @@
+package responses
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// clusterName is the name of elastic cluster emulated by kibouse.
+const clusterName = "elasticsearch"
+
+// health statuses of the cluster and indices from the best to the worst one
+const (
+	HealthGreen  = "green"
+	HealthYellow = "yellow"
+	HealthRed    = "red"
+)
+
+var healthLevels = map[string]int{HealthGreen: 0, HealthYellow: 1, HealthRed: 2}
+
+// HealthSatisfies checks that health status is the same or better than the required one,
+// unknown statuses are never satisfied.
+func HealthSatisfies(status string, required string) bool {
+	level, ok := healthLevels[status]
+	requiredLevel, known := healthLevels[required]
+	return ok && known && level <= requiredLevel
+}
+
+// IndexStats describes logs table reported by _cat/indices api.
+type IndexStats struct {
+	Name      string
+	Health    string
+	DocsCount uint64
+	StoreSize uint64
+}
+
+// CatIndicesColumns are the columns of _cat/indices response, tables have single primary shard without replicas.
+var CatIndicesColumns = []string{
+	"health", "status", "index", "pri", "rep", "docs.count", "docs.deleted", "store.size", "pri.store.size",
+}
+
+// byteUnits are the units of sizes accepted by "bytes" parameter of _cat api.
+var byteUnits = []string{"b", "kb", "mb", "gb", "tb", "pb"}
+
+// catIndexRow returns values of _cat/indices columns, sizes are converted to the unit or formatted
+// as human readable values if unit is not set.
+func catIndexRow(index IndexStats, unit string) map[string]string {
+	size := formatByteSize(index.StoreSize, unit)
+	return map[string]string{
+		"health":         index.Health,
+		"status":         "open",
+		"index":          index.Name,
+		"pri":            "1",
+		"rep":            "0",
+		"docs.count":     strconv.FormatUint(index.DocsCount, 10),
+		"docs.deleted":   "0",
+		"store.size":     size,
+		"pri.store.size": size,
+	}
+}
+
+// formatByteSize returns size in the unit, size is formatted like elastic does (e.g. 1.5kb) if unit is empty.
+func formatByteSize(bytes uint64, unit string) string {
+	for i, name := range byteUnits {
+		if name == unit {
+			return strconv.FormatUint(bytes>>(10*uint(i)), 10)
+		}
+	}
+
+	size, i := float64(bytes), 0
+	for size >= 1024 && i < len(byteUnits)-1 {
+		size /= 1024
+		i++
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(size, 'f', 1, 64), ".0") + byteUnits[i]
+}
+
+// CreateCatIndicesJSON returns _cat/indices response in json format, only listed columns are returned.
+func CreateCatIndicesJSON(indices []IndexStats, columns []string, unit string) ([]byte, error) {
+	rows := make([]map[string]string, 0, len(indices))
+	for _, index := range indices {
+		values := catIndexRow(index, unit)
+		row := make(map[string]string, len(columns))
+		for _, column := range columns {
+			row[column] = values[column]
+		}
+		rows = append(rows, row)
+	}
+	return json.Marshal(rows)
+}
+
+// CreateCatIndicesText returns _cat/indices response as text table aligned by columns,
+// header with names of columns is added in verbose mode.
+func CreateCatIndicesText(indices []IndexStats, columns []string, unit string, verbose bool) string {
+	rows := make([][]string, 0, len(indices)+1)
+	if verbose {
+		rows = append(rows, columns)
+	}
+	for _, index := range indices {
+		values := catIndexRow(index, unit)
+		row := make([]string, 0, len(columns))
+		for _, column := range columns {
+			row = append(row, values[column])
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for i, value := range row {
+			if len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+	text := strings.Builder{}
+	for _, row := range rows {
+		for i, value := range row {
+			if i > 0 {
+				text.WriteString(" ")
+			}
+			text.WriteString(value)
+			if i < len(row)-1 {
+				text.WriteString(strings.Repeat(" ", widths[i]-len(value)))
+			}
+		}
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// CreateAliasesJSON returns _aliases response, logs tables have no aliases.
+func CreateAliasesJSON(indices []string) ([]byte, error) {
+	aliases := make(map[string]interface{}, len(indices))
+	for _, index := range indices {
+		aliases[index] = map[string]interface{}{"aliases": map[string]interface{}{}}
+	}
+	return json.Marshal(aliases)
+}
+
+type resolvedIndex struct {
+	Name       string   `json:"name"`
+	Attributes []string `json:"attributes"`
+}
+
+// CreateResolveIndexJSON returns _resolve/index response listing logs tables as open indices.
+func CreateResolveIndexJSON(indices []string) ([]byte, error) {
+	names := append([]string{}, indices...)
+	sort.Strings(names)
+	resolved := make([]resolvedIndex, 0, len(names))
+	for _, name := range names {
+		resolved = append(resolved, resolvedIndex{Name: name, Attributes: []string{"open"}})
+	}
+	return json.Marshal(map[string]interface{}{
+		"indices":      resolved,
+		"aliases":      []interface{}{},
+		"data_streams": []interface{}{},
+	})
+}
+
+type clusterHealth struct {
+	ClusterName                 string  `json:"cluster_name"`
+	Status                      string  `json:"status"`
+	TimedOut                    bool    `json:"timed_out"`
+	NumberOfNodes               int     `json:"number_of_nodes"`
+	NumberOfDataNodes           int     `json:"number_of_data_nodes"`
+	ActivePrimaryShards         int     `json:"active_primary_shards"`
+	ActiveShards                int     `json:"active_shards"`
+	RelocatingShards            int     `json:"relocating_shards"`
+	InitializingShards          int     `json:"initializing_shards"`
+	UnassignedShards            int     `json:"unassigned_shards"`
+	DelayedUnassignedShards     int     `json:"delayed_unassigned_shards"`
+	NumberOfPendingTasks        int     `json:"number_of_pending_tasks"`
+	NumberOfInFlightFetch       int     `json:"number_of_in_flight_fetch"`
+	TaskMaxWaitingInQueueMillis int     `json:"task_max_waiting_in_queue_millis"`
+	ActiveShardsPercentAsNumber float64 `json:"active_shards_percent_as_number"`
+}
+
+// CreateClusterHealthJSON returns _cluster/health response, every logs table is reported as index
+// with single shard, shards are unassigned if clickhouse is not reachable.
+func CreateClusterHealthJSON(status string, indices int, timedOut bool) ([]byte, error) {
+	health := clusterHealth{
+		ClusterName:   clusterName,
+		Status:        status,
+		TimedOut:      timedOut,
+		NumberOfNodes: 1,
+	}
+	if status == HealthRed {
+		health.UnassignedShards = indices
+	} else {
+		health.NumberOfDataNodes = 1
+		health.ActivePrimaryShards = indices
+		health.ActiveShards = indices
+		health.ActiveShardsPercentAsNumber = 100
+	}
+	return json.Marshal(&health)
+}
@@ -0,0 +1,110 @@
+package responses
+
+import (
+	"testing"
+)
+
+func TestFormatByteSize(t *testing.T) {
+	testData := []struct {
+		caseName string
+		bytes    uint64
+		unit     string
+		expected string
+	}{
+		{caseName: "bytes", bytes: 208, expected: "208b"},
+		{caseName: "fractional kilobytes", bytes: 5222, expected: "5.1kb"},
+		{caseName: "whole megabytes", bytes: 3 << 20, expected: "3mb"},
+		{caseName: "empty table", bytes: 0, expected: "0b"},
+		{caseName: "size in bytes", bytes: 3 << 20, unit: "b", expected: "3145728"},
+		{caseName: "size in kilobytes", bytes: 5222, unit: "kb", expected: "5"},
+	}
+
+	for _, test := range testData {
+		if got := formatByteSize(test.bytes, test.unit); got != test.expected {
+			t.Error("For", test.caseName, "\n expected: ", test.expected, "\n got: ", got)
+		}
+	}
+}
+
+func TestCatIndices(t *testing.T) {
+	indices := []IndexStats{
+		{Name: "logs_gate", Health: HealthGreen, DocsCount: 12345, StoreSize: 5222},
+		{Name: "archive.nginx", Health: HealthGreen},
+	}
+
+	testData := []struct {
+		caseName string
+		json     bool
+		columns  []string
+		verbose  bool
+		expected string
+	}{
+		{
+			caseName: "json with selected columns",
+			json:     true,
+			columns:  []string{"index", "docs.count", "store.size"},
+			expected: `[{"docs.count":"12345","index":"logs_gate","store.size":"5.1kb"},` +
+				`{"docs.count":"0","index":"archive.nginx","store.size":"0b"}]`,
+		},
+		{
+			caseName: "text with header",
+			columns:  []string{"health", "index", "docs.count"},
+			verbose:  true,
+			expected: "health index         docs.count\n" +
+				"green  logs_gate     12345\n" +
+				"green  archive.nginx 0\n",
+		},
+		{
+			caseName: "text without header",
+			columns:  []string{"index", "pri", "rep"},
+			expected: "logs_gate     1 0\n" +
+				"archive.nginx 1 0\n",
+		},
+	}
+
+	for _, test := range testData {
+		got := CreateCatIndicesText(indices, test.columns, "", test.verbose)
+		if test.json {
+			response, _ := CreateCatIndicesJSON(indices, test.columns, "")
+			got = string(response)
+		}
+		if got != test.expected {
+			t.Error("For", test.caseName, "\n expected: ", test.expected, "\n got: ", got)
+		}
+	}
+}
+
+func TestClusterHealth(t *testing.T) {
+	testData := []struct {
+		caseName string
+		status   string
+		required string
+		expected string
+	}{
+		{
+			caseName: "reachable clickhouse",
+			status:   HealthGreen,
+			required: HealthYellow,
+			expected: `{"cluster_name":"elasticsearch","status":"green","timed_out":false,"number_of_nodes":1,` +
+				`"number_of_data_nodes":1,"active_primary_shards":2,"active_shards":2,"relocating_shards":0,` +
+				`"initializing_shards":0,"unassigned_shards":0,"delayed_unassigned_shards":0,"number_of_pending_tasks":0,` +
+				`"number_of_in_flight_fetch":0,"task_max_waiting_in_queue_millis":0,"active_shards_percent_as_number":100}`,
+		},
+		{
+			caseName: "unreachable clickhouse",
+			status:   HealthRed,
+			required: HealthYellow,
+			expected: `{"cluster_name":"elasticsearch","status":"red","timed_out":true,"number_of_nodes":1,` +
+				`"number_of_data_nodes":0,"active_primary_shards":0,"active_shards":0,"relocating_shards":0,` +
+				`"initializing_shards":0,"unassigned_shards":2,"delayed_unassigned_shards":0,"number_of_pending_tasks":0,` +
+				`"number_of_in_flight_fetch":0,"task_max_waiting_in_queue_millis":0,"active_shards_percent_as_number":0}`,
+		},
+	}
+
+	for _, test := range testData {
+		response, err := CreateClusterHealthJSON(test.status, 2, !HealthSatisfies(test.status, test.required))
+		if err != nil || string(response) != test.expected {
+			t.Error("For", test.caseName, "\n expected: ", test.expected, "\n got: ", string(response), err)
+		}
+	}
+}
@@ -2,27 +2,64 @@ package responses
 
 import (
 	"encoding/json"
+	"sort"
+	"strings"
 
 	"kibouse/data/models"
 )
 
 func CreateFieldCapsJSON(tableInfo map[string]*models.FieldProps) ([]byte, error) {
+	return CreateIndicesFieldCapsJSON(map[string]map[string]*models.FieldProps{"": tableInfo})
+}
+
+// CreateIndicesFieldCapsJSON returns field capabilities of several indices, types of the field
+// differing between indices are listed with the indices having them.
+func CreateIndicesFieldCapsJSON(indices map[string]map[string]*models.FieldProps) ([]byte, error) {
 	jsonStruct := createBaseMappingJson()
-	for name, clkInfo := range tableInfo {
-		elasticType := clickhouseTypeToElastic(clkInfo.CHType)
-		elcInfo := info{Type: elasticType, Searchable: true}
-		if elasticType != "text" {
-			elcInfo.Aggregatable = true
+	names := make([]string, 0, len(indices))
+	for index := range indices {
+		names = append(names, index)
+	}
+	sort.Strings(names)
+
+	for _, index := range names {
+		for name, clkInfo := range indices[index] {
+			elasticType := clickhouseTypeToElastic(clkInfo.CHField)
+			// map keys and paths of JSON payloads are exposed as separate sub-fields
+			if elasticType == "object" || clkInfo.IsJSONBlob() {
+				continue
+			}
+			if _, ok := jsonStruct.Fields[name]; !ok {
+				jsonStruct.Fields[name] = make(map[string]info)
+			}
+			elcInfo, ok := jsonStruct.Fields[name][elasticType]
+			if !ok {
+				elcInfo = info{Type: elasticType, Searchable: true, Aggregatable: elasticType != "text"}
+			}
+			if index != "" {
+				elcInfo.Indices = append(elcInfo.Indices, index)
+			}
+			jsonStruct.Fields[name][elasticType] = elcInfo
+		}
+	}
+
+	// indices are reported only for fields with conflicting types
+	for name, types := range jsonStruct.Fields {
+		if len(types) == 1 {
+			for elasticType, elcInfo := range types {
+				elcInfo.Indices = nil
+				jsonStruct.Fields[name][elasticType] = elcInfo
+			}
 		}
-		addFieldMappingJson(name, elcInfo, jsonStruct)
 	}
 	return json.Marshal(&jsonStruct)
 }
 
 type info struct {
-	Type       string `json:"type"`
-	Searchable bool   `json:"searchable"`
-	Aggregatable bool `json:"aggregatable"`
+	Type         string   `json:"type"`
+	Searchable   bool     `json:"searchable"`
+	Aggregatable bool     `json:"aggregatable"`
+	Indices      []string `json:"indices,omitempty"`
 }
 
 type mapping struct {
@@ -45,21 +82,102 @@ func addFieldMappingJson(name string, params info, json *mapping) {
 	json.Fields[name][params.Type] = params
 }
 
+// mappingProperty is the field of elastic index mapping, objects contain properties of their sub-fields.
+type mappingProperty struct {
+	Type       string                      `json:"type,omitempty"`
+	Fields     map[string]mappingProperty  `json:"fields,omitempty"`
+	Properties map[string]*mappingProperty `json:"properties,omitempty"`
+}
+
+// CreateIndicesMappingJSON returns elastic mapping of several indices, mapping of the index is nested into
+// its document type, typeless mapping of elasticsearch 7.x is returned for indices without type.
+func CreateIndicesMappingJSON(indices map[string]map[string]*models.FieldProps, docTypes map[string]string) ([]byte, error) {
+	mappings := make(map[string]interface{}, len(indices))
+	for index, fields := range indices {
+		var mapping interface{} = map[string]interface{}{"properties": createMappingProperties(fields)}
+		if docType := docTypes[index]; docType != "" {
+			mapping = map[string]interface{}{docType: mapping}
+		}
+		mappings[index] = map[string]interface{}{"mappings": mapping}
+	}
+	return json.Marshal(mappings)
+}
+
+// createMappingProperties converts fields to mapping properties, dotted names of map keys and
+// paths of JSON payloads become properties of object fields.
+func createMappingProperties(fields map[string]*models.FieldProps) map[string]*mappingProperty {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	root := &mappingProperty{Properties: make(map[string]*mappingProperty)}
+	for _, name := range names {
+		elasticType := clickhouseTypeToElastic(fields[name].CHField)
+		// objects are defined by their sub-fields
+		if elasticType == "object" || fields[name].IsJSONBlob() {
+			continue
+		}
+
+		path := strings.Split(name, ".")
+		parent := root
+		for _, part := range path[:len(path)-1] {
+			if parent.Properties == nil {
+				break
+			}
+			object, ok := parent.Properties[part]
+			if !ok {
+				object = &mappingProperty{Properties: make(map[string]*mappingProperty)}
+				parent.Properties[part] = object
+			}
+			parent = object
+		}
+		// sub-fields of fields with values are not supported by elastic mapping
+		if parent.Properties == nil {
+			continue
+		}
+		if _, ok := parent.Properties[path[len(path)-1]]; ok {
+			continue
+		}
+
+		property := &mappingProperty{Type: elasticType}
+		if elasticType == "text" {
+			// text fields are aggregated by keyword sub-field, it is read from the same column
+			property.Fields = map[string]mappingProperty{"keyword": {Type: "keyword"}}
+		}
+		parent.Properties[path[len(path)-1]] = property
+	}
+	return root.Properties
+}
+
 // clickhouseTypeToElastic converts clickhouse data types to elastic.
-func clickhouseTypeToElastic(clickhouseType string) string {
-	switch clickhouseType {
-	case "String", "Array(String)":
-		return "text"
-	case "Bool":
+func clickhouseTypeToElastic(field models.CHField) string {
+	switch {
+	case field.IsTime():
+		return "date"
+	case field.CHType == "Bool":
 		return "boolean"
-	case "UInt16", "Array(UInt16)", "UInt32", "Array(UInt32)", "UInt64", "Array(UInt64)", "Int8", "Array(Int8)",
-		"Int16", "Array(Int16)", "Int32", "Array(Int32)", "Int64", "Array(Int64)":
+	case field.IsMap(), field.IsJSONObject():
+		return "object"
+	case field.IsIP():
+		return "ip"
+	case field.IsText() && !field.IsLowCardinality():
+		return "text"
+	case field.IsString():
+		// low cardinality strings, enums and uuids are not analyzed
+		return "keyword"
+	}
+
+	switch field.GetBaseChType() {
+	case "UInt8", "UInt16", "UInt32", "UInt64", "Int8", "Int16", "Int32", "Int64":
 		return "long"
-	case "Float32", "Array(Float32)", "Float64", "Array(Float64)":
+	case "Float32", "Float64":
 		return "float"
-	case "Timestamp", "Date", "DateTime":
-		return "date"
-	default:
-		return "keyword"
 	}
-}
\ No newline at end of file
+	if field.IsNumeric() {
+		// decimals
+		return "double"
+	}
+	return "keyword"
+}
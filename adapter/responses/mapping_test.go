@@ -47,3 +47,102 @@ func TestCreateElasticMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestClickhouseTypeToElastic(t *testing.T) {
+	testData := []struct {
+		caseName string
+		chType   string
+		timeUnit string
+		result   string
+	}{
+		{caseName: "string", chType: "String", result: "text"},
+		{caseName: "timestamp in milliseconds", chType: "UInt64", timeUnit: models.Milliseconds, result: "date"},
+		{caseName: "integer", chType: "UInt64", result: "long"},
+		{caseName: "datetime", chType: "DateTime", result: "date"},
+		{caseName: "nullable string", chType: "Nullable(String)", result: "text"},
+		{caseName: "low cardinality string", chType: "LowCardinality(String)", result: "keyword"},
+		{caseName: "low cardinality nullable string", chType: "LowCardinality(Nullable(String))", result: "keyword"},
+		{caseName: "enum", chType: "Enum8('info' = 1, 'error' = 2)", result: "keyword"},
+		{caseName: "uuid", chType: "UUID", result: "keyword"},
+		{caseName: "ipv4", chType: "IPv4", result: "ip"},
+		{caseName: "nullable ipv6", chType: "Nullable(IPv6)", result: "ip"},
+		{caseName: "datetime64", chType: "DateTime64(3)", result: "date"},
+		{caseName: "nullable date", chType: "Nullable(Date)", result: "date"},
+		{caseName: "decimal", chType: "Decimal(18, 4)", result: "double"},
+		{caseName: "nullable int", chType: "Nullable(Int32)", result: "long"},
+		{caseName: "map", chType: "Map(String, String)", result: "object"},
+	}
+
+	for _, test := range testData {
+		result := clickhouseTypeToElastic(models.CHField{CHType: test.chType, TimeUnit: test.timeUnit})
+		if result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result,
+			)
+		}
+	}
+}
+
+func TestCreateIndicesFieldCapsJSON(t *testing.T) {
+	indices := map[string]map[string]*models.FieldProps{
+		"logs_a": {
+			"ts":     {CHField: models.CHField{CHName: "ts", CHType: "UInt64", TimeUnit: models.Nanoseconds}},
+			"status": {CHField: models.CHField{CHName: "status", CHType: "UInt16"}},
+		},
+		"logs_b": {
+			"ts":     {CHField: models.CHField{CHName: "ts", CHType: "DateTime"}},
+			"status": {CHField: models.CHField{CHName: "status", CHType: "String"}},
+		},
+	}
+	expected := `{"fields":{"_id":{"_id":{"type":"_id","searchable":true,"aggregatable":true}},"_index":{"_index":{"type":"_index","searchable":true,"aggregatable":true}},"_source":{"_source":{"type":"_source","searchable":false,"aggregatable":false}},"_type":{"_type":{"type":"_type","searchable":true,"aggregatable":true}},"status":{"long":{"type":"long","searchable":true,"aggregatable":true,"indices":["logs_a"]},"text":{"type":"text","searchable":true,"aggregatable":false,"indices":["logs_b"]}},"ts":{"date":{"type":"date","searchable":true,"aggregatable":true}}}}`
+
+	result, err := CreateIndicesFieldCapsJSON(indices)
+	if err != nil || string(result) != expected {
+		t.Error(
+			"For", "indices with conflicting field types",
+			"\n expected: ", expected,
+			"\n got: ", string(result), err,
+		)
+	}
+}
+
+func TestCreateIndicesMappingJSON(t *testing.T) {
+	fields := map[string]*models.FieldProps{
+		"ts":          {CHField: models.CHField{CHName: "ts", CHType: "DateTime64(3)"}},
+		"hostname":    {CHField: models.CHField{CHName: "hostname", CHType: "String"}},
+		"labels":      {CHField: models.CHField{CHName: "labels", CHType: "Map(String, String)"}},
+		"labels.env":  {CHField: models.CHField{CHName: "labels['env']", CHType: "String"}},
+		"payload":     {CHField: models.CHField{CHName: "payload", CHType: "String"}, JSONBlob: true},
+		"payload.a.b": {CHField: models.CHField{CHName: "JSONExtractInt(payload, 'a', 'b')", CHType: "Int64"}},
+	}
+	properties := `{"hostname":{"type":"text","fields":{"keyword":{"type":"keyword"}}},"labels":{"properties":{"env":{"type":"text","fields":{"keyword":{"type":"keyword"}}}}},"payload":{"properties":{"a":{"properties":{"b":{"type":"long"}}}}},"ts":{"type":"date"}}`
+
+	testData := []struct {
+		caseName string
+		docTypes map[string]string
+		result   string
+	}{
+		{
+			caseName: "mapping with document type",
+			docTypes: map[string]string{"logs_gate": "doc"},
+			result:   `{"logs_gate":{"mappings":{"doc":{"properties":` + properties + `}}}}`,
+		},
+		{
+			caseName: "typeless mapping",
+			result:   `{"logs_gate":{"mappings":{"properties":` + properties + `}}}`,
+		},
+	}
+
+	for _, test := range testData {
+		result, err := CreateIndicesMappingJSON(map[string]map[string]*models.FieldProps{"logs_gate": fields}, test.docTypes)
+		if err != nil || string(result) != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", string(result), err,
+			)
+		}
+	}
+}
@@ -4,26 +4,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"kibouse/data/models"
 	"kibouse/data/wrappers"
+	"kibouse/adapter/requests"
 	"kibouse/adapter/requests/aggregations"
+	"kibouse/adapter/settings"
 )
 
+
 type hit struct {
-	Index   string                           `json:"_index"`
-	Version int                              `json:"_version"`
-	ID      string                           `json:"_id"`
-	Type    string                           `json:"_type"`
-	Score   int                              `json:"_score"`
-	Found   bool                             `json:"found"`
-	Source  interface{}                      `json:"_source"`
-	Sort    *sortingSectionMarshaller        `json:"sort,omitempty"`
-	Fields  *docValueFieldsSectionMarshaller `json:"fields,omitempty"`
-	Debug   map[string]string                `json:"_debug"`
+	Index     string                           `json:"_index"`
+	Version   int                              `json:"_version"`
+	ID        string                           `json:"_id"`
+	Type      string                           `json:"_type"`
+	Score     int                              `json:"_score"`
+	Found     bool                             `json:"found"`
+	Source    interface{}                      `json:"_source,omitempty"`
+	Sort      *sortingSectionMarshaller        `json:"sort,omitempty"`
+	Fields    *docValueFieldsSectionMarshaller `json:"fields,omitempty"`
+	Highlight map[string][]string              `json:"highlight,omitempty"`
+	Debug     map[string]string                `json:"_debug"`
 }
 
 type shardsStat struct {
@@ -36,26 +42,48 @@ type shardsStat struct {
 type Builder interface {
 	CreateElasticJSON() (string, error)
 	AddIndex(string)
+	AddScrollID(string)
 	AddSorting([]string)
 	AddDocValueFields([]string)
+	AddDocValueFormat(map[string]string)
+	AddScriptFields(map[string]string)
+	AddSourceFilter(*requests.SourceFilter)
+	AddHighlight(*requests.Highlight)
 	AddHits(wrappers.ChDataWrapper)
 	AddAggregationResult(data *aggregations.BucketAggregationData)
+	AddTotalHits(int, string)
 	AppendDebug(string, string)
+	TotalHitsAsInt(bool)
+	UseFormat(settings.Format)
 }
 
 type ResponseInputs struct {
 	index          string
+	scrollID       string
 	sorting        []string
 	docValueFields []string
+	docValueFormat map[string]string
+	scriptFields   map[string]string
+	source         *requests.SourceFilter
+	sourceFields   map[*models.ModelInfo][]string
+	highlight      *requests.Highlight
 	rows           wrappers.ChDataWrapper
 	aggregation    *aggregations.BucketAggregationData
+	total          *totalHitsObject
 	debug          map[string]string
+	totalAsInt     bool
+	format         *settings.Format
 }
 
 func (ri *ResponseInputs) AddIndex(index string) {
 	ri.index = index
 }
 
+// AddScrollID sets id of scroll context used for requesting next pages of hits.
+func (ri *ResponseInputs) AddScrollID(id string) {
+	ri.scrollID = id
+}
+
 func (ri *ResponseInputs) AddSorting(sorting []string) {
 	ri.sorting = sorting
 }
@@ -64,6 +92,27 @@ func (ri *ResponseInputs) AddDocValueFields(docValueFields []string) {
 	ri.docValueFields = docValueFields
 }
 
+// AddDocValueFormat sets formats of docvalue fields values by field names.
+func (ri *ResponseInputs) AddDocValueFormat(formats map[string]string) {
+	ri.docValueFormat = formats
+}
+
+// AddScriptFields sets script fields returned with hits, scripts return values of document fields.
+func (ri *ResponseInputs) AddScriptFields(fields map[string]string) {
+	ri.scriptFields = fields
+}
+
+// AddSourceFilter sets fields returned in source of hits, whole data of hits is returned if filter is nil.
+func (ri *ResponseInputs) AddSourceFilter(filter *requests.SourceFilter) {
+	ri.source = filter
+	ri.sourceFields = make(map[*models.ModelInfo][]string)
+}
+
+// AddHighlight sets settings of hits highlighting, hits are not highlighted if it is nil.
+func (ri *ResponseInputs) AddHighlight(highlight *requests.Highlight) {
+	ri.highlight = highlight
+}
+
 func (ri *ResponseInputs) AddHits(rows wrappers.ChDataWrapper) {
 	ri.rows = rows
 }
@@ -72,6 +121,13 @@ func (ri *ResponseInputs) AddAggregationResult(aggregation *aggregations.BucketA
 	ri.aggregation = aggregation
 }
 
+// AddTotalHits sets number of documents matching the search request, relation is "gte" if the number
+// is a lower bound of the real one and empty if documents are not counted. Number of returned hits
+// is reported if it is not set.
+func (ri *ResponseInputs) AddTotalHits(total int, relation string) {
+	ri.total = &totalHitsObject{Value: total, Relation: relation}
+}
+
 func (ri *ResponseInputs) AppendDebug(key string, value string) {
 	if ri.debug == nil {
 		ri.debug = make(map[string]string)
@@ -79,11 +135,61 @@ func (ri *ResponseInputs) AppendDebug(key string, value string) {
 	ri.debug[key] = value
 }
 
+// TotalHitsAsInt requests reporting number of found documents as integer for kibana 7.x
+// (rest_total_hits_as_int url parameter).
+func (ri *ResponseInputs) TotalHitsAsInt(asInt bool) {
+	ri.totalAsInt = asInt
+}
+
+// UseFormat sets format of documents expected by the requesting UI, the served kibana format is used by default.
+func (ri *ResponseInputs) UseFormat(format settings.Format) {
+	ri.format = &format
+}
+
+func (ri *ResponseInputs) documentFormat() settings.Format {
+	if ri.format != nil {
+		return *ri.format
+	}
+	return settings.KibanaFormat()
+}
+
+// totalHits returns number of found documents in format of the requesting UI.
+func (ri *ResponseInputs) totalHits(hits int) interface{} {
+	total := totalHitsObject{Value: hits, Relation: "eq"}
+	if ri.total != nil {
+		total = *ri.total
+	}
+	asInt := ri.totalAsInt || ri.documentFormat() < settings.Kibana7
+	// elasticsearch 6.x reports -1 and 7.x omits total if documents are not counted
+	if total.Relation == "" {
+		if asInt {
+			return -1
+		}
+		return nil
+	}
+	if asInt {
+		return total.Value
+	}
+	return total
+}
+
 type sortingSectionMarshaller struct {
 	values []FieldData
 }
 
 func (ss *sortingSectionMarshaller) MarshalJSON() ([]byte, error) {
+	if len(ss.values) > 0 && ss.values[0].field.IsTime() {
+		// elastic returns sort values of date fields in milliseconds
+		sorting := make([]interface{}, len(ss.values))
+		for i, sortVal := range ss.values {
+			if !sortVal.field.IsTime() {
+				sorting[i] = tieBreakerValue(*sortVal.value)
+			} else if t, ok := sortVal.field.TimeValue(sortVal.value.Interface()); ok {
+				sorting[i] = t.UnixNano() / int64(time.Millisecond)
+			}
+		}
+		return json.Marshal(sorting)
+	}
 	if len(ss.values) > 0 {
 		switch ss.values[0].value.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -110,6 +216,13 @@ func (ss *sortingSectionMarshaller) MarshalJSON() ([]byte, error) {
 				sorting[i] = sortVal.value.String()
 			}
 			return json.Marshal(sorting)
+		case reflect.Struct, reflect.Slice, reflect.Map:
+			// nullable values, time and ip addresses
+			sorting := make([]interface{}, len(ss.values))
+			for i, sortVal := range ss.values {
+				sorting[i] = sortVal.value.Interface()
+			}
+			return json.Marshal(sorting)
 		default:
 			return nil, errors.New("unsupported data type of sort values")
 		}
@@ -117,33 +230,71 @@ func (ss *sortingSectionMarshaller) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ss.values)
 }
 
-func printFormattedValues(fieldType string, fieldVal *reflect.Value) string {
-	switch fieldType {
-	case "String", "DateTime":
+// maxSafeInteger is the maximum integer represented by javascript numbers without precision loss.
+const maxSafeInteger = 1<<53 - 1
+
+// tieBreakerValue returns sort value of the field breaking ties of sorting by time (e.g. uuid),
+// large integers are returned as strings because kibana sends them back as search_after values.
+func tieBreakerValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() > maxSafeInteger || value.Int() < -maxSafeInteger {
+			return strconv.FormatInt(value.Int(), 10)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() > maxSafeInteger {
+			return strconv.FormatUint(value.Uint(), 10)
+		}
+	}
+	return value.Interface()
+}
+
+// printFormattedValues returns JSON of docvalue field value, values of date fields are formatted
+// by the requested format (date_time, epoch_millis, epoch_second or date).
+func printFormattedValues(field models.CHField, fieldVal *reflect.Value, format string) string {
+	if field.IsTime() {
+		t, ok := field.TimeValue(fieldVal.Interface())
+		switch {
+		case !ok:
+			return "null"
+		case format == "epoch_millis":
+			return fmt.Sprintf(`"%d"`, t.UnixNano()/int64(time.Millisecond))
+		case format == "epoch_second":
+			return fmt.Sprintf(`"%d"`, t.Unix())
+		case format == "date_time" || format == "strict_date_time":
+			return fmt.Sprintf(`"%s"`, t.UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+		case format == "date" || format == "strict_date" || format == "" && field.GetBaseChType() == "Date":
+			return fmt.Sprintf(`"%s"`, t.Format("2006-01-02"))
+		default:
+			// sub-second precision is kept
+			return fmt.Sprintf(`"%s"`, t.Format(time.RFC3339Nano))
+		}
+	}
+	if field.CHType == "String" {
 		return fmt.Sprintf(`"%v"`, fieldVal)
-	case "Date":
-		date := fieldVal.Interface().(time.Time)
-		return fmt.Sprintf(`"%s"`, date.Format("2006-01-02"))
-	case "Timestamp":
-		timestamp := time.Unix(0, int64(fieldVal.Uint()))
-		return fmt.Sprintf(`"%v"`, timestamp)
-	default:
-		return fmt.Sprintf("%v", fieldVal.Interface())
 	}
+	// nullable values, ip addresses and strings of other types are marshalled according to their go types
+	if bytes, err := json.Marshal(fieldVal.Interface()); err == nil {
+		return string(bytes)
+	}
+	return fmt.Sprintf("%v", fieldVal.Interface())
+}
+
+// docValueField contains value of docvalue or script field returned in fields section of hit.
+type docValueField struct {
+	FieldData
+	name   string
+	format string
 }
 
 type docValueFieldsSectionMarshaller struct {
-	fields []string
-	values []FieldData
+	values []docValueField
 }
 
 func (dm *docValueFieldsSectionMarshaller) MarshalJSON() ([]byte, error) {
-	if len(dm.values) != len(dm.fields) {
-		return nil, errors.New("doc value section couldn't be create: input arrays size mismatch")
-	}
-	docValueFieldsJson := make([]string, len(dm.fields))
-	for i := range dm.fields {
-		docValueFieldsJson[i] = fmt.Sprintf(`"%s": [%s]`, dm.fields[i], printFormattedValues(dm.values[i].dataType, dm.values[i].value))
+	docValueFieldsJson := make([]string, len(dm.values))
+	for i, value := range dm.values {
+		docValueFieldsJson[i] = fmt.Sprintf(`"%s": [%s]`, value.name, printFormattedValues(value.field, value.value, value.format))
 	}
 	return []byte(fmt.Sprintf("{%s}", strings.Join(docValueFieldsJson, ","))), nil
 }
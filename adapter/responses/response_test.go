@@ -0,0 +1,38 @@
+package responses
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"kibouse/data/models"
+)
+
+func TestPrintFormattedValues(t *testing.T) {
+	ts := time.Date(2019, 6, 10, 12, 30, 15, 123456789, time.UTC)
+	nanos := reflect.ValueOf(uint64(ts.UnixNano()))
+	date := reflect.ValueOf(ts)
+	timestamp := models.CHField{CHName: "ts", CHType: "UInt64", TimeUnit: models.Nanoseconds}
+	day := models.CHField{CHName: "day", CHType: "Date"}
+
+	testData := []struct {
+		caseName string
+		field    models.CHField
+		value    reflect.Value
+		format   string
+		result   string
+	}{
+		{caseName: "default time format", field: timestamp, value: nanos, result: `"` + ts.In(time.Local).Format(time.RFC3339Nano) + `"`},
+		{caseName: "date_time", field: timestamp, value: nanos, format: "date_time", result: `"2019-06-10T12:30:15.123Z"`},
+		{caseName: "epoch_millis", field: timestamp, value: nanos, format: "epoch_millis", result: `"1560169815123"`},
+		{caseName: "epoch_second", field: timestamp, value: nanos, format: "epoch_second", result: `"1560169815"`},
+		{caseName: "date column", field: day, value: date, result: `"2019-06-10"`},
+		{caseName: "string", field: models.CHField{CHName: "host", CHType: "String"}, value: reflect.ValueOf("gate-1"), format: "date_time", result: `"gate-1"`},
+	}
+
+	for _, test := range testData {
+		if result := printFormattedValues(test.field, &test.value, test.format); result != test.result {
+			t.Error("For", test.caseName, "\n expected: ", test.result, "\n got: ", result)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package responses
+
+import (
+	"sort"
+
+	"kibouse/adapter/requests"
+	"kibouse/data/wrappers"
+)
+
+// hitSource returns source of the hit with fields matched by the source filter by their kibana names,
+// whole data of the hit is returned if filter is not set.
+func (ri *ResponseInputs) hitSource(item wrappers.DataItem) interface{} {
+	if ri.source == nil {
+		return item.Data()
+	}
+	if ri.source.Disabled {
+		return nil
+	}
+
+	scheme := item.ModelScheme()
+	fields, ok := ri.sourceFields[scheme]
+	if !ok {
+		fields = requests.SourceFields(ri.source, scheme)
+		ri.sourceFields[scheme] = fields
+	}
+	source := make(map[string]interface{}, len(fields))
+	for _, name := range fields {
+		// null values are returned as null
+		source[scheme.DataFields[name].KibanaName] = nil
+		if value, ok := item.AttrValue(name); ok {
+			source[scheme.DataFields[name].KibanaName] = value.Interface()
+		}
+	}
+	return source
+}
+
+// hitFields returns values of docvalue and script fields of the hit.
+func (ri *ResponseInputs) hitFields(item wrappers.DataItem) *docValueFieldsSectionMarshaller {
+	scheme := item.ModelScheme()
+	values := make([]docValueField, 0, len(ri.docValueFields)+len(ri.scriptFields))
+	appendValue := func(name string, column string, format string) {
+		field, known := scheme.DataFields[column]
+		if value, ok := item.AttrValue(column); ok && known {
+			values = append(values, docValueField{
+				FieldData: FieldData{value: value, field: field.CHField},
+				name:      name,
+				format:    format,
+			})
+		}
+	}
+
+	for _, name := range ri.docValueFields {
+		appendValue(name, name, ri.docValueFormat[name])
+	}
+	names := make([]string, 0, len(ri.scriptFields))
+	for name := range ri.scriptFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		appendValue(name, ri.scriptFields[name], "")
+	}
+	return &docValueFieldsSectionMarshaller{values: values}
+}
@@ -0,0 +1,211 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"kibouse/data/models"
+)
+
+// SQLColumn describes column of elasticsearch SQL response.
+type SQLColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// sqlTextMinWidth is the min width of columns of text SQL response.
+const sqlTextMinWidth = 15
+
+// sqlTypeNames maps elastic types to SQL types reported by DESCRIBE statement.
+var sqlTypeNames = map[string]string{
+	"long":    "BIGINT",
+	"float":   "REAL",
+	"double":  "DOUBLE",
+	"keyword": "VARCHAR",
+	"text":    "VARCHAR",
+	"date":    "TIMESTAMP",
+	"boolean": "BOOLEAN",
+	"ip":      "IP",
+	"object":  "STRUCT",
+}
+
+// SQLColumnType returns elasticsearch SQL type of values of the clickhouse type.
+func SQLColumnType(chType string) string {
+	elasticType := clickhouseTypeToElastic(models.CHField{CHType: chType})
+	if elasticType == "date" {
+		return "datetime"
+	}
+	return elasticType
+}
+
+// SQLTablesRows returns columns and rows of SHOW TABLES statement response, logs tables are listed as indices.
+func SQLTablesRows(tables []string) ([]SQLColumn, [][]interface{}) {
+	columns := []SQLColumn{{Name: "name", Type: "keyword"}, {Name: "type", Type: "keyword"}, {Name: "kind", Type: "keyword"}}
+	names := append([]string{}, tables...)
+	sort.Strings(names)
+	rows := make([][]interface{}, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, []interface{}{name, "TABLE", "INDEX"})
+	}
+	return columns, rows
+}
+
+// SQLDescribeRows returns columns and rows of DESCRIBE statement response listing fields of the model
+// by kibana names with their SQL and elastic types.
+func SQLDescribeRows(scheme *models.ModelInfo) ([]SQLColumn, [][]interface{}) {
+	columns := []SQLColumn{{Name: "column", Type: "keyword"}, {Name: "type", Type: "keyword"}, {Name: "mapping", Type: "keyword"}}
+	fields := make([]*models.FieldProps, 0, len(scheme.DataFields))
+	for name, field := range scheme.DataFields {
+		if name != "_table" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].KibanaName < fields[j].KibanaName
+	})
+	rows := make([][]interface{}, 0, len(fields))
+	for _, field := range fields {
+		elasticType := clickhouseTypeToElastic(field.CHField)
+		rows = append(rows, []interface{}{field.KibanaName, sqlTypeNames[elasticType], elasticType})
+	}
+	return columns, rows
+}
+
+// sqlValue converts value read from clickhouse to value of json response, dates are formatted in UTC.
+func sqlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format("2006-01-02T15:04:05.000Z")
+	case []byte:
+		return string(v)
+	case net.IP:
+		return v.String()
+	}
+	return value
+}
+
+// sqlText converts value read from clickhouse to text of txt and csv responses.
+func sqlText(value interface{}) string {
+	value = sqlValue(value)
+	if value == nil {
+		return "null"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		text, _ := json.Marshal(value)
+		return string(text)
+	}
+	return fmt.Sprint(value)
+}
+
+type sqlResponse struct {
+	Columns []SQLColumn     `json:"columns,omitempty"`
+	Rows    [][]interface{} `json:"rows"`
+	Cursor  string          `json:"cursor,omitempty"`
+}
+
+// CreateSQLResponseJSON returns page of SQL query results in json format, columns are not set for the
+// following pages requested by cursor. Cursor is returned if there are more rows.
+func CreateSQLResponseJSON(columns []SQLColumn, rows [][]interface{}, cursor string) ([]byte, error) {
+	response := sqlResponse{Columns: columns, Rows: make([][]interface{}, 0, len(rows)), Cursor: cursor}
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for i := range row {
+			values[i] = sqlValue(row[i])
+		}
+		response.Rows = append(response.Rows, values)
+	}
+	return json.Marshal(&response)
+}
+
+// CreateSQLResponseText returns page of SQL query results as text table, header with names of columns
+// is added to the first page only.
+func CreateSQLResponseText(columns []SQLColumn, rows [][]interface{}) string {
+	texts := make([][]string, 0, len(rows))
+	width := 0
+	for _, row := range rows {
+		values := make([]string, len(row))
+		for i := range row {
+			values[i] = sqlText(row[i])
+		}
+		texts = append(texts, values)
+		width = len(values)
+	}
+	if len(columns) > 0 {
+		width = len(columns)
+	}
+
+	widths := make([]int, width)
+	for i := range widths {
+		widths[i] = sqlTextMinWidth
+		if i < len(columns) && len(columns[i].Name) > widths[i] {
+			widths[i] = len(columns[i].Name)
+		}
+	}
+	for _, values := range texts {
+		for i, value := range values {
+			if len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+
+	text := strings.Builder{}
+	if len(columns) > 0 {
+		header := make([]string, len(columns))
+		separator := make([]string, len(columns))
+		for i, column := range columns {
+			// names of columns are centered
+			left := (widths[i] - len(column.Name)) / 2
+			header[i] = strings.Repeat(" ", left) + column.Name + strings.Repeat(" ", widths[i]-len(column.Name)-left)
+			separator[i] = strings.Repeat("-", widths[i])
+		}
+		text.WriteString(strings.Join(header, "|") + "\n")
+		text.WriteString(strings.Join(separator, "+") + "\n")
+	}
+	for _, values := range texts {
+		for i, value := range values {
+			values[i] = value + strings.Repeat(" ", widths[i]-len(value))
+		}
+		text.WriteString(strings.Join(values, "|") + "\n")
+	}
+	return text.String()
+}
+
+// CreateSQLResponseCSV returns page of SQL query results in csv format, header with names of columns
+// is added to the first page only. Null values are empty.
+func CreateSQLResponseCSV(columns []SQLColumn, rows [][]interface{}) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := csv.NewWriter(&buffer)
+	writer.UseCRLF = true
+	if len(columns) > 0 {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		writer.Write(header)
+	}
+	for _, row := range rows {
+		values := make([]string, len(row))
+		for i := range row {
+			if row[i] != nil {
+				values[i] = sqlText(row[i])
+			}
+		}
+		writer.Write(values)
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// CreateSQLCloseResponse returns response of closing SQL cursor.
+func CreateSQLCloseResponse(succeeded bool) string {
+	return fmt.Sprintf(`{"succeeded":%t}`, succeeded)
+}
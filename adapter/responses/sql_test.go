@@ -0,0 +1,70 @@
+package responses
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSQLResponseFormats(t *testing.T) {
+	columns := []SQLColumn{{Name: "hostname", Type: "keyword"}, {Name: "ts", Type: "datetime"}, {Name: "count", Type: "long"}}
+	rows := [][]interface{}{
+		{"gate-1", time.Date(2020, 5, 26, 12, 0, 0, 0, time.UTC), uint64(42)},
+		{"gate, 2", time.Date(2020, 5, 26, 13, 30, 0, 500000000, time.UTC), nil},
+	}
+
+	testData := []struct {
+		caseName string
+		format   string
+		columns  []SQLColumn
+		cursor   string
+		expected string
+	}{
+		{
+			caseName: "json page with cursor",
+			format:   "json",
+			columns:  columns,
+			cursor:   "abc",
+			expected: `{"columns":[{"name":"hostname","type":"keyword"},{"name":"ts","type":"datetime"},{"name":"count","type":"long"}],` +
+				`"rows":[["gate-1","2020-05-26T12:00:00.000Z",42],["gate, 2","2020-05-26T13:30:00.500Z",null]],"cursor":"abc"}`,
+		},
+		{
+			caseName: "json page requested by cursor",
+			format:   "json",
+			expected: `{"rows":[["gate-1","2020-05-26T12:00:00.000Z",42],["gate, 2","2020-05-26T13:30:00.500Z",null]]}`,
+		},
+		{
+			caseName: "text",
+			format:   "txt",
+			columns:  columns,
+			expected: "   hostname    |           ts           |     count     \n" +
+				"---------------+------------------------+---------------\n" +
+				"gate-1         |2020-05-26T12:00:00.000Z|42             \n" +
+				"gate, 2        |2020-05-26T13:30:00.500Z|null           \n",
+		},
+		{
+			caseName: "csv",
+			format:   "csv",
+			columns:  columns,
+			expected: "hostname,ts,count\r\n" +
+				"gate-1,2020-05-26T12:00:00.000Z,42\r\n" +
+				"\"gate, 2\",2020-05-26T13:30:00.500Z,\r\n",
+		},
+	}
+
+	for _, test := range testData {
+		var got string
+		switch test.format {
+		case "json":
+			response, _ := CreateSQLResponseJSON(test.columns, rows, test.cursor)
+			got = string(response)
+		case "txt":
+			got = CreateSQLResponseText(test.columns, rows)
+		case "csv":
+			response, _ := CreateSQLResponseCSV(test.columns, rows)
+			got = string(response)
+		}
+		if got != test.expected {
+			t.Error("For", test.caseName, "\n expected: ", test.expected, "\n got: ", got)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package responses
 
-import "fmt"
+import (
+	"fmt"
+
+	"kibouse/adapter/settings"
+)
 
 const NotFoundResponseTemplate = `{
   "error": {
@@ -24,7 +28,15 @@ const NotFoundResponseTemplate = `{
   "status": 404
 }`
 
-const ResponseTemplate = `{"_index":".kibana","_type":"%s","_id":"%s","_version":6,"result":"updated","_shards":{"total":1,"successful":1,"failed":0},"_seq_no":361,"_primary_term":38}`
+const ResponseTemplate = `{"_index":"%s","_type":"%s","_id":"%s","_version":6,"result":"%s","_shards":{"total":1,"successful":1,"failed":0},"_seq_no":361,"_primary_term":38}`
+
+const ScrollNotFoundResponseTemplate = `{"error":{"root_cause":[{"type":"search_context_missing_exception","reason":"No search context found for id [%s]"}],"type":"search_phase_execution_exception","reason":"all shards failed","phase":"query","grouped":true},"status":404}`
+
+const ClearScrollResponseTemplate = `{"succeeded":true,"num_freed":%d}`
+
+const CountResponseTemplate = `{"count":%d,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0}}`
+
+const DocNotFoundResponseTemplate = `{"_index":"%s","_type":"%s","_id":"%s","found":false}`
 
 func CreateDataNotFoundResponse() string {
 	return "{\"hits\":{\"total\":0}}"
@@ -34,6 +46,31 @@ func CreateIndexNotFoundResponse(id string) string {
 	return fmt.Sprintf(NotFoundResponseTemplate, id, id, id, id)
 }
 
-func CreateUpdatingResponse(typename string, id string) string {
-	return fmt.Sprintf(ResponseTemplate, typename, id)
+func CreateUpdatingResponse(index string, typename string, id string, format settings.Format) string {
+	return fmt.Sprintf(ResponseTemplate, index, format.DocumentType(typename), format.DocumentID(typename, id), "updated")
+}
+
+func CreateDeletingResponse(index string, typename string, id string, format settings.Format) string {
+	return fmt.Sprintf(ResponseTemplate, index, format.DocumentType(typename), format.DocumentID(typename, id), "deleted")
+}
+
+func CreateDocNotFoundResponse(index string, typename string, id string, format settings.Format) string {
+	return fmt.Sprintf(DocNotFoundResponseTemplate, index, format.DocumentType(typename), format.DocumentID(typename, id))
+}
+
+// CreateLogsDocNotFoundResponse returns response of elastic GET request for the missing logs document.
+func CreateLogsDocNotFoundResponse(index string, typename string, id string) string {
+	return fmt.Sprintf(DocNotFoundResponseTemplate, index, typename, id)
+}
+
+func CreateScrollNotFoundResponse(id string) string {
+	return fmt.Sprintf(ScrollNotFoundResponseTemplate, id)
+}
+
+func CreateCountResponse(count int) string {
+	return fmt.Sprintf(CountResponseTemplate, count)
+}
+
+func CreateClearScrollResponse(freed int) string {
+	return fmt.Sprintf(ClearScrollResponseTemplate, freed)
 }
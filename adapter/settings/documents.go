@@ -0,0 +1,223 @@
+package settings
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+)
+
+// Supported kibana major versions, they store saved objects in .kibana index documents of different formats.
+const (
+	Kibana5 = 5
+	Kibana6 = 6
+	Kibana7 = 7
+)
+
+// SavedObjectTypes lists types of kibana saved objects stored in the settings table.
+var SavedObjectTypes = []string{
+	"visualization",
+	"dashboard",
+	"config",
+	"search",
+	"url",
+	"server",
+	"timelion-sheet",
+	"index-pattern",
+}
+
+// OpenSearchFormat is the format of documents expected by OpenSearch Dashboards, it is forked from kibana 7.10.
+const OpenSearchFormat = Format(Kibana7)
+
+// Format is the format of saved objects documents expected by kibana of the specific major version.
+type Format int
+
+var kibanaMajorVersion = Kibana5
+var kibanaVersionMutex = &sync.RWMutex{}
+
+// tablesFormats contains formats of saved objects stored in settings tables other than kibana one.
+var tablesFormats = map[string]Format{}
+var tablesFormatsMutex = &sync.RWMutex{}
+
+// ParseMajorVersion returns major part of kibana version, e.g. 6 for "6.8.0".
+func ParseMajorVersion(version string) (int, error) {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot parse kibana version "+version)
+	}
+	if major < Kibana5 || major > Kibana7 {
+		return 0, errors.New("unsupported kibana version " + version)
+	}
+	return major, nil
+}
+
+// SetKibanaVersion selects format of .kibana documents according to the served kibana version.
+func SetKibanaVersion(version string) error {
+	major, err := ParseMajorVersion(version)
+	if err != nil {
+		return err
+	}
+	kibanaVersionMutex.Lock()
+	defer kibanaVersionMutex.Unlock()
+	kibanaMajorVersion = major
+	return nil
+}
+
+// KibanaMajorVersion returns major version of the served kibana.
+func KibanaMajorVersion() int {
+	kibanaVersionMutex.RLock()
+	defer kibanaVersionMutex.RUnlock()
+	return kibanaMajorVersion
+}
+
+// KibanaFormat returns format of documents expected by the served kibana.
+func KibanaFormat() Format {
+	return Format(KibanaMajorVersion())
+}
+
+// SetTableFormat sets format of saved objects stored in the settings table of other UI.
+func SetTableFormat(table string, format Format) {
+	tablesFormatsMutex.Lock()
+	defer tablesFormatsMutex.Unlock()
+	tablesFormats[table] = format
+}
+
+// TableFormat returns format of saved objects stored in the settings table.
+func TableFormat(table string) Format {
+	tablesFormatsMutex.RLock()
+	defer tablesFormatsMutex.RUnlock()
+	if format, ok := tablesFormats[table]; ok {
+		return format
+	}
+	return KibanaFormat()
+}
+
+// DocumentType returns _type of documents stored in the table: kibana 6.x uses single "doc" type
+// for all documents, types are removed in 7.x.
+func (f Format) DocumentType(table string) string {
+	switch f {
+	case Kibana6:
+		return "doc"
+	case Kibana7:
+		return "_doc"
+	}
+	return table
+}
+
+// DocumentID returns id of .kibana document with saved object, since 6.x it is prefixed with the object type.
+func (f Format) DocumentID(objectType string, id string) string {
+	if f < Kibana6 || objectType == "" {
+		return id
+	}
+	return objectType + ":" + id
+}
+
+// ParseDocumentID splits id of .kibana document to saved object type and id.
+func (f Format) ParseDocumentID(docID string) (string, string) {
+	if f < Kibana6 {
+		return "", docID
+	}
+	if pos := strings.Index(docID, ":"); pos != -1 {
+		return docID[:pos], docID[pos+1:]
+	}
+	return "", docID
+}
+
+// DocumentSource returns _source of .kibana document with saved object,
+// since 6.x object attributes are nested into the object named by its type:
+// {"type":"index-pattern","index-pattern":{"title":"logs_*"},"updated_at":"..."}.
+func (f Format) DocumentSource(source *ElasticSettings) interface{} {
+	if source == nil || f < Kibana6 {
+		return source
+	}
+	return savedObject{ElasticSettings: source, format: f}
+}
+
+type savedObject struct {
+	*ElasticSettings
+	format Format
+}
+
+func (so savedObject) MarshalJSON() ([]byte, error) {
+	attrs := *so.ElasticSettings
+	attrs.Type = ""
+	attrs.UpdatedAt = ""
+	bytes, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &attributes); err != nil {
+		return nil, err
+	}
+	delete(attributes, "type")
+	delete(attributes, "updated_at")
+
+	doc := map[string]interface{}{
+		"type":       so.Type,
+		so.Type:      attributes,
+		"updated_at": so.UpdatedAt,
+	}
+	if so.format >= Kibana7 {
+		doc["references"] = []interface{}{}
+	}
+	return json.Marshal(doc)
+}
+
+// ParseDocumentSource reads saved object from _source of .kibana document of both flat (5.x)
+// and nested (6.x, 7.x) formats, type of object could be omitted in the source of partial updates.
+func ParseDocumentSource(body []byte, objectType string) (*ElasticSettings, error) {
+	var source ElasticSettings
+	if err := json.Unmarshal(body, &source); err != nil {
+		return nil, errors.Wrap(err, "cannot parse kibana saved object")
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, errors.Wrap(err, "cannot parse kibana saved object")
+	}
+	if source.Type == "" {
+		source.Type = objectType
+	}
+	attributes, ok := doc[source.Type]
+	if source.Type == "" || !ok {
+		return &source, nil
+	}
+
+	var nested ElasticSettings
+	if err := json.Unmarshal(attributes, &nested); err != nil {
+		return nil, errors.Wrap(err, "cannot parse attributes of kibana saved object "+source.Type)
+	}
+	nested.Type = source.Type
+	nested.UpdatedAt = source.UpdatedAt
+	return &nested, nil
+}
+
+// DocumentFields adds names of saved objects attributes used by kibana 6.x and 7.x,
+// e.g. "index-pattern.title", to the fields of settings table.
+func (f Format) DocumentFields(fields map[string]*models.FieldProps) map[string]*models.FieldProps {
+	if f < Kibana6 {
+		return fields
+	}
+	documentFields := make(map[string]*models.FieldProps, len(fields)*(len(SavedObjectTypes)+1))
+	for name, field := range fields {
+		documentFields[name] = field
+		for _, objectType := range SavedObjectTypes {
+			documentFields[objectType+"."+name] = field
+		}
+	}
+	return documentFields
+}
+
+// ParseOpenSearchMajorVersion returns major part of OpenSearch Dashboards version, e.g. 2 for "2.11.0".
+func ParseOpenSearchMajorVersion(version string) (int, error) {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil || major < 1 {
+		return 0, errors.New("unsupported OpenSearch Dashboards version " + version)
+	}
+	return major, nil
+}
@@ -0,0 +1,173 @@
+package settings
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentID(t *testing.T) {
+	defer SetKibanaVersion("5.6.8")
+
+	testData := []struct {
+		caseName   string
+		version    string
+		objectType string
+		id         string
+		docID      string
+	}{
+		{
+			caseName:   "kibana 5.x document id",
+			version:    "5.6.8",
+			objectType: "index-pattern",
+			id:         "logs",
+			docID:      "logs",
+		},
+		{
+			caseName:   "kibana 6.x document id",
+			version:    "6.8.0",
+			objectType: "index-pattern",
+			id:         "logs",
+			docID:      "index-pattern:logs",
+		},
+		{
+			caseName:   "kibana 7.x config document id",
+			version:    "7.10.2",
+			objectType: "config",
+			id:         "7.10.2",
+			docID:      "config:7.10.2",
+		},
+	}
+
+	for _, test := range testData {
+		if err := SetKibanaVersion(test.version); err != nil {
+			t.Fatal(err)
+		}
+		if docID := KibanaFormat().DocumentID(test.objectType, test.id); docID != test.docID {
+			t.Error("For", test.caseName, "\n expected: ", test.docID, "\n got: ", docID)
+		}
+		objectType, id := KibanaFormat().ParseDocumentID(test.docID)
+		if test.version[0] != '5' && (objectType != test.objectType || id != test.id) {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.objectType, test.id,
+				"\n got: ", objectType, id,
+			)
+		}
+	}
+}
+
+func TestDocumentSource(t *testing.T) {
+	defer SetKibanaVersion("5.6.8")
+
+	source := &ElasticSettings{Type: "index-pattern", UpdatedAt: "2019-01-01T00:00:00.000Z", Title: "logs_*", TimeFieldName: "ts"}
+	testData := []struct {
+		caseName string
+		version  string
+		result   string
+	}{
+		{
+			caseName: "kibana 5.x flat source",
+			version:  "5.6.8",
+			result:   `{"type":"index-pattern","updated_at":"2019-01-01T00:00:00.000Z","timeFieldName":"ts","title":"logs_*","kibanaSavedObjectMeta":{"searchSourceJSON":""},"refreshInterval":{"display":"","pause":false,"section":0,"value":0}}`,
+		},
+		{
+			caseName: "kibana 6.x nested source",
+			version:  "6.8.0",
+			result:   `{"index-pattern":{"kibanaSavedObjectMeta":{"searchSourceJSON":""},"refreshInterval":{"display":"","pause":false,"section":0,"value":0},"timeFieldName":"ts","title":"logs_*"},"type":"index-pattern","updated_at":"2019-01-01T00:00:00.000Z"}`,
+		},
+		{
+			caseName: "kibana 7.x nested source with references",
+			version:  "7.10.2",
+			result:   `{"index-pattern":{"kibanaSavedObjectMeta":{"searchSourceJSON":""},"refreshInterval":{"display":"","pause":false,"section":0,"value":0},"timeFieldName":"ts","title":"logs_*"},"references":[],"type":"index-pattern","updated_at":"2019-01-01T00:00:00.000Z"}`,
+		},
+	}
+
+	for _, test := range testData {
+		if err := SetKibanaVersion(test.version); err != nil {
+			t.Fatal(err)
+		}
+		result, err := json.Marshal(KibanaFormat().DocumentSource(source))
+		if err != nil || string(result) != test.result {
+			t.Error("For", test.caseName, "\n expected: ", test.result, "\n got: ", string(result), err)
+		}
+	}
+}
+
+func TestParseDocumentSource(t *testing.T) {
+	testData := []struct {
+		caseName   string
+		body       string
+		objectType string
+		result     ElasticSettings
+	}{
+		{
+			caseName: "flat source",
+			body:     `{"title":"logs_*","timeFieldName":"ts"}`,
+			result:   ElasticSettings{Title: "logs_*", TimeFieldName: "ts"},
+		},
+		{
+			caseName: "nested source",
+			body:     `{"type":"index-pattern","updated_at":"2019-01-01T00:00:00.000Z","index-pattern":{"title":"logs_*","timeFieldName":"ts"}}`,
+			result:   ElasticSettings{Type: "index-pattern", UpdatedAt: "2019-01-01T00:00:00.000Z", Title: "logs_*", TimeFieldName: "ts"},
+		},
+		{
+			caseName:   "partial update without object type",
+			body:       `{"config":{"defaultIndex":"logs"}}`,
+			objectType: "config",
+			result:     ElasticSettings{Type: "config", DefaultIndex: "logs"},
+		},
+	}
+
+	for _, test := range testData {
+		result, err := ParseDocumentSource([]byte(test.body), test.objectType)
+		if err != nil || !reflect.DeepEqual(*result, test.result) {
+			t.Error("For", test.caseName, "\n expected: ", test.result, "\n got: ", result, err)
+		}
+	}
+}
+
+func TestParseMajorVersion(t *testing.T) {
+	testData := []struct {
+		version string
+		major   int
+		valid   bool
+	}{
+		{version: "5.6.8", major: Kibana5, valid: true},
+		{version: "6.8.0", major: Kibana6, valid: true},
+		{version: "7.10.2", major: Kibana7, valid: true},
+		{version: "4.6.0", valid: false},
+		{version: "latest", valid: false},
+	}
+
+	for _, test := range testData {
+		major, err := ParseMajorVersion(test.version)
+		if (err == nil) != test.valid || major != test.major {
+			t.Error("For", test.version, "\n expected: ", test.major, test.valid, "\n got: ", major, err)
+		}
+	}
+}
+
+func TestTableFormat(t *testing.T) {
+	defer SetKibanaVersion("5.6.8")
+	if err := SetKibanaVersion("6.8.0"); err != nil {
+		t.Fatal(err)
+	}
+	SetTableFormat("opensearch_dashboards", OpenSearchFormat)
+
+	testData := []struct {
+		table  string
+		docID  string
+		format Format
+	}{
+		{table: "kibana", docID: "index-pattern:logs", format: Kibana6},
+		{table: "opensearch_dashboards", docID: "index-pattern:logs", format: Kibana7},
+	}
+
+	for _, test := range testData {
+		format := TableFormat(test.table)
+		if format != test.format || format.DocumentID("index-pattern", "logs") != test.docID {
+			t.Error("For", test.table, "\n expected: ", test.format, "\n got: ", format)
+		}
+	}
+}
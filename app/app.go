@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 	"log"
@@ -12,11 +15,14 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
+	"kibouse/adapter/requests"
+	"kibouse/adapter/settings"
 	"kibouse/app/handlers"
 	"kibouse/clickhouse"
 	"kibouse/config"
 	"kibouse/data/models"
 	"kibouse/db"
+	"kibouse/index"
 	"kibouse/logging"
 	"kibouse/setup"
 )
@@ -31,6 +37,8 @@ const (
 type handlerRoutes struct {
 	route   string
 	handler handlers.HandlerConstructor
+	// all methods are accepted if not set
+	methods []string
 }
 
 // App is the main type holding app's settings and processing external http requests
@@ -75,7 +83,7 @@ func (app *App) init() error {
 		return err
 	}
 
-	if err = db.InitLogsDbConnection(connection, db.DataBaseName); err != nil {
+	if err = db.InitLogsDbConnection(connection, db.DataBaseName, app.cfg.Databases()...); err != nil {
 		return err
 	}
 
@@ -86,9 +94,40 @@ func (app *App) init() error {
 		}
 	}
 
+	// format of .kibana documents depends on kibana version
+	if err := settings.SetKibanaVersion(app.cfg.KibanaVersion()); err != nil {
+		return err
+	}
+
+	// OpenSearch Dashboards saved objects are stored in the separate table,
+	// it is created on demand independently of kibana configuration reset
+	if app.cfg.OpenSearchEnabled() {
+		if err := setup.InitOpenSearchSettings(app.cfg); err != nil {
+			return err
+		}
+		settings.SetTableFormat(models.OpenSearchSettingsTableName, settings.OpenSearchFormat)
+	}
+
+	// deep pages of hits are requested by search_after or scroll
+	requests.SetMaxResultWindow(app.cfg.MaxResultWindow())
+	requests.SetMaxScrollKeepAlive(app.cfg.MaxScrollKeepAlive())
+	requests.SetTotalHitsCacheTTL(app.cfg.TotalHitsCacheTTL())
+
+	// models declared in config are processed the same way as compiled-in ones
+	if err := models.RegisterModels(app.cfg.Models()); err != nil {
+		return err
+	}
+
+	// full text search backend is selected per logs table
+	tables := models.GetLogsTablesSchemas()
+	for tableName := range tables {
+		if err := index.SetTableBackend(tableName, app.cfg.FullTextSearchBackend(tableName)); err != nil {
+			return err
+		}
+	}
+
 	// create tables for logs delivery and storing (if not exists yet)
 	if app.cfg.CreateChTables() {
-		tables := models.GetLogsTablesSchemas()
 		for tableName := range tables {
 			ok, err := db.TableExists(tableName)
 			if err != nil {
@@ -97,13 +136,33 @@ func (app *App) init() error {
 			if ok {
 				continue
 			}
-			err = clickhouse.CreateDataDeliveryQueue(tableName, tableName, tables[tableName], app.cfg.GetKafkaSource())
+			err = clickhouse.CreateDataDeliveryQueue(
+				tableName,
+				tableName,
+				tables[tableName],
+				app.cfg.GetKafkaSource(),
+				app.storageSettings(tableName),
+			)
 			if err != nil {
 				logrus.Error(fmt.Sprintf("%+v", err))
 			}
 		}
 	}
 
+	// alter existing tables according to the changed models
+	if app.cfg.MigrateChTables() {
+		for tableName := range tables {
+			app.migrateTable(tableName, tables[tableName])
+		}
+	}
+
+	// full text search should split searched text the same way as indexer does
+	for _, table := range app.cfg.IndexedTables() {
+		if err := index.SetTableAnalyzer(table.Table, table.Analyzer); err != nil {
+			return err
+		}
+	}
+
 	r := mux.NewRouter()
 
 	var targeting = adapterToClickhouse
@@ -128,8 +187,11 @@ func (app *App) init() error {
 	}
 
 	// create handlers for all API endpoints
-	for _, handler := range initAppHandlerParams(targeting) {
-		r.PathPrefix(handler.route).HandlerFunc(handler.handler(context))
+	for _, handler := range initAppHandlerParams(targeting, app.cfg.KibanaMajorVersion(), app.cfg.OpenSearchEnabled()) {
+		route := r.PathPrefix(handler.route).HandlerFunc(handler.handler(context))
+		if len(handler.methods) > 0 {
+			route.Methods(handler.methods...)
+		}
 	}
 
 	app.server = createServer(app.cfg.GetListeningPort(), r)
@@ -137,9 +199,51 @@ func (app *App) init() error {
 	return nil
 }
 
-func initAppHandlerParams(target proxyTarget) []handlerRoutes {
+// migrateTable alters existing logs table and recreates its delivery queues if model was changed.
+func (app *App) migrateTable(table string, dataStruct reflect.Type) {
+	if ok, err := db.TableExists(table); err != nil || !ok {
+		return
+	}
+	plan, err := clickhouse.PlanMigration(table, table, dataStruct, app.cfg.GetKafkaSource())
+	if err != nil {
+		logrus.Error(fmt.Sprintf("%+v", err))
+		return
+	}
+	if plan.Empty() {
+		return
+	}
+	for _, query := range plan.Queries {
+		logrus.Info("migration of " + table + ": " + query)
+	}
+	if err := plan.Apply(); err != nil {
+		logrus.Error(fmt.Sprintf("%+v", err))
+	}
+}
+
+// storageSettings returns settings of full text search data skipping indexes and data retention for the logs table.
+func (app *App) storageSettings(table string) clickhouse.StorageSettings {
+	params := app.cfg.FullTextSearchSkipIndex()
+	retention := app.cfg.Retention(table)
+	return clickhouse.StorageSettings{
+		FullTextIndex: clickhouse.FullTextIndex{
+			Backend:         index.GetTableBackend(table),
+			BloomFilterSize: params.BloomFilterSize,
+			HashFunctions:   params.HashFunctions,
+			Seed:            params.Seed,
+			NgramSize:       params.NgramSize,
+			Granularity:     params.Granularity,
+		},
+		Retention: clickhouse.Retention{
+			Logs:          retention.Logs,
+			InvertedIndex: retention.InvertedIndex,
+			Histogram:     retention.Histogram,
+		},
+	}
+}
+
+func initAppHandlerParams(target proxyTarget, kibanaMajor int, openSearch bool) []handlerRoutes {
 	if target == adapterToClickhouse {
-		return []handlerRoutes{
+		routes := []handlerRoutes{
 			{
 				route:   "/_msearch",
 				handler: handlers.MultiSearchHandler,
@@ -148,14 +252,12 @@ func initAppHandlerParams(target proxyTarget) []handlerRoutes {
 				route:   "/{index}/_msearch",
 				handler: handlers.MultiSearchHandler,
 			},
-			{
-				route:   "/.kibana/{type:visualization|dashboard|config|search|url|server|timelion-sheet|index-pattern}/{id}",
-				handler: handlers.UpdateSettingsHandler,
-			},
-			{
-				route:   "/.kibana/{type:visualization|dashboard|config|search|url|server|timelion-sheet|index-pattern}",
-				handler: handlers.UpdateSettingsHandler,
-			},
+		}
+		routes = append(routes, kibanaSettingsRoutes(kibanaMajor)...)
+		if openSearch {
+			routes = append(routes, openSearchSettingsRoutes()...)
+		}
+		return append(routes, []handlerRoutes{
 			{
 				route:   "/.kibana/_delete_by_query",
 				handler: handlers.DeleteSettingsHandler,
@@ -164,19 +266,85 @@ func initAppHandlerParams(target proxyTarget) []handlerRoutes {
 				route:   "/_mget",
 				handler: handlers.MultiGetRequestsHandler,
 			},
+			{
+				route:   "/_search/scroll/{scroll_id}",
+				handler: handlers.ScrollHandler,
+			},
+			{
+				route:   "/_search/scroll",
+				handler: handlers.ScrollHandler,
+			},
+			{
+				route:   "/_sql/close",
+				handler: handlers.SQLCloseHandler,
+			},
+			{
+				route:   "/_xpack/sql/close",
+				handler: handlers.SQLCloseHandler,
+			},
+			{
+				route:   "/_sql",
+				handler: handlers.SQLHandler,
+			},
+			{
+				route:   "/_xpack/sql",
+				handler: handlers.SQLHandler,
+			},
+			{
+				route:   "/_cat/indices/{index}",
+				handler: handlers.CatIndicesHandler,
+			},
+			{
+				route:   "/_cat/indices",
+				handler: handlers.CatIndicesHandler,
+			},
+			{
+				route:   "/_aliases",
+				handler: handlers.AliasesHandler,
+			},
+			{
+				route:   "/_resolve/index/{index}",
+				handler: handlers.ResolveIndexHandler,
+			},
+			{
+				route:   "/_cluster/health/{index}",
+				handler: handlers.ClusterHealthHandler,
+			},
+			{
+				route:   "/_cluster/health",
+				handler: handlers.ClusterHealthHandler,
+			},
+			{
+				route:   "/_mapping",
+				handler: handlers.IndexMappingHandler,
+			},
 			{
 				route:   "/{index}/_search",
 				handler: handlers.SearchHandler,
 			},
+			{
+				route:   "/{index}/_count",
+				handler: handlers.CountHandler,
+			},
 			{
 				route:   "/{index}/_field_caps",
 				handler: handlers.ElasticMappingBuildHandler,
 			},
+			{
+				route:   "/{index}/_mapping",
+				handler: handlers.IndexMappingHandler,
+			},
+			{
+				// names of indices and document types could not start with underscore except typeless _doc
+				route:   "/{index:[^_][^/]*}/{type:_doc|[^_][^/]*}/{id}",
+				handler: handlers.GetDocumentHandler,
+				methods: []string{http.MethodGet, http.MethodHead},
+			},
 			{
 				route:   "/",
 				handler: handlers.StaticRequestsHandler,
 			},
-		}
+		}...)
 	}
 	if target == proxyToElastic {
 		return []handlerRoutes{
@@ -189,6 +357,75 @@ func initAppHandlerParams(target proxyTarget) []handlerRoutes {
 	return nil
 }
 
+// kibanaSettingsRoutes returns routes of .kibana documents api: kibana 5.x uses saved object types as document types,
+// 6.x stores all objects as documents of "doc" type, 7.x uses typeless api.
+func kibanaSettingsRoutes(kibanaMajor int) []handlerRoutes {
+	switch kibanaMajor {
+	case settings.Kibana6, settings.Kibana7:
+		return savedObjectsRoutes(indexRoute(".kibana"), settings.Format(kibanaMajor))
+	}
+	types := strings.Join(settings.SavedObjectTypes, "|")
+	return []handlerRoutes{
+		{
+			route:   "/.kibana/{type:" + types + "}/{id}",
+			handler: handlers.UpdateSettingsHandler,
+		},
+		{
+			route:   "/.kibana/{type:" + types + "}",
+			handler: handlers.UpdateSettingsHandler,
+		},
+	}
+}
+
+// openSearchSettingsRoutes returns routes of .opensearch_dashboards documents api, it is the same as kibana 7.x one.
+func openSearchSettingsRoutes() []handlerRoutes {
+	index := indexRoute("." + models.OpenSearchSettingsTableName)
+	return append(savedObjectsRoutes(index, settings.OpenSearchFormat), handlerRoutes{
+		route:   index + "/_delete_by_query",
+		handler: handlers.DeleteSettingsHandler,
+	})
+}
+
+// indexRoute returns route of the index, its name is passed to handlers as url parameter.
+func indexRoute(index string) string {
+	return "/{index:" + regexp.QuoteMeta(index) + "}"
+}
+
+// savedObjectsRoutes returns routes of saved objects api of the index.
+func savedObjectsRoutes(index string, format settings.Format) []handlerRoutes {
+	update, create, doc := index+"/_update/{id}", index+"/_create/{id}", index+"/_doc/{id}"
+	if format == settings.Kibana6 {
+		update, create, doc = index+"/doc/{id}/_update", index+"/doc/{id}/_create", index+"/doc/{id}"
+	}
+	return []handlerRoutes{
+		{
+			route:   update,
+			handler: handlers.UpdateSettingsHandler,
+			methods: []string{http.MethodPost},
+		},
+		{
+			route:   create,
+			handler: handlers.UpdateSettingsHandler,
+			methods: []string{http.MethodPut, http.MethodPost},
+		},
+		{
+			route:   doc,
+			handler: handlers.GetSettingHandler,
+			methods: []string{http.MethodGet, http.MethodHead},
+		},
+		{
+			route:   doc,
+			handler: handlers.DeleteSettingHandler,
+			methods: []string{http.MethodDelete},
+		},
+		{
+			route:   doc,
+			handler: handlers.UpdateSettingsHandler,
+			methods: []string{http.MethodPut, http.MethodPost},
+		},
+	}
+}
+
 func createLogger(cfg *config.AppConfig) *logrus.Logger {
 	logger := logrus.New()
 	if cfg.LogDebugMessages() {
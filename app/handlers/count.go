@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"kibouse/adapter/requests"
+	"kibouse/adapter/responses"
+	"kibouse/clickhouse"
+)
+
+// CountHandler returns handler for elastic _count requests returning number of documents matching the query.
+func CountHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(w, r, context.RuntimeLog)
+		if err != nil {
+			return
+		}
+		index, ok := context.URL.FetchParam(r, "index")
+		if !ok {
+			writeResponseError(w, errors.New("cannot fetch index name from url"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+
+		provider, err := clickhouse.NewProvider(index)
+		if err != nil {
+			response := responses.CreateIndexNotFoundResponse(index)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+
+		// all documents are counted if query is not set
+		if len(bytes.TrimSpace(body)) == 0 {
+			body = []byte("{}")
+		}
+		esReq, err := requests.ParseElasticJSON(body, provider.DataScheme())
+		if err != nil {
+			writeResponseError(w, errors.Wrap(err, "request body has incorrect format"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		count, err := esReq.CountHits(provider, 0)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		response := responses.CreateCountResponse(count)
+		writeResponseSuccess(w, &response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
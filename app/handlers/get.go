@@ -2,14 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"kibouse/clickhouse"
 	"kibouse/data/models"
+	"kibouse/data/wrappers"
+	"kibouse/db"
 	"kibouse/adapter/responses"
 	"kibouse/adapter/requests/queries"
+	"kibouse/adapter/settings"
 )
 
 // ElasticMappingBuildHandler returns elastic field capabilities json, required for adding
@@ -29,7 +34,12 @@ func ElasticMappingBuildHandler(context HandlerContext) http.HandlerFunc {
 			return
 		}
 
-		response, err := responses.CreateFieldCapsJSON(provider.DataScheme().DataFields)
+		// fields of each table are reported separately for detecting type conflicts
+		indices := make(map[string]map[string]*models.FieldProps)
+		for table, scheme := range provider.TablesSchemes() {
+			indices[table] = expandSubFields(table, scheme, context.RuntimeLog)
+		}
+		response, err := responses.CreateIndicesFieldCapsJSON(indices)
 		if err != nil {
 			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
 			return
@@ -43,6 +53,141 @@ func ElasticMappingBuildHandler(context HandlerContext) http.HandlerFunc {
 	return handler
 }
 
+// IndexMappingHandler returns elastic mapping of index built from models of its tables,
+// required for listing fields of grafana elasticsearch data source.
+func IndexMappingHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// mappings of kibana settings indices are predefined
+		if response, ok := staticResponse(context, r); ok {
+			writeResponseSuccess(w, &response)
+			return
+		}
+
+		// mapping of all logs tables is returned if index is not set
+		index, ok := context.URL.FetchParam(r, "index")
+		if !ok || index == "_all" {
+			index = "*"
+		}
+
+		provider, err := clickhouse.NewProvider(index)
+		if err != nil {
+			response := responses.CreateIndexNotFoundResponse(index)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+
+		// mapping types are removed in elasticsearch 7.x
+		format := requestFormat(context, r)
+		indices := make(map[string]map[string]*models.FieldProps)
+		docTypes := make(map[string]string)
+		for table, scheme := range provider.TablesSchemes() {
+			indices[table] = expandSubFields(table, scheme, context.RuntimeLog)
+			if format < settings.Kibana7 {
+				docTypes[table] = format.DocumentType(table)
+			}
+		}
+		response, err := responses.CreateIndicesMappingJSON(indices, docTypes)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		writeBytesResponseSuccess(w, response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// expandSubFields adds sub-fields for all keys of Map columns and paths of JSON payloads to the model fields.
+func expandSubFields(table string, model *models.ModelInfo, logger *logrus.Logger) map[string]*models.FieldProps {
+	fields := make(map[string]*models.FieldProps, len(model.DataFields))
+	for name, field := range model.DataFields {
+		fields[name] = field
+		keys := make([]string, 0)
+		switch {
+		case field.IsMap():
+			mapKeys, err := clickhouse.LoadMapKeys(table, field.CHName)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("%+v", err))
+				continue
+			}
+			keys = mapKeys
+		case field.IsJSONBlob():
+			// paths are sampled by data provider
+			for path := range model.JSONPaths[field.CHName] {
+				keys = append(keys, path)
+			}
+		}
+		for _, key := range keys {
+			if subField, ok := model.GetField(name + "." + key); ok {
+				fields[name+"."+key] = subField
+			}
+		}
+	}
+	return fields
+}
+
+// GetDocumentHandler returns logs document by its id, required for kibana document view.
+// Documents are identified by values of the model uuid field.
+func GetDocumentHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		index, okIndex := context.URL.FetchParam(r, "index")
+		id, okID := context.URL.FetchParam(r, "id")
+		if !okIndex || !okID {
+			writeResponseError(w, errors.New("cannot fetch element id from url"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		docType, _ := context.URL.FetchParam(r, "type")
+
+		provider, err := clickhouse.NewProvider(index)
+		if err != nil {
+			response := responses.CreateIndexNotFoundResponse(index)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+
+		rows, err := findDocument(provider, id)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		var item wrappers.DataItem
+		if rows != nil {
+			item = rows.NextItem()
+		}
+		if item == nil {
+			response := responses.CreateLogsDocNotFoundResponse(index, docType, id)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+
+		response, err := responses.CreateDocJSON(index, item, requestFormat(context, r))
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		writeResponseSuccess(w, &response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// findDocument selects logs document with the id, nothing is selected if model has no uuid field.
+func findDocument(provider db.DataProvider, id string) (wrappers.ChDataWrapper, error) {
+	uuid, ok := provider.DataScheme().GetUuidField()
+	if !ok {
+		return nil, nil
+	}
+	req := clickhouse.NewRequestTpl(provider).
+		Where(queries.NewIdsClause(&uuid.CHField, []string{id}).String()).
+		Limit(1)
+	return provider.FetchData(req)
+}
+
 // MultiGetRequestsHandler is the handler for elastic _mget requests
 // used for multiple data fetching from kibana settings table
 func MultiGetRequestsHandler(context HandlerContext) http.HandlerFunc {
@@ -70,16 +215,22 @@ func MultiGetRequestsHandler(context HandlerContext) http.HandlerFunc {
 			return
 		}
 
-		req := clickhouse.NewRequestTpl(provider.DataTable()).
+		req := clickhouse.NewRequestTpl(provider).
 			What("*").
-			Final(provider.DataTable() == models.SettingsTableName).
+			Final(models.IsSettingsTable(provider.DataTable())).
 			Limit(len(requiredIds))
 
 		for i := range requiredIds {
-			req.WhereOr(queries.NewStringMatch("_id", requiredIds[i].ID).String())
+			id := requiredIds[i].ID
+			if models.IsSettingsTable(provider.DataTable()) {
+				// since kibana 6.x ids of saved objects are prefixed with their types
+				_, id = settings.TableFormat(provider.DataTable()).ParseDocumentID(id)
+			}
+			req.WhereOr(queries.NewStringMatch("_id", id).String())
 		}
 
 		response := responses.NewDocItemsResponseBuilder()
+		response.UseFormat(requestFormat(context, r))
 
 		response.AddIndex(provider.DataTable())
 
@@ -106,7 +257,7 @@ func MultiGetRequestsHandler(context HandlerContext) http.HandlerFunc {
 // StaticRequestsHandler returns predefined static elastic response for provided uri
 func StaticRequestsHandler(context HandlerContext) http.HandlerFunc {
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		response, ok := context.Cfg.StaticResponse(r.RequestURI)
+		response, ok := staticResponse(context, r)
 		if !ok {
 			context.RuntimeLog.Debugf("unsupported request: %s", r.RequestURI)
 		}
@@ -116,6 +267,14 @@ func StaticRequestsHandler(context HandlerContext) http.HandlerFunc {
 	return handler
 }
 
+// staticResponse returns predefined response for uri of the request in format of the requesting UI.
+func staticResponse(context HandlerContext, r *http.Request) (string, bool) {
+	if context.Cfg.OpenSearchEnabled() && isOpenSearchDashboards(r) {
+		return context.Cfg.OpenSearchStaticResponse(r.RequestURI)
+	}
+	return context.Cfg.StaticResponse(r.RequestURI)
+}
+
 type itemIdentifier struct {
 	Index string `json:"_index"`
 	ID    string `json:"_id"`
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"kibouse/adapter/responses"
+	"kibouse/clickhouse"
+	"kibouse/db"
+)
+
+// indexTables returns logs tables matching comma separated list of index patterns, empty pattern
+// and _all match all tables. The first of concrete indices which is not found is returned as missing.
+func indexTables(pattern string) ([]string, string) {
+	if pattern == "" || pattern == "_all" {
+		pattern = "*"
+	}
+	tables := make([]string, 0)
+	found := make(map[string]struct{})
+	for _, index := range strings.Split(pattern, ",") {
+		index = strings.TrimSpace(index)
+		matched, err := db.GetTablesByPattern(index)
+		if err != nil && !strings.ContainsAny(index, "*?") {
+			return nil, index
+		}
+		for _, table := range matched {
+			if _, ok := found[table]; !ok {
+				found[table] = struct{}{}
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Strings(tables)
+	return tables, ""
+}
+
+// CatIndicesHandler returns list of logs tables with numbers of rows and sizes of tables in format
+// of elastic _cat/indices api, required for external tools listing indices.
+func CatIndicesHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		index, _ := context.URL.FetchParam(r, "index")
+		tables, missing := indexTables(index)
+		if missing != "" {
+			response := responses.CreateIndexNotFoundResponse(missing)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+
+		stats, err := clickhouse.LoadTablesStats(tables)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		indices := make([]responses.IndexStats, 0, len(tables))
+		for _, table := range tables {
+			indices = append(indices, responses.IndexStats{
+				Name:      table,
+				Health:    responses.HealthGreen,
+				DocsCount: stats[table].Rows,
+				StoreSize: stats[table].Bytes,
+			})
+		}
+
+		params := r.URL.Query()
+		columns := catColumns(params.Get("h"), responses.CatIndicesColumns)
+		if params.Get("format") == "json" {
+			response, err := responses.CreateCatIndicesJSON(indices, columns, params.Get("bytes"))
+			if err != nil {
+				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+				return
+			}
+			writeBytesResponseSuccess(w, response)
+			return
+		}
+
+		// verbose flag is set without value as "?v"
+		_, verbose := params["v"]
+		verbose = verbose && params.Get("v") != "false"
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, responses.CreateCatIndicesText(indices, columns, params.Get("bytes"), verbose))
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// catColumns returns columns of _cat api response selected by "h" parameter, unknown columns are skipped.
+func catColumns(selected string, columns []string) []string {
+	if selected == "" {
+		return columns
+	}
+	known := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		known[column] = struct{}{}
+	}
+	result := make([]string, 0, len(columns))
+	for _, column := range strings.Split(selected, ",") {
+		if _, ok := known[strings.TrimSpace(column)]; ok {
+			result = append(result, strings.TrimSpace(column))
+		}
+	}
+	return result
+}
+
+// AliasesHandler returns aliases of logs tables, tables have no aliases, but tools list indices by this api.
+func AliasesHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		tables, _ := indexTables("")
+		response, err := responses.CreateAliasesJSON(tables)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		writeBytesResponseSuccess(w, response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// ResolveIndexHandler returns logs tables matching index patterns, required for kibana 7.x index pattern wizard.
+func ResolveIndexHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		index, _ := context.URL.FetchParam(r, "index")
+		tables, missing := indexTables(index)
+		if missing != "" {
+			response := responses.CreateIndexNotFoundResponse(missing)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+		response, err := responses.CreateResolveIndexJSON(tables)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		writeBytesResponseSuccess(w, response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// ClusterHealthHandler returns health of the cluster, it is green while clickhouse is reachable and red otherwise.
+// Request times out if health is worse than the required by wait_for_status parameter.
+func ClusterHealthHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		status := responses.HealthGreen
+		if err := db.Ping(); err != nil {
+			context.RuntimeLog.Warn(fmt.Sprintf("%+v", err))
+			status = responses.HealthRed
+		}
+
+		index, _ := context.URL.FetchParam(r, "index")
+		tables, _ := indexTables(index)
+		indices := len(tables)
+		// kibana settings indices are stored in kibouse database and always exist
+		for _, name := range strings.Split(index, ",") {
+			if strings.HasPrefix(strings.TrimSpace(name), ".") {
+				indices++
+			}
+		}
+
+		code := http.StatusOK
+		required := r.URL.Query().Get("wait_for_status")
+		timedOut := required != "" && !responses.HealthSatisfies(status, required)
+		if timedOut {
+			code = http.StatusRequestTimeout
+		}
+		response, err := responses.CreateClusterHealthJSON(status, indices, timedOut)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		body := string(response)
+		writeResponseJSON(w, &body, code)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
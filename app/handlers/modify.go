@@ -23,13 +23,13 @@ import (
 // UpdateSettingsHandler updates and inserts kibana settings entry by its id
 func UpdateSettingsHandler(context HandlerContext) http.HandlerFunc {
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		t, ok := context.URL.FetchParam(r, "type")
-		if !ok {
-			writeResponseError(w, errors.New("cannot fetch element type from url"), http.StatusBadRequest, context.RuntimeLog)
+		table := settingsTable(context, r)
+		t, id, err := fetchSettingsID(context, r, table)
+		if err != nil {
+			writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
 			return
 		}
-		id, ok := context.URL.FetchParam(r, "id")
-		if !ok {
+		if id == "" {
 			id = fmt.Sprintf("%s_%d", t, time.Now().UnixNano())
 		}
 
@@ -39,26 +39,27 @@ func UpdateSettingsHandler(context HandlerContext) http.HandlerFunc {
 			return
 		}
 
-		var elasticSettings settings.ElasticSettings
-		err = json.Unmarshal(body, &elasticSettings)
-
 		// for updating kibana table entries, data may be wrapped by additional service structure {"doc":{...}}
 		if strings.Contains(r.RequestURI, "/_update") {
 			doc := struct {
-				Doc settings.ElasticSettings `json:"doc"`
+				Doc json.RawMessage `json:"doc"`
 			}{}
-			err = json.Unmarshal(body, &doc)
-			elasticSettings = doc.Doc
+			if err = json.Unmarshal(body, &doc); err != nil {
+				writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+				return
+			}
+			body = doc.Doc
 		}
+		elasticSettings, err := settings.ParseDocumentSource(body, t)
 		if err != nil {
 			writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
 			return
 		}
 
 		elasticSettings.Type = t
-		elasticSettingsItem := settings.CreateElasticSettingsItem(id, &elasticSettings)
+		elasticSettingsItem := settings.CreateElasticSettingsItem(id, elasticSettings)
 
-		provider, err := clickhouse.NewProvider(models.SettingsTableName)
+		provider, err := clickhouse.NewProvider(table)
 		if err != nil {
 			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
 			return
@@ -70,7 +71,47 @@ func UpdateSettingsHandler(context HandlerContext) http.HandlerFunc {
 			return
 		}
 
-		response := responses.CreateUpdatingResponse(t, id)
+		response := responses.CreateUpdatingResponse("."+table, t, id, settings.TableFormat(table))
+		writeResponseSuccess(w, &response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// GetSettingHandler returns kibana settings entry by its id (kibana 6.x and 7.x).
+func GetSettingHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		table := settingsTable(context, r)
+		t, id, err := fetchSettingsID(context, r, table)
+		if err != nil || id == "" {
+			writeResponseError(w, errors.New("cannot fetch element id from url"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		provider, err := clickhouse.NewProvider(table)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		cfg, err := findSettings(settingsIDMatch(t, id), provider)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+		item := cfg.NextItem()
+		if item == nil {
+			response := responses.CreateDocNotFoundResponse("."+table, t, id, settings.TableFormat(table))
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+
+		response, err := responses.CreateDocJSON("."+table, item, settings.TableFormat(table))
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
 		writeResponseSuccess(w, &response)
 	}
 	if context.HttpLog != nil {
@@ -79,13 +120,70 @@ func UpdateSettingsHandler(context HandlerContext) http.HandlerFunc {
 	return handler
 }
 
+// DeleteSettingHandler removes kibana settings entry by its id (kibana 6.x and 7.x).
+func DeleteSettingHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		table := settingsTable(context, r)
+		t, id, err := fetchSettingsID(context, r, table)
+		if err != nil || id == "" {
+			writeResponseError(w, errors.New("cannot fetch element id from url"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		provider, err := clickhouse.NewProvider(table)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		if err = removeByCond(settingsIDMatch(t, id), provider); err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		response := responses.CreateDeletingResponse("."+table, t, id, settings.TableFormat(table))
+		writeResponseSuccess(w, &response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// settingsTable returns table with saved objects of the index from url, kibana settings table is used by default.
+func settingsTable(context HandlerContext, r *http.Request) string {
+	if index, ok := context.URL.FetchParam(r, "index"); ok {
+		return strings.TrimPrefix(index, ".")
+	}
+	return models.SettingsTableName
+}
+
+// fetchSettingsID returns type and id of kibana settings entry from url,
+// since kibana 6.x the type is the part of document id ("type:id").
+func fetchSettingsID(context HandlerContext, r *http.Request, table string) (string, string, error) {
+	id, _ := context.URL.FetchParam(r, "id")
+	if t, ok := context.URL.FetchParam(r, "type"); ok {
+		return t, id, nil
+	}
+	t, id := settings.TableFormat(table).ParseDocumentID(id)
+	if t == "" {
+		return "", "", errors.New("cannot fetch element type from url")
+	}
+	return t, id, nil
+}
+
+func settingsIDMatch(t string, id string) queries.Clause {
+	return queries.NewEmptyMustSection().
+		AppendChild(queries.NewStringMatch("_id", id)).
+		AppendChild(queries.NewStringMatch("type", t))
+}
+
 func DeleteSettingsHandler(context HandlerContext) http.HandlerFunc {
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		body, err := readBody(w, r, context.RuntimeLog)
 		if err != nil {
 			return
 		}
-		provider, err := clickhouse.NewProvider(models.SettingsTableName)
+		provider, err := clickhouse.NewProvider(settingsTable(context, r))
 		if err != nil {
 			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
 			return
@@ -117,7 +215,7 @@ func update(cfg *models.ClickhouseSettings, conn db.DataProvider) error {
 		return err
 	}
 
-	return insert(cfg)
+	return insert(conn.DataTable(), cfg)
 }
 
 // removeByID updates settings table row as marked for collapsing.
@@ -126,7 +224,7 @@ func removeByID(id string, conn db.DataProvider) error {
 }
 
 func findSettings(query queries.Clause, conn db.DataProvider) (*wrappers.KibanaSettings, error) {
-	rows, err := conn.FetchData(clickhouse.NewRequestTpl(models.SettingsTableName).Where(query.String()).Final(true))
+	rows, err := conn.FetchData(clickhouse.NewRequestTpl(conn).Where(query.String()).Final(true))
 	if err != nil {
 		return nil, errors.Wrap(err, "kibana settings selection failed")
 	}
@@ -146,7 +244,7 @@ func removeByCond(query queries.Clause, conn db.DataProvider) error {
 
 	for item := cfg.NextClickhouseSettings(); item != nil; item = cfg.NextClickhouseSettings() {
 		item.Sign = -1
-		err := insert(item)
+		err := insert(conn.DataTable(), item)
 		if err != nil {
 			return errors.Wrap(err, "cannot remove kibana settings record with id = " + item.ID)
 		}
@@ -155,6 +253,6 @@ func removeByCond(query queries.Clause, conn db.DataProvider) error {
 	return nil
 }
 
-func insert(cfg *models.ClickhouseSettings) error {
-	return db.InsertIntoTable(models.SettingsTableName, *cfg)
+func insert(table string, cfg *models.ClickhouseSettings) error {
+	return db.InsertIntoTable(table, *cfg)
 }
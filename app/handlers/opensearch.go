@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"kibouse/adapter/settings"
+)
+
+// isOpenSearchDashboards checks that request is sent by OpenSearch Dashboards,
+// its clients are recognized by product origin header or user agent.
+func isOpenSearchDashboards(r *http.Request) bool {
+	if r.Header.Get("X-Opensearch-Product-Origin") != "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(r.UserAgent()), "opensearch")
+}
+
+// requestFormat returns format of documents expected by UI sent the request.
+func requestFormat(context HandlerContext, r *http.Request) settings.Format {
+	if context.Cfg != nil && context.Cfg.OpenSearchEnabled() && isOpenSearchDashboards(r) {
+		return settings.OpenSearchFormat
+	}
+	return settings.KibanaFormat()
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"kibouse/adapter/requests"
+	"kibouse/adapter/responses"
+	"kibouse/clickhouse"
+	"kibouse/db"
+)
+
+// ScrollHandler returns handler for elastic _search/scroll requests: GET and POST requests return
+// the next page of scroll hits, DELETE requests remove scroll contexts.
+func ScrollHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(w, r, context.RuntimeLog)
+		if err != nil {
+			return
+		}
+		params, err := parseScrollParams(context, r, body)
+		if err != nil {
+			writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if len(params.ids) == 0 {
+				writeResponseError(w, errors.New("no scroll ids specified"), http.StatusBadRequest, context.RuntimeLog)
+				return
+			}
+			var freed int
+			if len(params.ids) == 1 && params.ids[0] == "_all" {
+				freed = requests.ClearScrolls(nil)
+			} else {
+				freed = requests.ClearScrolls(params.ids)
+			}
+			response := responses.CreateClearScrollResponse(freed)
+			if freed == 0 {
+				writeResponseJSON(w, &response, http.StatusNotFound)
+				return
+			}
+			writeResponseSuccess(w, &response)
+			return
+		}
+
+		if len(params.ids) != 1 {
+			writeResponseError(w, errors.New("single scroll id should be specified"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		var keepAlive time.Duration
+		if params.keepAlive != "" {
+			if keepAlive, err = requests.ParseKeepAlive(params.keepAlive); err != nil {
+				writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+				return
+			}
+		}
+
+		id := params.ids[0]
+		scroll, ok := requests.GetScroll(id, keepAlive)
+		if !ok {
+			response := responses.CreateScrollNotFoundResponse(id)
+			writeResponseJSON(w, &response, http.StatusNotFound)
+			return
+		}
+		provider, err := clickhouse.NewProvider(scroll.Index)
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		response, err := executeScroll(id, scroll, provider, createResponseBuilder(context, r, false))
+		if err != nil {
+			writeResponseError(w, err, searchErrorStatus(err), context.RuntimeLog)
+			return
+		}
+		writeResponseSuccess(w, &response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// scrollParams contains scroll ids and keep alive time set by body, url query or path of _search/scroll request.
+type scrollParams struct {
+	ids       []string
+	keepAlive string
+}
+
+func parseScrollParams(context HandlerContext, r *http.Request, body []byte) (scrollParams, error) {
+	params := scrollParams{keepAlive: r.URL.Query().Get("scroll")}
+	if id, ok := context.URL.FetchParam(r, "scroll_id"); ok && id != "" {
+		params.ids = strings.Split(id, ",")
+	}
+	if id := r.URL.Query().Get("scroll_id"); id != "" {
+		params.ids = strings.Split(id, ",")
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return params, nil
+	}
+
+	var cfg struct {
+		ScrollID interface{} `json:"scroll_id"`
+		Scroll   string      `json:"scroll"`
+	}
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return params, errors.Wrap(err, "cannot parse body of scroll request")
+	}
+	switch id := cfg.ScrollID.(type) {
+	case string:
+		params.ids = []string{id}
+	case []interface{}:
+		params.ids = make([]string, 0, len(id))
+		for _, item := range id {
+			if item, ok := item.(string); ok {
+				params.ids = append(params.ids, item)
+			}
+		}
+	}
+	if cfg.Scroll != "" {
+		params.keepAlive = cfg.Scroll
+	}
+	return params, nil
+}
+
+// openScroll creates scroll context of the search request and returns the first page of its hits.
+func openScroll(index string, body []byte, keepAlive time.Duration, conn db.DataProvider, response responses.Builder) (string, error) {
+	id, err := requests.OpenScroll(index, body, keepAlive)
+	if err != nil {
+		return "", err
+	}
+	scroll, _ := requests.GetScroll(id, 0)
+	return executeScroll(id, scroll, conn, response)
+}
+
+// executeScroll returns the next page of scroll hits and moves scroll context to the following page.
+func executeScroll(id string, scroll requests.ScrollContext, conn db.DataProvider, response responses.Builder) (string, error) {
+	esReq, err := requests.ParseElasticJSON(scroll.Body, conn.DataScheme())
+	if err != nil {
+		return "", err
+	}
+	esReq.SetScrollPage(scroll)
+	// aggregations are returned with the first page only
+	if scroll.From > 0 {
+		esReq.Aggregations = nil
+	}
+
+	response.AddScrollID(id)
+	result, err := executeSearch(esReq, conn, response)
+	if err != nil {
+		return "", err
+	}
+	searchAfter, hits := lastHitSort(result)
+	if !esReq.HasTieBreaker() {
+		// pages of hits without unique sort values are selected by offset
+		searchAfter = nil
+	}
+	requests.MoveScroll(id, searchAfter, hits)
+	return result, nil
+}
+
+// lastHitSort returns sort values of the last hit of search response and number of its hits.
+func lastHitSort(response string) ([]interface{}, int) {
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				Sort []interface{} `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(response))
+	decoder.UseNumber()
+	if err := decoder.Decode(&parsed); err != nil || len(parsed.Hits.Hits) == 0 {
+		return nil, 0
+	}
+	hits := parsed.Hits.Hits
+	if sort := hits[len(hits)-1].Sort; len(sort) > 0 {
+		return sort, len(hits)
+	}
+	return nil, len(hits)
+}
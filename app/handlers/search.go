@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"bytes"
 	"net/http"
 	"time"
 
@@ -27,53 +26,38 @@ func MultiSearchHandler(context HandlerContext) http.HandlerFunc {
 
 		var response string
 		builder := responses.NewFullResponseBuilder(true)
+		builder.TotalHitsAsInt(totalHitsAsInt(r))
+		builder.UseFormat(requestFormat(context, r))
 
-		for len(body) > 0 {
+		searches, err := requests.ParseMultiSearch(body)
+		if err != nil {
+			writeResponseError(w, errors.Wrap(err, "request body has incorrect format"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
 
+		for _, search := range searches {
+			// index set by header overrides index from URL
 			index, ok := context.URL.FetchParam(r, "index")
-			indexJSONEnding := bytes.IndexByte(body, '}')
-			nextRequestMarker := []byte(`{}`)
-			// index not set in URL, get it from received JSON
-			if !ok {
-				if indexJSONEnding == -1 {
-					writeResponseError(w, errors.New("request body has incorrect format"), http.StatusBadRequest, context.RuntimeLog)
-					return
-				}
-
-				indexJSON, err := requests.ParseElasticJSON(body[:indexJSONEnding+1], nil)
-				if err != nil {
-					writeResponseError(w, errors.Wrap(err,"cannot get json with Elastic index from request body"), http.StatusBadRequest, context.RuntimeLog)
-					return
-				}
-
-				if index = indexJSON.Index; index == "" {
-					writeResponseError(w, errors.New("cannot fetch Elastic index from json"), http.StatusBadRequest, context.RuntimeLog)
-					return
-				}
-				nextRequestMarker = []byte(`{"index":`)
+			if search.Index != "" {
+				index, ok = search.Index, true
 			}
-
-			body = body[indexJSONEnding+1:]
-
-			provider, err := clickhouse.NewProvider(index)
-			if err != nil {
-				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			if !ok {
+				writeResponseError(w, errors.New("cannot fetch Elastic index from json"), http.StatusBadRequest, context.RuntimeLog)
 				return
 			}
 
-			requestParamsEnding := bytes.Index(body, nextRequestMarker)
-			if requestParamsEnding == -1 {
-				requestParamsEnding = len(body)
+			provider, err := clickhouse.NewProvider(index)
+			if err != nil && search.IgnoreUnavailable {
+				context.RuntimeLog.Debugf("unavailable index %s is ignored: %s", index, err.Error())
+				response, err = emptySearchResult(index, builder)
+			} else if err == nil {
+				response, err = executeRequest(search.Body, provider, builder, context.RuntimeLog)
 			}
 
-			response, err = executeRequest(body[:requestParamsEnding], provider, builder, context.RuntimeLog)
-
 			if err != nil {
-				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+				writeResponseError(w, err, searchErrorStatus(err), context.RuntimeLog)
 				return
 			}
-
-			body = body[requestParamsEnding:]
 		}
 
 		writeResponseSuccess(w, &response)
@@ -111,14 +95,20 @@ func SearchHandler(context HandlerContext) http.HandlerFunc {
 				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
 				return
 			}
-			response, err = executeRequest(
-				body,
-				provider,
-				createResponseBuilder(r, false),
-				context.RuntimeLog,
-			)
+			builder := createResponseBuilder(context, r, false)
+			// scroll url parameter sets time the scroll context is kept for requesting the next page
+			if scroll := r.URL.Query().Get("scroll"); scroll != "" {
+				keepAlive, err := requests.ParseKeepAlive(scroll)
+				if err != nil {
+					writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+					return
+				}
+				response, err = openScroll(index, body, keepAlive, provider, builder)
+			} else {
+				response, err = executeRequest(body, provider, builder, context.RuntimeLog)
+			}
 			if err != nil {
-				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+				writeResponseError(w, err, searchErrorStatus(err), context.RuntimeLog)
 				return
 			}
 		}
@@ -131,7 +121,7 @@ func SearchHandler(context HandlerContext) http.HandlerFunc {
 	return handler
 }
 
-func createResponseBuilder(r *http.Request, multipleReq bool) responses.Builder {
+func createResponseBuilder(context HandlerContext, r *http.Request, multipleReq bool) responses.Builder {
 	if r == nil {
 		return nil
 	}
@@ -139,7 +129,15 @@ func createResponseBuilder(r *http.Request, multipleReq bool) responses.Builder
 	if filter != nil && filter[0] == "aggregations.types.buckets" {
 		return responses.NewAggOnlyResponseBuilder()
 	}
-	return responses.NewFullResponseBuilder(multipleReq)
+	builder := responses.NewFullResponseBuilder(multipleReq)
+	builder.TotalHitsAsInt(totalHitsAsInt(r))
+	builder.UseFormat(requestFormat(context, r))
+	return builder
+}
+
+// totalHitsAsInt checks that kibana 7.x requested number of found documents as integer instead of object.
+func totalHitsAsInt(r *http.Request) bool {
+	return r.URL.Query().Get("rest_total_hits_as_int") == "true"
 }
 
 func executeRequest(request []byte, conn db.DataProvider, response responses.Builder, log *logrus.Logger) (string, error) {
@@ -147,7 +145,11 @@ func executeRequest(request []byte, conn db.DataProvider, response responses.Bui
 	if err != nil {
 		return "", err
 	}
+	return executeSearch(esReq, conn, response)
+}
 
+// executeSearch returns response with hits and aggregations of the parsed search request.
+func executeSearch(esReq requests.ElasticRequest, conn db.DataProvider, response responses.Builder) (string, error) {
 	setResponseParams(&esReq, conn.DataTable(), response)
 
 	aggRes, err := aggregateData(conn, esReq)
@@ -156,8 +158,18 @@ func executeRequest(request []byte, conn db.DataProvider, response responses.Bui
 	}
 	response.AddAggregationResult(aggRes)
 
-	if aggRes != nil {
-		if newLowerBound, ok := reduceLogsSelectionTimeRange(aggRes.Buckets.Buckets, uint64(esReq.Size)); ok {
+	// settings are selected entirely, so number of found settings is the number of returned hits
+	if !models.IsSettingsTable(conn.DataTable()) {
+		total, relation, err := esReq.TotalHits(conn, aggRes)
+		if err != nil {
+			return "", err
+		}
+		response.AddTotalHits(total, relation)
+	}
+
+	// hits following search_after values could be older than the latest ones
+	if aggRes != nil && esReq.SearchAfter == nil {
+		if newLowerBound, ok := reduceLogsSelectionTimeRange(aggRes.Buckets.Buckets, uint64(esReq.From+esReq.Size)); ok {
 			esReq.UpdateLogsLowerTimeRange(newLowerBound)
 		}
 	}
@@ -166,10 +178,32 @@ func executeRequest(request []byte, conn db.DataProvider, response responses.Bui
 	if err != nil {
 		return "", err
 	}
-	if hits != nil {
-		response.AddHits(hits)
+	// builder of _msearch response is reused for all requests
+	response.AddHits(hits)
+
+	return response.CreateElasticJSON()
+}
+
+// searchErrorStatus returns http status of the failed search request.
+func searchErrorStatus(err error) int {
+	if _, ok := errors.Cause(err).(*requests.ResultWindowError); ok {
+		return http.StatusBadRequest
 	}
+	return http.StatusInternalServerError
+}
 
+// emptySearchResult adds response without hits and aggregations for the unavailable index.
+func emptySearchResult(index string, response responses.Builder) (string, error) {
+	response.AddIndex(index)
+	response.AddSorting(nil)
+	response.AddDocValueFields(nil)
+	response.AddDocValueFormat(nil)
+	response.AddScriptFields(nil)
+	response.AddSourceFilter(nil)
+	response.AddHighlight(nil)
+	response.AddAggregationResult(nil)
+	response.AddTotalHits(0, requests.TotalHitsEqual)
+	response.AddHits(nil)
 	return response.CreateElasticJSON()
 }
 
@@ -180,26 +214,32 @@ func reduceLogsSelectionTimeRange(aggrBuckets []aggregations.Bucket, size uint64
 	for i := len(aggrBuckets) - 1; i >= 0; i-- {
 		docCount += aggrBuckets[i].DocCount()
 		if docCount > size {
-			return aggrBuckets[i].Key().(time.Time), true
+			// buckets of other aggregations (e.g. terms) do not contain time
+			key, ok := aggrBuckets[i].Key().(time.Time)
+			return key, ok
 		}
 	}
 	return time.Now(), false
 }
 
 func queryData(conn db.DataProvider, req requests.ElasticRequest) (wrappers.ChDataWrapper, error) {
-	clickhouseRequest := clickhouse.NewRequestTpl(conn.DataTable())
+	clickhouseRequest := clickhouse.NewRequestTpl(conn)
 
-	if req.Query != nil {
-		clickhouseRequest.Where(req.Query.String())
+	if condition := req.QueryCondition(); condition != "" {
+		clickhouseRequest.Where(condition)
+	}
+	if req.SearchAfter != nil {
+		clickhouseRequest.WhereAnd(req.SearchAfter.String())
 	}
 
 	// getting kibana settings
-	if conn.DataTable() == models.SettingsTableName {
+	if models.IsSettingsTable(conn.DataTable()) {
 		clickhouseRequest.Final(true)
 	} else if req.Size == 0 { // getting logs data
 		return nil, nil
 	} else {
-		clickhouseRequest.Limit(req.Size)
+		clickhouse.SelectColumns(clickhouseRequest, req.HitsColumns())
+		clickhouseRequest.Limit(req.Size).Offset(req.From)
 	}
 
 	clickhouseRequest.OrderBy(req.Sorting.String())
@@ -219,10 +259,15 @@ func aggregateData(conn db.DataProvider, req requests.ElasticRequest) (*aggregat
 
 func setResponseParams(req *requests.ElasticRequest, table string, response responses.Builder) {
 	response.AddIndex(table)
-	if len(req.DocValueFields) > 0 {
-		response.AddDocValueFields(req.DocValueFields)
-	}
-	if len(req.SortingFields) > 0 {
-		response.AddSorting(req.SortingFields)
+	response.AddDocValueFields(req.DocValueFields)
+	response.AddDocValueFormat(req.DocValueFormat)
+	response.AddScriptFields(req.ScriptFields)
+	response.AddHighlight(req.Highlight)
+	response.AddSorting(req.SortingFields)
+	// kibana settings are returned as they are stored
+	if models.IsSettingsTable(table) {
+		response.AddSourceFilter(nil)
+	} else {
+		response.AddSourceFilter(&req.Source)
 	}
 }
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"kibouse/adapter/requests"
+	"kibouse/adapter/responses"
+	"kibouse/clickhouse"
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+// sqlPage contains page of SQL query results, columns are set for the first page only.
+type sqlPage struct {
+	columns []responses.SQLColumn
+	rows    [][]interface{}
+	cursor  string
+}
+
+// SQLHandler returns handler for elasticsearch _sql requests: statements are translated to clickhouse
+// queries of logs tables, results are paged by cursors and returned in json, txt or csv format.
+func SQLHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(w, r, context.RuntimeLog)
+		if err != nil {
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "txt" && format != "csv" {
+			writeResponseError(w, errors.New("unsupported format of SQL response: "+format), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		req, err := requests.ParseSQLRequest(body)
+		if err != nil {
+			writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		timeout := requests.DefaultSQLPageTimeout
+		if req.PageTimeout != "" {
+			if timeout, err = requests.ParseKeepAlive(req.PageTimeout); err != nil {
+				writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+				return
+			}
+		}
+
+		var page sqlPage
+		if req.Cursor != "" {
+			cursor, ok := requests.GetSQLCursor(req.Cursor)
+			if !ok {
+				writeResponseError(w, errors.New("SQL cursor is not found: "+req.Cursor), http.StatusNotFound, context.RuntimeLog)
+				return
+			}
+			page, err = fetchSQLPage(req.Cursor, cursor, nil, timeout)
+		} else {
+			var statement *requests.SQLStatement
+			if statement, err = requests.ParseSQL(req.Query, req.Params); err != nil {
+				writeResponseError(w, err, http.StatusBadRequest, context.RuntimeLog)
+				return
+			}
+			var provider db.DataProvider
+			if statement.Kind != requests.SQLShowTables && statement.Index != "" {
+				if provider, err = clickhouse.NewProvider(statement.Index); err != nil {
+					response := responses.CreateIndexNotFoundResponse(statement.Index)
+					writeResponseJSON(w, &response, http.StatusNotFound)
+					return
+				}
+			}
+			page, err = executeSQL(statement, provider, req.FetchSize, timeout)
+		}
+		if err != nil {
+			writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+			return
+		}
+
+		switch format {
+		case "txt":
+			writeSQLText(w, []byte(responses.CreateSQLResponseText(page.columns, page.rows)), "text/plain", page.cursor)
+		case "csv":
+			response, err := responses.CreateSQLResponseCSV(page.columns, page.rows)
+			if err != nil {
+				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+				return
+			}
+			writeSQLText(w, response, "text/csv", page.cursor)
+		default:
+			response, err := responses.CreateSQLResponseJSON(page.columns, page.rows, page.cursor)
+			if err != nil {
+				writeResponseError(w, err, http.StatusInternalServerError, context.RuntimeLog)
+				return
+			}
+			writeBytesResponseSuccess(w, response)
+		}
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// SQLCloseHandler returns handler closing SQL cursors before their page timeout.
+func SQLCloseHandler(context HandlerContext) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(w, r, context.RuntimeLog)
+		if err != nil {
+			return
+		}
+		req, err := requests.ParseSQLRequest(body)
+		if err != nil || req.Cursor == "" {
+			writeResponseError(w, errors.New("cursor should be specified"), http.StatusBadRequest, context.RuntimeLog)
+			return
+		}
+		response := responses.CreateSQLCloseResponse(requests.CloseSQLCursor(req.Cursor))
+		writeResponseSuccess(w, &response)
+	}
+	if context.HttpLog != nil {
+		return logHttpTransactions(context.HttpLog, handler)
+	}
+	return handler
+}
+
+// executeSQL returns the first page of SQL statement results, metadata statements are answered
+// from models of logs tables.
+func executeSQL(statement *requests.SQLStatement, provider db.DataProvider, fetchSize int, timeout time.Duration) (sqlPage, error) {
+	switch statement.Kind {
+	case requests.SQLShowTables:
+		tables, _ := indexTables(statement.Index)
+		columns, rows := responses.SQLTablesRows(tables)
+		return sqlPage{columns: columns, rows: rows}, nil
+	case requests.SQLDescribe:
+		columns, rows := responses.SQLDescribeRows(provider.DataScheme())
+		return sqlPage{columns: columns, rows: rows}, nil
+	}
+
+	var scheme *models.ModelInfo
+	source := ""
+	if provider != nil {
+		scheme, source = provider.DataScheme(), requests.SQLSource(provider)
+	}
+	cursor := requests.SQLCursor{
+		Query:     statement.Query(scheme, source),
+		Limit:     statement.Limit,
+		FetchSize: fetchSize,
+	}
+	return fetchSQLPage("", cursor, scheme, timeout)
+}
+
+// fetchSQLPage selects page of query results at the cursor position, new cursor is opened for the first page
+// if there are more rows, cursor of the last page is closed. Columns are returned for the first page only,
+// they are named by kibana names of model fields.
+func fetchSQLPage(id string, cursor requests.SQLCursor, scheme *models.ModelInfo, timeout time.Duration) (sqlPage, error) {
+	// one more row is selected for checking that the page is not the last one
+	names, types, rows, err := db.SelectRows(requests.SQLPageQuery(cursor.Query, cursor.Limit, cursor.Offset, cursor.FetchSize+1))
+	if err != nil {
+		return sqlPage{}, err
+	}
+	more := len(rows) > cursor.FetchSize
+	if more {
+		rows = rows[:cursor.FetchSize]
+	}
+
+	page := sqlPage{rows: rows}
+	switch {
+	case id == "" && more:
+		cursor.Offset += len(rows)
+		if page.cursor, err = requests.OpenSQLCursor(cursor, timeout); err != nil {
+			return sqlPage{}, err
+		}
+	case id != "" && more:
+		requests.MoveSQLCursor(id, len(rows))
+		page.cursor = id
+	case id != "":
+		requests.CloseSQLCursor(id)
+	}
+
+	if id == "" {
+		page.columns = make([]responses.SQLColumn, len(names))
+		for i := range names {
+			page.columns[i] = responses.SQLColumn{Name: names[i], Type: responses.SQLColumnType(types[i])}
+			if scheme == nil {
+				continue
+			}
+			if field, ok := scheme.DataFields[names[i]]; ok {
+				page.columns[i].Name = field.KibanaName
+			}
+		}
+	}
+	return page, nil
+}
+
+// writeSQLText writes SQL response in text format, cursor of the next page is set by header.
+func writeSQLText(w http.ResponseWriter, body []byte, contentType string, cursor string) {
+	if cursor != "" {
+		w.Header().Set("Cursor", cursor)
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(body)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+)
+
+// checkModel compares compiled-in model with the actual clickhouse table structure,
+// list of found differences is returned.
+func checkModel(table string, structure *tableStructure) ([]string, error) {
+	model, ok := models.GetLogsTablesSchemas()[table]
+	if !ok {
+		return nil, errors.New("model is not found for table " + table)
+	}
+
+	columns := make(map[string]tableColumn, len(structure.columns))
+	for _, column := range structure.columns {
+		columns[column.name] = column
+	}
+	partitioning := structure.partitioningColumn()
+	positions := structure.sortingKeyPositions()
+
+	diff := make([]string, 0)
+	modelColumns := make(map[string]struct{}, model.NumField())
+	for i := 0; i < model.NumField(); i++ {
+		field := model.Field(i)
+		name := field.Tag.Get("db")
+		if name == "" || field.Tag.Get("skip") == "db" {
+			continue
+		}
+		modelColumns[name] = struct{}{}
+
+		column, ok := columns[name]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("column %s (%s) is missing in table", name, field.Name))
+			continue
+		}
+		if chType := field.Tag.Get("type"); chType != column.chType {
+			diff = append(diff, fmt.Sprintf("column %s type mismatch: model %s, table %s", name, chType, column.chType))
+		}
+		if isPartitioning := field.Tag.Get("partitioning") == "true"; isPartitioning != (name == partitioning) {
+			diff = append(diff, fmt.Sprintf("column %s partitioning mismatch: model %t, table %t", name, isPartitioning, !isPartitioning))
+		}
+		pos := 0
+		if tag := field.Tag.Get("ch_index_pos"); tag != "" {
+			if pos, _ = strconv.Atoi(tag); pos == 0 {
+				diff = append(diff, fmt.Sprintf("column %s has invalid ch_index_pos %q", name, tag))
+				continue
+			}
+		}
+		if pos != positions[name] {
+			diff = append(diff, fmt.Sprintf("column %s sorting key position mismatch: model %d, table %d", name, pos, positions[name]))
+		}
+	}
+
+	for _, column := range structure.columns {
+		if _, ok := modelColumns[column.name]; !ok {
+			diff = append(diff, fmt.Sprintf("column %s (%s) is missing in model", column.name, column.chType))
+		}
+	}
+
+	return diff, nil
+}
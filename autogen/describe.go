@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+// columns used as logs timestamp if they have UInt64 (nanoseconds), DateTime or DateTime64 type.
+var timestampColumns = []string{"ts", "timestamp"}
+
+var identifier = regexp.MustCompile(`^\w+$`)
+
+// field names of well-known columns, the same as in hand-written models.
+var wellKnownFieldNames = map[string]string{
+	"uuid": "UUID",
+	"ts":   "TS",
+}
+
+// tableColumn contains clickhouse table column description.
+type tableColumn struct {
+	name        string
+	chType      string
+	defaultKind string
+	defaultExpr string
+}
+
+// tableStructure contains clickhouse table columns and keys.
+type tableStructure struct {
+	columns      []tableColumn
+	partitionKey string
+	sortingKey   []string
+}
+
+// modelField contains source code of single model field.
+type modelField struct {
+	Name   string
+	GoType string
+	Tags   string
+	// packages required by the field type
+	imports []string
+}
+
+// modelsPkgPath is import path of the package generated models belong to.
+var modelsPkgPath = reflect.TypeOf(models.NullInt64{}).PkgPath()
+
+// goTypeSource returns source code of the type inside models package, e.g. NullInt64 instead of models.NullInt64,
+// packages of other named types are returned as imports.
+func goTypeSource(t reflect.Type) (string, []string) {
+	if t.Name() != "" {
+		switch t.PkgPath() {
+		case "":
+			return t.String(), nil
+		case modelsPkgPath:
+			return t.Name(), nil
+		default:
+			return t.String(), []string{t.PkgPath()}
+		}
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		elem, imports := goTypeSource(t.Elem())
+		return "[]" + elem, imports
+	case reflect.Map:
+		key, keyImports := goTypeSource(t.Key())
+		elem, elemImports := goTypeSource(t.Elem())
+		return "map[" + key + "]" + elem, mergeImports(keyImports, elemImports)
+	}
+	return t.String(), nil
+}
+
+// mergeImports returns sorted unique import paths.
+func mergeImports(lists ...[]string) []string {
+	set := make(map[string]struct{})
+	for _, list := range lists {
+		for _, path := range list {
+			set[path] = struct{}{}
+		}
+	}
+	result := make([]string, 0, len(set))
+	for path := range set {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// describeTable reads table structure by DESCRIBE TABLE, partition and sorting keys are read from system.tables.
+func describeTable(conn *sqlx.DB, table string) (*tableStructure, error) {
+	database, name := db.SplitTableName(table)
+	rows, err := conn.Query("DESCRIBE TABLE " + database + "." + name)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot describe table "+table)
+	}
+	defer rows.Close()
+
+	// set of DESCRIBE columns depends on clickhouse version, first four of them are always the same
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	structure := &tableStructure{}
+	for rows.Next() {
+		values := make([]string, len(names))
+		pointers := make([]interface{}, len(names))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, errors.Wrap(err, "cannot read description of table "+table)
+		}
+		column := tableColumn{name: values[0], chType: values[1]}
+		if len(values) >= 4 {
+			column.defaultKind, column.defaultExpr = values[2], values[3]
+		}
+		structure.columns = append(structure.columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(structure.columns) == 0 {
+		return nil, errors.New("table not exists: " + table)
+	}
+
+	var sortingKey string
+	err = conn.QueryRow(
+		"SELECT partition_key, sorting_key FROM system.tables WHERE database = ? AND name = ?",
+		database,
+		name,
+	).Scan(&structure.partitionKey, &sortingKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read keys of table "+table)
+	}
+	structure.sortingKey = splitKey(sortingKey)
+
+	return structure, nil
+}
+
+// splitKey splits key expression to the list of expressions, e.g. "(ts, type)" -> ["ts", "type"].
+func splitKey(key string) []string {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "(") && strings.HasSuffix(key, ")") {
+		key = key[1 : len(key)-1]
+	}
+	if key == "" {
+		return nil
+	}
+
+	parts := make([]string, 0)
+	depth, start := 0, 0
+	for i, r := range key {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(key[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(key[start:]))
+}
+
+// timestampColumn returns name of column with logs timestamp, first sorting key column is used
+// if there is no column with one of the well-known names.
+func (ts *tableStructure) timestampColumn() string {
+	types := make(map[string]string, len(ts.columns))
+	for _, column := range ts.columns {
+		types[column.name] = column.chType
+	}
+	for _, name := range timestampColumns {
+		if isTimestampType(types[name]) {
+			return name
+		}
+	}
+	if len(ts.sortingKey) > 0 && isTimestampType(types[ts.sortingKey[0]]) {
+		return ts.sortingKey[0]
+	}
+	return ""
+}
+
+// isTimestampType checks that column of the type could be used as logs timestamp,
+// UInt64 values are considered as nanoseconds.
+func isTimestampType(chType string) bool {
+	return chType == "UInt64" || chType == "DateTime" || strings.HasPrefix(chType, "DateTime64(")
+}
+
+// partitioningColumn returns partition key column, complex partition expressions are not supported by models.
+func (ts *tableStructure) partitioningColumn() string {
+	key := strings.Trim(strings.TrimSpace(ts.partitionKey), "()")
+	if identifier.MatchString(key) {
+		return key
+	}
+	return ""
+}
+
+// sortingKeyPositions returns positions of columns in sorting key starting from 1.
+func (ts *tableStructure) sortingKeyPositions() map[string]int {
+	positions := make(map[string]int, len(ts.sortingKey))
+	for i, key := range ts.sortingKey {
+		if identifier.MatchString(key) {
+			positions[key] = i + 1
+		}
+	}
+	return positions
+}
+
+// modelFields generates model fields for all table columns, materialized and alias columns are omitted.
+// Name of the field used as document id is returned as well.
+func (ts *tableStructure) modelFields() ([]modelField, string, []string, error) {
+	timestamp := ts.timestampColumn()
+	partitioning := ts.partitioningColumn()
+	positions := ts.sortingKeyPositions()
+
+	fields := make([]modelField, 0, len(ts.columns)+1)
+	warnings := make([]string, 0)
+	names := make(map[string]struct{}, len(ts.columns))
+	idField, timestampField := "", ""
+
+	for _, column := range ts.columns {
+		if column.defaultKind == "MATERIALIZED" || column.defaultKind == "ALIAS" {
+			warnings = append(warnings, fmt.Sprintf("%s column %s is omitted", strings.ToLower(column.defaultKind), column.name))
+			continue
+		}
+
+		tags := []string{
+			"db:" + strconv.Quote(column.name),
+			"json:" + strconv.Quote(column.name),
+			"type:" + strconv.Quote(column.chType),
+		}
+		isUUID := column.name == "uuid" && column.chType == "UInt64"
+		if isUUID {
+			tags = append(tags, `uuid:"true"`)
+		}
+		if column.name == timestamp {
+			tags = append(tags, `timestamp:"true"`)
+		}
+		if column.name == partitioning {
+			tags = append(tags, `partitioning:"true"`)
+		}
+		if pos, ok := positions[column.name]; ok {
+			tags = append(tags, "ch_index_pos:"+strconv.Quote(strconv.Itoa(pos)))
+		}
+		if column.defaultKind == "DEFAULT" {
+			// empty string default is set by empty tag value
+			expr := column.defaultExpr
+			if expr == "''" {
+				expr = ""
+			}
+			tags = append(tags, "default:"+strconv.Quote(expr))
+		}
+
+		name, ok := wellKnownFieldNames[column.name]
+		if !ok {
+			name = models.GoFieldName(column.name)
+		}
+		if _, duplicated := names[name]; duplicated {
+			name += strconv.Itoa(len(fields))
+		}
+		names[name] = struct{}{}
+		if isUUID {
+			idField = name
+		}
+		if column.name == timestamp && column.chType == "UInt64" {
+			timestampField = name
+		}
+
+		goType, imports := goTypeSource(models.GoType(column.chType))
+		fields = append(fields, modelField{
+			Name:    name,
+			GoType:  goType,
+			Tags:    strings.Join(tags, " "),
+			imports: imports,
+		})
+	}
+
+	// table name is required by data wrappers
+	fields = append(fields, modelField{
+		Name:   "Table",
+		GoType: "string",
+		Tags:   `db:"_table" type:"String" json:"table" skip:"db"`,
+	})
+
+	if timestamp == "" {
+		warnings = append(warnings, "timestamp column is not detected")
+	}
+	if idField == "" {
+		if timestampField == "" {
+			return nil, "", nil, errors.New("neither uuid nor timestamp UInt64 column is found")
+		}
+		idField = timestampField
+		warnings = append(warnings, "uuid column is not detected, timestamp is used as document id")
+	}
+	return fields, idField, warnings, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestSplitKey(t *testing.T) {
+	testCases := []struct {
+		caseName string
+		key      string
+		expected []string
+	}{
+		{
+			caseName: "single column",
+			key:      "ts",
+			expected: []string{"ts"},
+		},
+		{
+			caseName: "tuple with functions",
+			key:      "(ts, cityHash64(uuid, type), day)",
+			expected: []string{"ts", "cityHash64(uuid, type)", "day"},
+		},
+		{
+			caseName: "empty key",
+			key:      "",
+			expected: nil,
+		},
+	}
+
+	for _, c := range testCases {
+		if got := splitKey(c.key); !reflect.DeepEqual(got, c.expected) {
+			t.Error("For", c.caseName, "\n expected: ", c.expected, "\n got: ", got)
+		}
+	}
+}
+
+func TestModelFields(t *testing.T) {
+	structure := &tableStructure{
+		columns: []tableColumn{
+			{name: "uuid", chType: "UInt64"},
+			{name: "day", chType: "Date", defaultKind: "DEFAULT", defaultExpr: "today()"},
+			{name: "ts", chType: "UInt64"},
+			{name: "remote_ip", chType: "String", defaultKind: "DEFAULT", defaultExpr: "''"},
+			{name: "tags", chType: "Array(String)"},
+			{name: "host", chType: "String", defaultKind: "MATERIALIZED", defaultExpr: "domain(url)"},
+		},
+		partitionKey: "day",
+		sortingKey:   []string{"ts", "uuid"},
+	}
+
+	expected := []modelField{
+		{Name: "UUID", GoType: "uint64", Tags: `db:"uuid" json:"uuid" type:"UInt64" uuid:"true" ch_index_pos:"2"`},
+		{Name: "Day", GoType: "time.Time", Tags: `db:"day" json:"day" type:"Date" partitioning:"true" default:"today()"`, imports: []string{"time"}},
+		{Name: "TS", GoType: "uint64", Tags: `db:"ts" json:"ts" type:"UInt64" timestamp:"true" ch_index_pos:"1"`},
+		{Name: "RemoteIp", GoType: "string", Tags: `db:"remote_ip" json:"remote_ip" type:"String" default:""`},
+		{Name: "Tags", GoType: "[]string", Tags: `db:"tags" json:"tags" type:"Array(String)"`},
+		{Name: "Table", GoType: "string", Tags: `db:"_table" type:"String" json:"table" skip:"db"`},
+	}
+
+	fields, idField, warnings, err := structure.modelFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Error("For model fields", "\n expected: ", expected, "\n got: ", fields)
+	}
+	if idField != "UUID" {
+		t.Error("For id field", "\n expected: ", "UUID", "\n got: ", idField)
+	}
+	if len(warnings) != 1 {
+		t.Error("For warnings", "\n expected: ", "materialized column warning", "\n got: ", warnings)
+	}
+}
+
+func TestGoTypeSource(t *testing.T) {
+	testCases := []struct {
+		chType  string
+		source  string
+		imports []string
+	}{
+		{chType: "Nullable(Int32)", source: "NullInt64"},
+		{chType: "Nullable(UInt64)", source: "NullUint64"},
+		{chType: "Nullable(DateTime)", source: "NullTime"},
+		{chType: "Array(Nullable(String))", source: "[]NullString"},
+		{chType: "IPv4", source: "net.IP", imports: []string{"net"}},
+		{chType: "Map(String, DateTime64(9))", source: "map[string]time.Time", imports: []string{"time"}},
+	}
+
+	for _, c := range testCases {
+		source, imports := goTypeSource(models.GoType(c.chType))
+		if source != c.source || !reflect.DeepEqual(imports, c.imports) {
+			t.Error("For", c.chType, "\n expected: ", c.source, c.imports, "\n got: ", source, imports)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"io"
+	"go/format"
+	"io/ioutil"
 	"os"
 	"strings"
 	"text/template"
@@ -10,12 +12,18 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"kibouse/clickhouse"
 	"kibouse/db"
 )
 
 type modelNames struct {
 	SourceName string
 	ChName     string
+	// model fields generated from the existing table, blank model is generated if empty
+	Fields  []modelField
+	IDField string
+	// packages imported by the model source
+	Imports []string
 }
 
 func check(err error) {
@@ -24,21 +32,108 @@ func check(err error) {
 	}
 }
 
-func executeTemplateFromFile(model modelNames, file string, writer io.Writer) error {
+func executeTemplateFromFile(model modelNames, file string, output string) error {
+	source, err := generateSource(model, file)
+	if err != nil {
+		return errors.Wrap(err, "cannot generate "+output)
+	}
+	return ioutil.WriteFile(output, source, 0644)
+}
+
+// generateSource executes template and returns formatted source code.
+func generateSource(model modelNames, file string) ([]byte, error) {
 	tmpl, err := template.ParseFiles(file)
 	if err != nil {
-		return errors.New("cannot parse " + file)
+		return nil, errors.New("cannot parse " + file)
+	}
+
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, model); err != nil {
+		return nil, err
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot format generated source")
+	}
+	return source, nil
+}
+
+// createModelNames prepares templates data, model fields are read from the existing clickhouse table in fromTable mode.
+func createModelNames(table string) modelNames {
+	names := modelNames{
+		SourceName: sourceCodeName,
+		ChName:     table,
+		IDField:    "UUID",
+		Imports:    []string{"reflect", "time"},
+	}
+	if !fromTable {
+		return names
+	}
+
+	structure, err := readTableStructure(table)
+	check(err)
+	fields, idField, warnings, err := structure.modelFields()
+	check(err)
+	for _, warning := range warnings {
+		fmt.Println("warning:", warning)
+	}
+
+	names.Fields, names.IDField = fields, idField
+	names.Imports = modelImports(fields)
+	return names
+}
+
+// modelImports returns packages imported by the model with the fields.
+func modelImports(fields []modelField) []string {
+	imports := [][]string{{"reflect"}}
+	for _, field := range fields {
+		imports = append(imports, field.imports)
+	}
+	return mergeImports(imports...)
+}
+
+func readTableStructure(table string) (*tableStructure, error) {
+	conn, err := clickhouse.CreateConnection(clickhouseAddress)
+	if err != nil {
+		return nil, err
 	}
+	defer conn.Close()
+	return describeTable(conn, table)
+}
 
-	return tmpl.Execute(writer, model)
+// checkTable prints differences between compiled-in model and clickhouse table, exits with error if any.
+func checkTable(table string) {
+	structure, err := readTableStructure(table)
+	check(err)
+	diff, err := checkModel(table, structure)
+	check(err)
+	if len(diff) == 0 {
+		fmt.Println("model of " + table + " is up to date")
+		return
+	}
+	for _, d := range diff {
+		fmt.Println(d)
+	}
+	os.Exit(1)
 }
 
 // RootCmd represents the base command when called without any subcommands.
 var rootCmd = &cobra.Command{
 	Use:   "autogen",
 	Short: "generate source code for kibouse models",
-	Long:  "autogen tool uses for generating new blank kibouse models and wrappers for it",
+	Long: "autogen tool uses for generating new blank kibouse models and wrappers for it, " +
+		"models could be also generated from the existing clickhouse tables or checked against them",
 	Run: func(cmd *cobra.Command, args []string) {
+		table := db.DataStorageTablesPrefix + chTableName
+		if checkOnly {
+			checkTable(table)
+			return
+		}
+		if sourceCodeName == "" {
+			check(errors.New("source_name is required"))
+		}
+		names := createModelNames(table)
+
 		params := []struct {
 			template string
 			output   string
@@ -54,20 +149,7 @@ var rootCmd = &cobra.Command{
 		}
 
 		for _, param := range params {
-			outputFile, err := os.Create(param.output)
-
-			check(err)
-			check(
-				executeTemplateFromFile(
-					modelNames{
-						SourceName: sourceCodeName,
-						ChName:     db.DataStorageTablesPrefix + chTableName,
-					},
-					param.template,
-					outputFile,
-				),
-			)
-			outputFile.Close()
+			check(executeTemplateFromFile(names, param.template, param.output))
 		}
 	},
 }
@@ -75,6 +157,9 @@ var rootCmd = &cobra.Command{
 var dataFolder string
 var chTableName string
 var sourceCodeName string
+var clickhouseAddress string
+var fromTable bool
+var checkOnly bool
 
 func init() {
 	rootCmd.Flags().StringVarP(
@@ -99,8 +184,27 @@ func init() {
 		"source code name for model",
 	)
 
+	rootCmd.Flags().StringVar(
+		&clickhouseAddress,
+		"clickhouse",
+		"tcp://127.0.0.1:9000",
+		"clickhouse address for reading tables structure",
+	)
+	rootCmd.Flags().BoolVarP(
+		&fromTable,
+		"from_table",
+		"t",
+		false,
+		"generate model fields from the existing clickhouse table",
+	)
+	rootCmd.Flags().BoolVar(
+		&checkOnly,
+		"check",
+		false,
+		"compare compiled-in model with the existing clickhouse table",
+	)
+
 	rootCmd.MarkFlagRequired("clickhouse_table")
-	rootCmd.MarkFlagRequired("source_name")
 }
 
 func main() {
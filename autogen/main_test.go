@@ -0,0 +1,86 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// typeCheckModel checks generated model source together with sources of the models package.
+func typeCheckModel(source []byte) error {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob("../data/models/*.go")
+	if err != nil {
+		return err
+	}
+	files := make([]*ast.File, 0, len(paths)+1)
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	generated, err := parser.ParseFile(fset, "generated.go", source, 0)
+	if err != nil {
+		return err
+	}
+	files = append(files, generated)
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check(modelsPkgPath, fset, files, nil)
+	return err
+}
+
+func TestGenerateModel(t *testing.T) {
+	structure := &tableStructure{
+		columns: []tableColumn{
+			{name: "ts", chType: "UInt64"},
+			{name: "day", chType: "Date"},
+			{name: "remote_ip", chType: "IPv4"},
+			{name: "hits", chType: "Nullable(UInt64)"},
+			{name: "status", chType: "Nullable(Int32)"},
+			{name: "finished", chType: "Nullable(DateTime)"},
+			{name: "tags", chType: "Array(Nullable(String))"},
+		},
+		partitionKey: "day",
+		sortingKey:   []string{"ts"},
+	}
+	fields, idField, _, err := structure.modelFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		caseName string
+		model    modelNames
+	}{
+		{
+			caseName: "blank model",
+			model:    modelNames{SourceName: "Blank", ChName: "logs_blank", IDField: "UUID", Imports: []string{"reflect", "time"}},
+		},
+		{
+			caseName: "model from table",
+			model:    modelNames{SourceName: "Generated", ChName: "logs_generated", Fields: fields, IDField: idField, Imports: modelImports(fields)},
+		},
+	}
+
+	for _, c := range testCases {
+		source, err := generateSource(c.model, "tpl/model.tmpl")
+		if err != nil {
+			t.Error("For", c.caseName, "\n expected: ", "formatted source", "\n got: ", err)
+			continue
+		}
+		if err := typeCheckModel(source); err != nil {
+			t.Error("For", c.caseName, "\n expected: ", "compilable source", "\n got: ", err, "\n", string(source))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package clickhouse
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+	"kibouse/data/wrappers"
+	"kibouse/db"
+)
+
+func init() {
+	wrappers.SetColumnsLoader(LoadTableColumns)
+	wrappers.SetLogsTablesFilter(db.IsLogsTable)
+}
+
+type columnInfo struct {
+	Name string `db:"name"`
+	Type string `db:"type"`
+}
+
+// LoadTableColumns reads names and types of logs table columns from system.columns.
+func LoadTableColumns(table string) ([]models.CHField, error) {
+	database, name := db.SplitTableName(table)
+	request := db.NewRequest("system.columns", "name, type")
+	request.Where(fmt.Sprintf(
+		"database = %s AND table = %s",
+		models.QuoteString(database),
+		models.QuoteString(name),
+	))
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+
+	columns := make([]columnInfo, 0)
+	if err := selector(&columns); err != nil {
+		return nil, errors.Wrap(err, "cannot read columns of table "+table)
+	}
+
+	fields := make([]models.CHField, 0, len(columns))
+	for _, column := range columns {
+		fields = append(fields, models.CHField{CHName: column.Name, CHType: column.Type})
+	}
+	return fields, nil
+}
+
+type mapKeyInfo struct {
+	Key string `db:"key"`
+}
+
+// mapKeysSampleSize is the number of the latest table rows used for collecting Map column keys.
+const mapKeysSampleSize = 10000
+
+// LoadMapKeys reads distinct keys of Map column from the sample of table rows.
+func LoadMapKeys(table string, column string) ([]string, error) {
+	request := db.NewRequest(
+		fmt.Sprintf("(SELECT %s FROM %s LIMIT %d)", column, db.TableFullName(table), mapKeysSampleSize),
+		fmt.Sprintf("DISTINCT arrayJoin(mapKeys(%s)) AS key", column),
+	)
+	request.OrderBy("key", db.ASC)
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+
+	keys := make([]mapKeyInfo, 0)
+	if err := selector(&keys); err != nil {
+		return nil, errors.Wrap(err, "cannot read keys of "+table+"."+column)
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.Key)
+	}
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package clickhouse
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"kibouse/db"
+)
+
+// TableDiskUsage contains summary of clickhouse table active data parts.
+type TableDiskUsage struct {
+	Table      string `db:"table"`
+	Partitions uint64 `db:"partitions"`
+	Parts      uint64 `db:"parts"`
+	Rows       uint64 `db:"rows"`
+	Bytes      uint64 `db:"bytes"`
+}
+
+type partitionInfo struct {
+	ID string `db:"partition_id"`
+}
+
+// GetTablesDiskUsage returns disk usage of all tables from the logs database ordered by size.
+func GetTablesDiskUsage() ([]TableDiskUsage, error) {
+	request := db.NewRequest(
+		"system.parts",
+		"table, uniqExact(partition_id) AS partitions, count() AS parts, sum(rows) AS rows, sum(bytes_on_disk) AS bytes",
+	)
+	request.Where(fmt.Sprintf("database = '%s' AND active", db.DataBaseName))
+	request.GroupBy("table")
+	request.OrderBy("bytes", db.DESC)
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+
+	usage := make([]TableDiskUsage, 0)
+	if err := selector(&usage); err != nil {
+		return nil, errors.Wrap(err, "cannot read tables disk usage: "+request.Build())
+	}
+	return usage, nil
+}
+
+// DropExpiredPartitions removes table partitions which contain only data older than retention period,
+// returns identifiers of expired partitions. Partitions are only listed in dry run mode.
+func DropExpiredPartitions(table string, retentionDays uint, dryRun bool) ([]string, error) {
+	if retentionDays == 0 {
+		return nil, nil
+	}
+
+	// tables not partitioned by date have zero max_date
+	database, name := db.SplitTableName(table)
+	request := db.NewRequest("system.parts", "partition_id")
+	request.Where(fmt.Sprintf("database = '%s' AND table = '%s' AND active", database, name))
+	request.GroupBy("partition_id")
+	request.Having(fmt.Sprintf("max(max_date) > toDate(0) AND max(max_date) < today() - %d", retentionDays))
+	request.OrderBy("partition_id", db.ASC)
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+
+	partitions := make([]partitionInfo, 0)
+	if err := selector(&partitions); err != nil {
+		return nil, errors.Wrap(err, "cannot read expired partitions of "+table)
+	}
+
+	expired := make([]string, 0, len(partitions))
+	for _, partition := range partitions {
+		if !dryRun {
+			query := fmt.Sprintf("ALTER TABLE %s.%s DROP PARTITION ID '%s'", database, name, partition.ID)
+			if _, err := db.Execute(query); err != nil {
+				return expired, err
+			}
+		}
+		expired = append(expired, partition.ID)
+	}
+	return expired, nil
+}
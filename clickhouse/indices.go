@@ -0,0 +1,67 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+// TableStats contains number of rows and size of the logs table, parts of table which
+// are not active (e.g. already merged) are not counted.
+type TableStats struct {
+	Rows  uint64
+	Bytes uint64
+}
+
+type partsInfo struct {
+	Database string `db:"database"`
+	Table    string `db:"table"`
+	Rows     uint64 `db:"rows"`
+	Bytes    uint64 `db:"bytes"`
+}
+
+// LoadTablesStats reads numbers of rows and sizes of logs tables from system.parts,
+// tables without data parts (e.g. views) have empty stats.
+func LoadTablesStats(tables []string) (map[string]TableStats, error) {
+	stats := make(map[string]TableStats, len(tables))
+	if len(tables) == 0 {
+		return stats, nil
+	}
+
+	names := make(map[string]string, len(tables))
+	conditions := make([]string, 0, len(tables))
+	for _, table := range tables {
+		database, name := db.SplitTableName(table)
+		names[database+"."+name] = table
+		stats[table] = TableStats{}
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s, %s)",
+			models.QuoteString(database),
+			models.QuoteString(name),
+		))
+	}
+
+	request := db.NewRequest("system.parts", "database, table, sum(rows) AS rows, sum(bytes_on_disk) AS bytes")
+	request.Where("active AND (database, table) IN (" + strings.Join(conditions, ", ") + ")")
+	request.GroupBy("database, table")
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+
+	parts := make([]partsInfo, 0, len(tables))
+	if err := selector(&parts); err != nil {
+		return nil, errors.Wrap(err, "cannot read data parts of logs tables")
+	}
+	for _, part := range parts {
+		if table, ok := names[part.Database+"."+part.Table]; ok {
+			stats[table] = TableStats{Rows: part.Rows, Bytes: part.Bytes}
+		}
+	}
+	return stats, nil
+}
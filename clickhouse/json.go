@@ -0,0 +1,191 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+const (
+	// jsonPathsSampleSize is the number of the latest table rows used for collecting paths of JSON payloads.
+	jsonPathsSampleSize = 1000
+	// jsonPathsTTL specifies how long sampled paths of JSON payloads are cached.
+	jsonPathsTTL = time.Minute
+)
+
+type sampledJSONPaths struct {
+	paths    map[string]string
+	loadedAt time.Time
+}
+
+var jsonPathsCache = map[string]sampledJSONPaths{}
+var jsonPathsMutex = &sync.Mutex{}
+
+// jsonPathsLoad is sampling of JSON payloads of the column in progress, concurrent requests of
+// the same column wait for its result instead of running the query again.
+type jsonPathsLoad struct {
+	done  chan struct{}
+	paths map[string]string
+	err   error
+}
+
+var jsonPathsLoads = map[string]*jsonPathsLoad{}
+
+type jsonPayload struct {
+	Payload string `db:"payload"`
+}
+
+// LoadJSONPaths returns paths of JSON payloads stored in the column with kinds of their values,
+// paths are collected from the sample of the latest table rows ordered by the timestamp column.
+// Rows are sampled in the order of reading if the table has no timestamp.
+func LoadJSONPaths(table string, column models.CHField, timestamp string) (map[string]string, error) {
+	key := table + "." + column.CHName
+	jsonPathsMutex.Lock()
+	if sample, ok := jsonPathsCache[key]; ok && time.Since(sample.loadedAt) < jsonPathsTTL {
+		jsonPathsMutex.Unlock()
+		return sample.paths, nil
+	}
+	if load, ok := jsonPathsLoads[key]; ok {
+		jsonPathsMutex.Unlock()
+		<-load.done
+		return load.paths, load.err
+	}
+	load := &jsonPathsLoad{done: make(chan struct{})}
+	jsonPathsLoads[key] = load
+	jsonPathsMutex.Unlock()
+
+	load.paths, load.err = sampleJSONPaths(table, column, timestamp)
+
+	jsonPathsMutex.Lock()
+	if load.err == nil {
+		jsonPathsCache[key] = sampledJSONPaths{paths: load.paths, loadedAt: time.Now()}
+	}
+	delete(jsonPathsLoads, key)
+	jsonPathsMutex.Unlock()
+	close(load.done)
+	return load.paths, load.err
+}
+
+// sampleJSONPaths selects sample of JSON payloads of the column and collects their paths.
+func sampleJSONPaths(table string, column models.CHField, timestamp string) (map[string]string, error) {
+	order := ""
+	if timestamp != "" {
+		order = fmt.Sprintf(" ORDER BY %s DESC", timestamp)
+	}
+	request := db.NewRequest(
+		fmt.Sprintf(
+			"(SELECT %s AS payload FROM %s%s LIMIT %d)",
+			column.JSONSource(), db.TableFullName(table), order, jsonPathsSampleSize,
+		),
+		"payload",
+	)
+	request.Where("payload != ''")
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+
+	payloads := make([]jsonPayload, 0)
+	if err := selector(&payloads); err != nil {
+		return nil, errors.Wrap(err, "cannot read JSON payloads of "+table+"."+column.CHName)
+	}
+
+	paths := make(map[string]string)
+	for _, payload := range payloads {
+		// payloads which are not JSON objects are ignored
+		var doc map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(payload.Payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err == nil {
+			collectJSONPaths("", doc, paths)
+		}
+	}
+	return paths, nil
+}
+
+// collectJSONPaths adds dotted paths of all object values to the list, kind of null values
+// is defined by other payloads.
+func collectJSONPaths(prefix string, doc map[string]interface{}, paths map[string]string) {
+	for key, value := range doc {
+		path := prefix + key
+		switch v := value.(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			collectJSONPaths(path+".", v, paths)
+			continue
+		}
+		paths[path] = mergeJSONKinds(paths[path], jsonValueKind(value))
+	}
+}
+
+// mergeJSONKinds returns kind of path values found in several payloads, values of different kinds are read as raw JSON.
+func mergeJSONKinds(known string, kind string) string {
+	switch {
+	case known == "" || known == kind:
+		return kind
+	case known == models.JSONInt && kind == models.JSONFloat, known == models.JSONFloat && kind == models.JSONInt:
+		return models.JSONFloat
+	}
+	return models.JSONRaw
+}
+
+func jsonValueKind(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return models.JSONString
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return models.JSONInt
+		}
+		return models.JSONFloat
+	}
+	return models.JSONRaw
+}
+
+// loadModelJSONPaths sets paths of JSON payloads of all model JSON columns.
+func loadModelJSONPaths(table string, model *models.ModelInfo) {
+	timestamp := ""
+	if field, ok := model.GetTimestampField(); ok && !field.IsComputed() {
+		timestamp = field.CHName
+	}
+	for _, field := range model.DataFields {
+		if !field.IsJSONBlob() {
+			continue
+		}
+		paths, err := LoadJSONPaths(table, field.CHField, timestamp)
+		if err != nil {
+			log.Warnf("%+v", err)
+			continue
+		}
+		if model.JSONPaths == nil {
+			model.JSONPaths = make(map[string]map[string]string)
+		}
+		model.JSONPaths[field.CHName] = paths
+	}
+}
+
+// mergeJSONPaths combines paths of JSON payloads of several tables.
+func mergeJSONPaths(tables []string, schemes map[string]*models.ModelInfo) map[string]map[string]string {
+	merged := make(map[string]map[string]string)
+	for _, table := range tables {
+		for column, paths := range schemes[table].JSONPaths {
+			if _, ok := merged[column]; !ok {
+				merged[column] = make(map[string]string, len(paths))
+			}
+			for path, kind := range paths {
+				merged[column][path] = mergeJSONKinds(merged[column][path], kind)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,59 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestCollectJSONPaths(t *testing.T) {
+	testData := []struct {
+		caseName string
+		payloads []string
+		paths    map[string]string
+	}{
+		{
+			caseName: "nested objects",
+			payloads: []string{`{"user": {"id": 1, "name": "bob"}, "duration": 0.5, "tags": ["a"], "ok": true}`},
+			paths: map[string]string{
+				"user.id":   models.JSONInt,
+				"user.name": models.JSONString,
+				"duration":  models.JSONFloat,
+				"tags":      models.JSONRaw,
+				"ok":        models.JSONRaw,
+			},
+		},
+		{
+			caseName: "different kinds of values",
+			payloads: []string{`{"code": 1, "size": 10, "error": null}`, `{"code": "E1", "size": 10.5, "error": "timeout"}`},
+			paths: map[string]string{
+				"code":  models.JSONRaw,
+				"size":  models.JSONFloat,
+				"error": models.JSONString,
+			},
+		},
+	}
+
+	for _, test := range testData {
+		paths := make(map[string]string)
+		for _, payload := range test.payloads {
+			var doc map[string]interface{}
+			decoder := json.NewDecoder(strings.NewReader(payload))
+			decoder.UseNumber()
+			if err := decoder.Decode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			collectJSONPaths("", doc, paths)
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.paths,
+				"\n got: ", paths,
+			)
+		}
+	}
+}
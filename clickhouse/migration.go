@@ -0,0 +1,219 @@
+package clickhouse
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+// MigrationPlan contains queries bringing existing logs table and its kafka delivery queues in line with the model.
+type MigrationPlan struct {
+	Table   string
+	Queries []string
+	// columns absent in the model, they are never dropped automatically
+	UnknownColumns []string
+
+	// kafka tables and materialized views recreated with the model, in order of dropping
+	pipeline []string
+	alters   []string
+	creates  []string
+}
+
+// executeQuery and loadTablesDefinitions are replaced in tests.
+var executeQuery = func(query string) error {
+	_, err := db.Execute(query)
+	return err
+}
+var loadTablesDefinitions = loadCreateTableQueries
+
+// Empty checks that logs table is up to date.
+func (mp *MigrationPlan) Empty() bool {
+	return len(mp.Queries) == 0
+}
+
+// Apply executes migration queries one by one, plan execution is stopped at the first error. Definitions
+// of kafka tables and materialized views are saved before dropping them, so logs delivery is restored
+// with the previous definitions if the table could not be altered or the new pipeline could not be created.
+func (mp *MigrationPlan) Apply() error {
+	saved, err := loadTablesDefinitions(mp.pipeline)
+	if err != nil {
+		return errors.Wrap(err, "migration of "+mp.Table+" is failed")
+	}
+
+	for _, name := range mp.pipeline {
+		if err := executeQuery(dropTableQuery(name)); err != nil {
+			return mp.rollback(err, saved)
+		}
+	}
+	for _, query := range mp.alters {
+		if err := executeQuery(query); err != nil {
+			return mp.rollback(err, saved)
+		}
+	}
+	for _, query := range mp.creates {
+		if err := executeQuery(query); err != nil {
+			return mp.rollback(err, saved)
+		}
+	}
+	return nil
+}
+
+// rollback drops kafka tables and views created by the plan and recreates the saved ones, kafka tables
+// are created before views reading from them.
+func (mp *MigrationPlan) rollback(cause error, saved map[string]string) error {
+	err := errors.Wrap(cause, "migration of "+mp.Table+" is failed")
+	for _, name := range mp.pipeline {
+		if dropErr := executeQuery(dropTableQuery(name)); dropErr != nil {
+			return errors.Wrap(err, "logs delivery is not restored: "+dropErr.Error())
+		}
+	}
+	for i := len(mp.pipeline) - 1; i >= 0; i-- {
+		query, ok := saved[mp.pipeline[i]]
+		if !ok {
+			continue
+		}
+		if createErr := executeQuery(query); createErr != nil {
+			return errors.Wrap(err, "logs delivery is not restored: "+createErr.Error())
+		}
+	}
+	return err
+}
+
+func dropTableQuery(name string) string {
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", db.DataBaseName, name)
+}
+
+type tableDefinition struct {
+	Name  string `db:"name"`
+	Query string `db:"create_table_query"`
+}
+
+// loadCreateTableQueries reads queries creating existing tables of kibouse database from system.tables.
+func loadCreateTableQueries(names []string) (map[string]string, error) {
+	definitions := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return definitions, nil
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = models.QuoteString(name)
+	}
+	request := db.NewRequest("system.tables", "name, create_table_query")
+	request.Where(fmt.Sprintf(
+		"database = %s AND name IN (%s)",
+		models.QuoteString(db.DataBaseName),
+		strings.Join(quoted, ", "),
+	))
+
+	selector := db.CreateDataSelector(request)
+	if selector == nil {
+		return nil, errors.New("kibouse db connection is not initialized")
+	}
+	tables := make([]tableDefinition, 0, len(names))
+	if err := selector(&tables); err != nil {
+		return nil, errors.Wrap(err, "cannot read definitions of kafka delivery tables")
+	}
+	for _, table := range tables {
+		definitions[table.Name] = table.Query
+	}
+	return definitions, nil
+}
+
+// PlanMigration compares model with columns of existing logs table from system.columns. Kafka tables don't
+// support ALTER, so they are recreated together with materialized views: views are dropped first to stop
+// consuming, data are not lost since kafka offsets are kept by the consumer group. Only tables of kibouse
+// database are migrated, kafka delivery queues are created there.
+func PlanMigration(table string, kafkaTopic string, dataStruct reflect.Type, kafka string) (*MigrationPlan, error) {
+	if database, _ := db.SplitTableName(table); database != db.DataBaseName {
+		return nil, errors.New("tables of other databases than " + db.DataBaseName + " are not migrated: " + table)
+	}
+	columns, err := LoadTableColumns(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("table not exists: " + table)
+	}
+
+	plan := &MigrationPlan{Table: table}
+	alters, unknown := alterColumnsQueries(table, dataStruct, columns)
+	plan.UnknownColumns = unknown
+	if len(alters) == 0 {
+		return plan, nil
+	}
+
+	logsQueue, logsConsumer := logsQueueSchemes(table, kafkaTopic, dataStruct, kafka)
+	histogramQueue, histogramConsumer := histogramQueueSchemes(table, kafkaTopic, dataStruct, kafka)
+
+	plan.pipeline = []string{
+		ConsumerPrefix + table,
+		ConsumerPrefix + models.PreparedHistogramDataTablePrefix + table,
+		StreamerPrefix + table,
+		StreamerPrefix + models.PreparedHistogramDataTablePrefix + table,
+	}
+	plan.alters = alters
+	for _, scheme := range []db.Scheme{logsQueue, histogramQueue, logsConsumer, histogramConsumer} {
+		query, err := scheme.BuildScheme(db.DataBaseName)
+		if err != nil {
+			return nil, err
+		}
+		plan.creates = append(plan.creates, query)
+	}
+	for _, name := range plan.pipeline {
+		plan.Queries = append(plan.Queries, dropTableQuery(name))
+	}
+	plan.Queries = append(plan.Queries, plan.alters...)
+	plan.Queries = append(plan.Queries, plan.creates...)
+
+	return plan, nil
+}
+
+// alterColumnsQueries generates ADD/MODIFY COLUMN queries for the logs table, names of columns
+// absent in the model are returned as well.
+func alterColumnsQueries(table string, dataStruct reflect.Type, columns []models.CHField) ([]string, []string) {
+	existing := make(map[string]string, len(columns))
+	for _, column := range columns {
+		existing[column.CHName] = column.CHType
+	}
+
+	queries := make([]string, 0)
+	modelColumns := make(map[string]struct{}, dataStruct.NumField())
+	previous := ""
+	for i := 0; i < dataStruct.NumField(); i++ {
+		field := dataStruct.Field(i)
+		if skip, ok := field.Tag.Lookup("skip"); ok && skip == "db" {
+			continue
+		}
+		definition := createFieldDefinition(field, MergeTreeFamily)
+		if definition == "" {
+			continue
+		}
+		name := field.Tag.Get("db")
+		modelColumns[name] = struct{}{}
+
+		chType, ok := existing[name]
+		switch {
+		case !ok && previous == "":
+			// FIRST is not supported by old clickhouse versions, so column is added to the end
+			queries = append(queries, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", db.TableFullName(table), definition))
+		case !ok:
+			queries = append(queries, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s AFTER %s", db.TableFullName(table), definition, previous))
+		case chType != field.Tag.Get("type"):
+			queries = append(queries, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", db.TableFullName(table), definition))
+		}
+		previous = name
+	}
+
+	unknown := make([]string, 0)
+	for _, column := range columns {
+		if _, ok := modelColumns[column.CHName]; !ok {
+			unknown = append(unknown, column.CHName)
+		}
+	}
+	return queries, unknown
+}
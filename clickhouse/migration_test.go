@@ -0,0 +1,134 @@
+package clickhouse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+)
+
+type migratedLogs struct {
+	Day     string `db:"day" type:"Date" partitioning:"true"`
+	TS      uint64 `db:"ts" type:"UInt64" ch_index_pos:"1"`
+	Status  uint16 `db:"status" type:"UInt16"`
+	Message string `db:"message" type:"String"`
+	Host    string `db:"host" type:"String" default:""`
+	Table   string `db:"_table" type:"String" skip:"db"`
+}
+
+func TestAlterColumnsQueries(t *testing.T) {
+	testData := []struct {
+		caseName string
+		columns  []models.CHField
+		queries  []string
+		unknown  []string
+	}{
+		{
+			caseName: "up to date table",
+			columns: []models.CHField{
+				{CHName: "day", CHType: "Date"},
+				{CHName: "ts", CHType: "UInt64"},
+				{CHName: "status", CHType: "UInt16"},
+				{CHName: "message", CHType: "String"},
+				{CHName: "host", CHType: "String"},
+			},
+			queries: []string{},
+			unknown: []string{},
+		},
+		{
+			caseName: "changed model",
+			columns: []models.CHField{
+				{CHName: "ts", CHType: "UInt64"},
+				{CHName: "status", CHType: "String"},
+				{CHName: "message", CHType: "String"},
+				{CHName: "pid", CHType: "UInt64"},
+			},
+			queries: []string{
+				"ALTER TABLE logs.migrated ADD COLUMN day Date",
+				"ALTER TABLE logs.migrated MODIFY COLUMN status UInt16",
+				"ALTER TABLE logs.migrated ADD COLUMN host String DEFAULT '' AFTER message",
+			},
+			unknown: []string{"pid"},
+		},
+	}
+
+	for _, test := range testData {
+		queries, unknown := alterColumnsQueries("migrated", reflect.TypeOf(migratedLogs{}), test.columns)
+		if !reflect.DeepEqual(queries, test.queries) || !reflect.DeepEqual(unknown, test.unknown) {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.queries, test.unknown,
+				"\n got: ", queries, unknown,
+			)
+		}
+	}
+}
+
+func TestMigrationPlanApplyFailure(t *testing.T) {
+	plan := MigrationPlan{
+		Table:    "logs_gate",
+		pipeline: []string{"consumer_logs_gate", "streamer_logs_gate"},
+		alters:   []string{"ALTER TABLE logs.logs_gate ADD COLUMN host String"},
+		creates:  []string{"CREATE TABLE streamer (new)", "CREATE MATERIALIZED VIEW consumer (new)"},
+	}
+	saved := map[string]string{
+		"consumer_logs_gate": "CREATE MATERIALIZED VIEW consumer (old)",
+		"streamer_logs_gate": "CREATE TABLE streamer (old)",
+	}
+	defer func(execute func(string) error, load func([]string) (map[string]string, error)) {
+		executeQuery, loadTablesDefinitions = execute, load
+	}(executeQuery, loadTablesDefinitions)
+	loadTablesDefinitions = func([]string) (map[string]string, error) {
+		return saved, nil
+	}
+
+	testData := []struct {
+		caseName string
+		failed   string
+		expected []string
+	}{
+		{
+			caseName: "failed alter",
+			failed:   "ALTER",
+			expected: []string{
+				"DROP TABLE IF EXISTS logs.consumer_logs_gate",
+				"DROP TABLE IF EXISTS logs.streamer_logs_gate",
+				"DROP TABLE IF EXISTS logs.consumer_logs_gate",
+				"DROP TABLE IF EXISTS logs.streamer_logs_gate",
+				"CREATE TABLE streamer (old)",
+				"CREATE MATERIALIZED VIEW consumer (old)",
+			},
+		},
+		{
+			caseName: "failed view creation",
+			failed:   "CREATE MATERIALIZED VIEW consumer (new)",
+			expected: []string{
+				"DROP TABLE IF EXISTS logs.consumer_logs_gate",
+				"DROP TABLE IF EXISTS logs.streamer_logs_gate",
+				"ALTER TABLE logs.logs_gate ADD COLUMN host String",
+				"CREATE TABLE streamer (new)",
+				"DROP TABLE IF EXISTS logs.consumer_logs_gate",
+				"DROP TABLE IF EXISTS logs.streamer_logs_gate",
+				"CREATE TABLE streamer (old)",
+				"CREATE MATERIALIZED VIEW consumer (old)",
+			},
+		},
+	}
+
+	for _, test := range testData {
+		var executed []string
+		executeQuery = func(query string) error {
+			if strings.HasPrefix(query, test.failed) {
+				return errors.New("query is failed")
+			}
+			executed = append(executed, query)
+			return nil
+		}
+		if err := plan.Apply(); err == nil || !reflect.DeepEqual(executed, test.expected) {
+			t.Error("For", test.caseName, "\n expected: ", test.expected, "\n got: ", executed, err)
+		}
+	}
+}
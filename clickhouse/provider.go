@@ -10,8 +10,11 @@ import (
 
 // ChDataProvider selects from Clickhouse log records with specified structure by SQL requests.
 type ChDataProvider struct {
-	data  wrappers.ChDataWrapper
-	table string
+	data    wrappers.ChDataWrapper
+	table   string
+	schemes map[string]*models.ModelInfo
+	// union of tables selected by requests, it is set for index patterns and tables with computed fields
+	source *indexSource
 }
 
 // DataTable returns clickhouse table name with data provided,
@@ -30,6 +33,20 @@ func (dp *ChDataProvider) DataScheme() *models.ModelInfo {
 	return nil
 }
 
+// TablesSchemes returns data model scheme of each table read by the provider.
+func (dp *ChDataProvider) TablesSchemes() map[string]*models.ModelInfo {
+	if dp == nil {
+		return nil
+	}
+	if dp.schemes != nil {
+		return dp.schemes
+	}
+	if scheme := dp.DataScheme(); scheme != nil {
+		return map[string]*models.ModelInfo{dp.table: scheme}
+	}
+	return nil
+}
+
 // FetchData selects data from specified table using SQL requests.
 func (dp *ChDataProvider) FetchData(req *db.Request) (wrappers.ChDataWrapper, error) {
 	if dp == nil || dp.data == nil {
@@ -56,15 +73,42 @@ func newProvider(table string, data wrappers.ChDataWrapper) *ChDataProvider {
 }
 
 // NewProvider creates new object for fetching logs records from DB by SQL requests.
+// Index pattern matching several tables is read from all of them.
 func NewProvider(index string) (db.DataProvider, error) {
-	table, err := db.GetTableByIndexPattern(index)
+	tables, err := db.GetTablesByIndexPattern(index)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot create data provider for index " + index)
+		return nil, errors.Wrap(err, "cannot create data provider for index "+index)
 	}
-	wrapper, err := wrappers.CreateDataContainer(table)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot create data provider for table " + table)
+	if len(tables) == 1 {
+		wrapper, err := wrappers.CreateDataContainer(tables[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create data provider for table "+tables[0])
+		}
+		loadModelJSONPaths(tables[0], wrapper.ModelScheme())
+		provider := newProvider(tables[0], wrapper)
+		if scheme := wrapper.ModelScheme(); scheme.HasComputedFields() {
+			// computed fields are added to the columns of table
+			schemes := map[string]*models.ModelInfo{tables[0]: scheme}
+			provider.source = newIndexSource(tables[0], tables, schemes)
+		}
+		return provider, nil
 	}
 
-	return newProvider(table, wrapper), nil
+	schemes := make(map[string]*models.ModelInfo, len(tables))
+	for _, table := range tables {
+		wrapper, err := wrappers.CreateDataContainer(table)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create data provider for table "+table)
+		}
+		loadModelJSONPaths(table, wrapper.ModelScheme())
+		schemes[table] = wrapper.ModelScheme()
+	}
+
+	// rows of all tables are loaded into the union model, inverted indexes of tables are not used
+	source := newIndexSource(index, tables, schemes)
+
+	provider := newProvider(index, wrappers.NewModelInfoWrapper(source.union))
+	provider.schemes = schemes
+	provider.source = source
+	return provider, nil
 }
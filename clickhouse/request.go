@@ -4,16 +4,51 @@ import (
 	"fmt"
 	"strings"
 
+	"kibouse/data/models"
 	"kibouse/db"
 )
 
+// NewRequestTpl creates new template of SQL request for data selection and aggregation from tables read by the provider.
+func NewRequestTpl(conn db.DataProvider) *db.Request {
+	// index pattern covering several tables or table with computed fields
+	if source := providerSource(conn); source != nil {
+		return db.NewRequest(source.from(), source.what())
+	}
+	return newIndexRequestTpl(conn.DataTable())
+}
+
+// NewPreparedHistogramRequestTpl creates new template of SQL request selecting prepared histogram data
+// of tables read by the provider.
+func NewPreparedHistogramRequestTpl(conn db.DataProvider) *db.Request {
+	if source := providerSource(conn); source != nil {
+		return db.NewRequest(mergeTables(models.PreparedHistogramDataTablePrefix, source.tables), "*, "+tableNameColumn)
+	}
+	return newIndexRequestTpl(models.PreparedHistogramDataTablePrefix + conn.DataTable())
+}
 
-// NewRequestTpl creates new template of SQL request for data selection and aggregation.
-func NewRequestTpl(index string) *db.Request {
+// providerSource returns union of tables read by clickhouse data provider.
+func providerSource(conn db.DataProvider) *indexSource {
+	if provider, ok := conn.(*ChDataProvider); ok && provider != nil {
+		return provider.source
+	}
+	return nil
+}
+
+func newIndexRequestTpl(index string) *db.Request {
 	// modify elastic index name with wildcard symbol '*' according to regular expressions syntax and use it in Merge engine
 	// for reading from all tables matched the pattern
+	database, table := db.SplitTableName(index)
 	return db.NewRequest(
-		fmt.Sprintf("merge(%s, '^%s')", db.DataBaseName, strings.Replace(index, "*", ".*", 1)),
+		fmt.Sprintf("merge(%s, '^%s')", database, strings.Replace(table, "*", ".*", 1)),
 		"*, _table",
 	)
 }
+
+// SelectColumns sets columns selected by the request of logs rows, name of the table containing
+// the row is selected too. All columns are selected if list is empty.
+func SelectColumns(request *db.Request, columns []string) *db.Request {
+	if len(columns) > 0 {
+		request.What(strings.Join(columns, ", ") + ", " + tableNameColumn)
+	}
+	return request
+}
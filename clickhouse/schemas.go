@@ -11,6 +11,7 @@ import (
 
 	"kibouse/data/models"
 	"kibouse/db"
+	"kibouse/index"
 )
 
 type EngineType uint
@@ -22,8 +23,9 @@ const (
 
 	DefaultIndexGranularity uint = 8192
 
-	StreamerPrefix = "queue_"
-	ConsumerPrefix = "consumer_"
+	StreamerPrefix      = "queue_"
+	ConsumerPrefix      = "consumer_"
+	FullTextIndexPrefix = "fts_"
 )
 
 // CreateLogsTableScheme creates scheme for adding new table with RuntimeLog engine
@@ -36,6 +38,45 @@ func CreateLogsTableScheme(name string, dataStructure reflect.Type) db.Scheme {
 	}
 }
 
+// FullTextIndex contains parameters of data skipping indexes created for full text searchable columns,
+// indexes are created only for tokenbf_v1 and ngrambf_v1 full text search backends.
+type FullTextIndex struct {
+	Backend         string
+	BloomFilterSize uint
+	HashFunctions   uint
+	Seed            uint
+	NgramSize       uint
+	Granularity     uint
+}
+
+// definition returns data skipping index declaration for the column.
+func (fti FullTextIndex) definition(column models.CHField) string {
+	indexType := ""
+	switch fti.Backend {
+	case index.TokenBloomFilterBackend:
+		indexType = fmt.Sprintf("tokenbf_v1(%d, %d, %d)", fti.BloomFilterSize, fti.HashFunctions, fti.Seed)
+	case index.NgramBloomFilterBackend:
+		indexType = fmt.Sprintf("ngrambf_v1(%d, %d, %d, %d)", fti.NgramSize, fti.BloomFilterSize, fti.HashFunctions, fti.Seed)
+	default:
+		return ""
+	}
+	return fmt.Sprintf("INDEX %s%s %s TYPE %s GRANULARITY %d",
+		FullTextIndexPrefix, column.CHName, index.SkipIndexExpression(column), indexType, fti.Granularity)
+}
+
+// Retention contains number of days logs table data is kept, zero value disables data expiration.
+type Retention struct {
+	Logs          uint
+	InvertedIndex uint
+	Histogram     uint
+}
+
+// StorageSettings contains optional settings of logs storing tables.
+type StorageSettings struct {
+	FullTextIndex FullTextIndex
+	Retention     Retention
+}
+
 func newBaseMergeTreeScheme(name string, dataStructure reflect.Type, indGranularity uint) *mergeTreeTableScheme {
 	return &mergeTreeTableScheme{
 		schemeBase: schemeBase{
@@ -46,8 +87,7 @@ func newBaseMergeTreeScheme(name string, dataStructure reflect.Type, indGranular
 	}
 }
 
-// CreateMergeTreeTableScheme creates scheme for adding new table with MergeTree family engines
-func CreateMergeTreeTableScheme(name string, dataStructure reflect.Type, indGranularity uint) db.Scheme {
+func newMergeTreeScheme(name string, dataStructure reflect.Type, indGranularity uint) *mergeTreeTableScheme {
 	scheme := newBaseMergeTreeScheme(name, dataStructure, indGranularity)
 	scheme.getEngineTypeDefinition = func() string {
 		return "ENGINE = MergeTree() "
@@ -55,6 +95,27 @@ func CreateMergeTreeTableScheme(name string, dataStructure reflect.Type, indGran
 	return scheme
 }
 
+// CreateMergeTreeTableScheme creates scheme for adding new table with MergeTree family engines
+func CreateMergeTreeTableScheme(name string, dataStructure reflect.Type, indGranularity uint) db.Scheme {
+	return newMergeTreeScheme(name, dataStructure, indGranularity)
+}
+
+// CreateFullTextIndexedMergeTreeTableScheme creates scheme for adding new MergeTree table with data skipping
+// indexes for all full text searchable columns.
+func CreateFullTextIndexedMergeTreeTableScheme(name string, dataStructure reflect.Type, indGranularity uint, fts FullTextIndex) db.Scheme {
+	scheme := newMergeTreeScheme(name, dataStructure, indGranularity)
+	scheme.fullTextIndex = fts
+	return scheme
+}
+
+// CreateExpiringMergeTreeTableScheme creates scheme for adding new MergeTree table, which data is removed
+// by TTL after specified number of days since partitioning date.
+func CreateExpiringMergeTreeTableScheme(name string, dataStructure reflect.Type, indGranularity uint, retentionDays uint) db.Scheme {
+	scheme := newMergeTreeScheme(name, dataStructure, indGranularity)
+	scheme.retentionDays = retentionDays
+	return scheme
+}
+
 // CreateCollapsingMergeTreeTableScheme creates scheme for adding new table with CollapsingMergeTree family engines
 func CreateCollapsingMergeTreeTableScheme(name string, dataStructure reflect.Type, sign string, indGranularity uint) db.Scheme {
 	scheme := newBaseMergeTreeScheme(name, dataStructure, indGranularity)
@@ -64,8 +125,7 @@ func CreateCollapsingMergeTreeTableScheme(name string, dataStructure reflect.Typ
 	return scheme
 }
 
-// CreateSummingMergeTreeTableScheme creates scheme for adding new table with SummingMergeTree family engines
-func CreateSummingMergeTreeTableScheme(name string, dataStructure reflect.Type, indGranularity uint) db.Scheme {
+func newSummingMergeTreeScheme(name string, dataStructure reflect.Type, indGranularity uint) *mergeTreeTableScheme {
 	scheme := newBaseMergeTreeScheme(name, dataStructure, indGranularity)
 	scheme.getEngineTypeDefinition = func() string {
 		return "ENGINE = SummingMergeTree() "
@@ -73,6 +133,11 @@ func CreateSummingMergeTreeTableScheme(name string, dataStructure reflect.Type,
 	return scheme
 }
 
+// CreateSummingMergeTreeTableScheme creates scheme for adding new table with SummingMergeTree family engines
+func CreateSummingMergeTreeTableScheme(name string, dataStructure reflect.Type, indGranularity uint) db.Scheme {
+	return newSummingMergeTreeScheme(name, dataStructure, indGranularity)
+}
+
 // CreateKafkaTableScheme creates scheme for adding new table with Kafka engines
 func CreateKafkaTableScheme(name string,
 	dataStructure reflect.Type,
@@ -133,17 +198,18 @@ func CreateDataTransformMatViewScheme(name string,
 }
 
 // CreateDataDeliveryQueue creates tables for storing data delivered via kafka.
-func CreateDataDeliveryQueue(name string, kafkaTopic string, dataStruct reflect.Type, kafka string) error {
-	if err := CreateLogsDeliveryQueue(name, kafkaTopic, dataStruct, kafka); err != nil {
+func CreateDataDeliveryQueue(name string, kafkaTopic string, dataStruct reflect.Type, kafka string, settings StorageSettings) error {
+	if err := CreateLogsDeliveryQueue(name, kafkaTopic, dataStruct, kafka, settings); err != nil {
 		return err
 	}
 
-	if err := CreateHistogramPreCalcQueue(name, kafkaTopic, dataStruct, kafka); err != nil {
+	if err := CreateHistogramPreCalcQueue(name, kafkaTopic, dataStruct, kafka, settings.Retention.Histogram); err != nil {
 		return err
 	}
 
-	if models.InvertedIndexRequired(dataStruct) {
-		if err := CreateLogsIndexingQueue(name, kafkaTopic, kafka); err != nil {
+	// data skipping indexes are used instead of inverted index
+	if models.InvertedIndexRequired(dataStruct) && !index.IsSkipIndexBackend(settings.FullTextIndex.Backend) {
+		if err := CreateLogsIndexingQueue(name, kafkaTopic, kafka, settings.Retention.InvertedIndex); err != nil {
 			return err
 		}
 	}
@@ -152,46 +218,51 @@ func CreateDataDeliveryQueue(name string, kafkaTopic string, dataStruct reflect.
 }
 
 // CreateDataDeliveryQueue creates tables for storing log entries delivered via kafka.
-func CreateLogsDeliveryQueue(name string, kafkaTopic string, dataStruct reflect.Type, kafka string) error {
-	if err := db.CreateTable(
-		CreateMergeTreeTableScheme(
-			name, dataStruct, DefaultIndexGranularity),
-	); err != nil {
+func CreateLogsDeliveryQueue(name string, kafkaTopic string, dataStruct reflect.Type, kafka string, settings StorageSettings) error {
+	logsTable := newMergeTreeScheme(name, dataStruct, DefaultIndexGranularity)
+	logsTable.fullTextIndex = settings.FullTextIndex
+	logsTable.retentionDays = settings.Retention.Logs
+	if err := db.CreateTable(logsTable); err != nil {
 		return err
 	}
-	if err := db.CreateTable(
-		CreateKafkaTableScheme(StreamerPrefix+name,
-			dataStruct,
-			kafka,
-			kafkaTopic,
-			name,
-			"JSONEachRow",
-			`\0`,
-			"",
-			1),
-	); err != nil {
+	queue, consumer := logsQueueSchemes(name, kafkaTopic, dataStruct, kafka)
+	if err := db.CreateTable(queue); err != nil {
 		return err
 	}
-	if err := db.CreateTable(
-		CreateMatViewScheme(
-			ConsumerPrefix+name,
-			dataStruct,
-			StreamerPrefix+name,
-			name),
-	); err != nil {
+	if err := db.CreateTable(consumer); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// logsQueueSchemes returns schemes of kafka table and materialized view delivering log entries to the logs table.
+func logsQueueSchemes(name string, kafkaTopic string, dataStruct reflect.Type, kafka string) (queue db.Scheme, consumer db.Scheme) {
+	queue = CreateKafkaTableScheme(StreamerPrefix+name,
+		dataStruct,
+		kafka,
+		kafkaTopic,
+		name,
+		"JSONEachRow",
+		`\0`,
+		"",
+		1)
+	consumer = CreateMatViewScheme(
+		ConsumerPrefix+name,
+		dataStruct,
+		StreamerPrefix+name,
+		name)
+	return queue, consumer
+}
+
 // CreateLogsIndexingQueue uses for creating data delivery queue for logs indexing.
-func CreateLogsIndexingQueue(indexingTable string, kafkaTopic string, kafka string) error {
+func CreateLogsIndexingQueue(indexingTable string, kafkaTopic string, kafka string, retentionDays uint) error {
 	if err := db.CreateTable(
-		CreateMergeTreeTableScheme(
+		CreateExpiringMergeTreeTableScheme(
 			models.InvertedIndexTablePrefix+indexingTable,
 			reflect.TypeOf(models.InvertedIndex{}),
 			DefaultIndexGranularity,
+			retentionDays,
 		),
 	); err != nil {
 		return err
@@ -225,43 +296,60 @@ func CreateLogsIndexingQueue(indexingTable string, kafkaTopic string, kafka stri
 }
 
 // CreateHistogramPreCalcQueue uses for creating data delivery queue for histogram pre calculation.
-func CreateHistogramPreCalcQueue(logsTable string, kafkaTopic string, dataStruct reflect.Type, kafka string) error {
-	if err := db.CreateTable(
-		CreateKafkaTableScheme(StreamerPrefix+models.PreparedHistogramDataTablePrefix+logsTable,
-			dataStruct,
-			kafka,
-			kafkaTopic,
-			models.PreparedHistogramDataTablePrefix+logsTable,
-			"JSONEachRow",
-			`\0`,
-			"",
-			1),
-	); err != nil {
+func CreateHistogramPreCalcQueue(logsTable string, kafkaTopic string, dataStruct reflect.Type, kafka string, retentionDays uint) error {
+	queue, consumer := histogramQueueSchemes(logsTable, kafkaTopic, dataStruct, kafka)
+	if err := db.CreateTable(queue); err != nil {
 		return err
 	}
-	if err := db.CreateTable(
-		CreateSummingMergeTreeTableScheme(
-			models.PreparedHistogramDataTablePrefix+logsTable,
-			reflect.TypeOf(models.HistogramPreCalcTable{}),
-			DefaultIndexGranularity,
-		),
-	); err != nil {
+	histogramTable := newSummingMergeTreeScheme(
+		models.PreparedHistogramDataTablePrefix+logsTable,
+		reflect.TypeOf(models.HistogramPreCalcTable{}),
+		DefaultIndexGranularity,
+	)
+	histogramTable.retentionDays = retentionDays
+	if err := db.CreateTable(histogramTable); err != nil {
 		return err
 	}
-	if err := db.CreateTable(
-		CreateDataTransformMatViewScheme(
-			ConsumerPrefix+models.PreparedHistogramDataTablePrefix+logsTable,
-			reflect.TypeOf(models.HistogramPreCalcTable{}),
-			StreamerPrefix+models.PreparedHistogramDataTablePrefix+logsTable,
-			models.PreparedHistogramDataTablePrefix+logsTable,
-			"today() AS day, toInt64((ts) / 300000000000) as key, count() as count",
-			"day, key"),
-	); err != nil {
+	if err := db.CreateTable(consumer); err != nil {
 		return err
 	}
 	return nil
 }
 
+// histogramQueueSchemes returns schemes of kafka table and materialized view delivering log entries
+// to the histogram pre calculation table.
+func histogramQueueSchemes(logsTable string, kafkaTopic string, dataStruct reflect.Type, kafka string) (queue db.Scheme, consumer db.Scheme) {
+	queue = CreateKafkaTableScheme(StreamerPrefix+models.PreparedHistogramDataTablePrefix+logsTable,
+		dataStruct,
+		kafka,
+		kafkaTopic,
+		models.PreparedHistogramDataTablePrefix+logsTable,
+		"JSONEachRow",
+		`\0`,
+		"",
+		1)
+	consumer = CreateDataTransformMatViewScheme(
+		ConsumerPrefix+models.PreparedHistogramDataTablePrefix+logsTable,
+		reflect.TypeOf(models.HistogramPreCalcTable{}),
+		StreamerPrefix+models.PreparedHistogramDataTablePrefix+logsTable,
+		models.PreparedHistogramDataTablePrefix+logsTable,
+		fmt.Sprintf("today() AS day, toInt64((%s) / 300000000000) as key, count() as count", timestampNanosExpression(dataStruct)),
+		"day, key")
+	return queue, consumer
+}
+
+// timestampNanosExpression returns expression converting model timestamp to nanoseconds, "ts" column is used by default.
+func timestampNanosExpression(dataStruct reflect.Type) string {
+	fields, err := models.CreateDBFieldsInfoMap(dataStruct)
+	if err != nil {
+		return "ts"
+	}
+	if timestamp, ok := (models.ModelInfo{DataFields: fields}).GetTimestampField(); ok {
+		return timestamp.TimeNanosExpression()
+	}
+	return "ts"
+}
+
 type schemeBase struct {
 	name          string
 	dataStructure reflect.Type
@@ -296,7 +384,7 @@ func buildMergeTreeIndex(fields []indexField) string {
 	return result[:len(result)-1]
 }
 
-func buildMergeTreeEngineSettings(partitioning string, indexedFields []indexField, granularity uint) string {
+func buildMergeTreeEngineSettings(partitioning string, indexedFields []indexField, granularity uint, retentionDays uint) string {
 	settings := strings.Builder{}
 	if partitioning != "" {
 		settings.WriteString(fmt.Sprintf("PARTITION BY %s ", partitioning))
@@ -304,6 +392,10 @@ func buildMergeTreeEngineSettings(partitioning string, indexedFields []indexFiel
 	if chIndex := buildMergeTreeIndex(indexedFields); chIndex != "" {
 		settings.WriteString(fmt.Sprintf("ORDER BY (%s) ", chIndex))
 	}
+	// data expires by partitioning date, so whole partitions are removed at once
+	if partitioning != "" && retentionDays != 0 {
+		settings.WriteString(fmt.Sprintf("TTL %s + toIntervalDay(%d) ", partitioning, retentionDays))
+	}
 	if granularity != 0 {
 		settings.WriteString(fmt.Sprintf("SETTINGS index_granularity=%d;", granularity))
 	}
@@ -315,6 +407,8 @@ type mergeTreeTableScheme struct {
 	schemeBase
 	indGranularity          uint
 	getEngineTypeDefinition func() string
+	fullTextIndex           FullTextIndex
+	retentionDays           uint
 }
 
 type indexField struct {
@@ -341,7 +435,7 @@ func (mtts *mergeTreeTableScheme) mergeTreeEngineBuilder() func(data reflect.Typ
 		}
 
 		return mtts.getEngineTypeDefinition() +
-			buildMergeTreeEngineSettings(partition, indices, mtts.indGranularity)
+			buildMergeTreeEngineSettings(partition, indices, mtts.indGranularity, mtts.retentionDays)
 	}
 }
 
@@ -349,7 +443,13 @@ func (mtts *mergeTreeTableScheme) BuildScheme(dbName string) (string, error) {
 	return buildScheme(mtts.dataStructure,
 		tableHead(dbName+"."+mtts.name),
 		func(t reflect.Type) string {
-			return fullFieldsDefinition(t, false, MergeTreeFamily)
+			definitions := fieldsDefinitions(t, false, MergeTreeFamily)
+			for _, field := range models.GetIndexedFields(t) {
+				if skipIndex := mtts.fullTextIndex.definition(field.CHField); skipIndex != "" {
+					definitions = append(definitions, skipIndex)
+				}
+			}
+			return "(" + strings.Join(definitions, ", ") + ")"
 		},
 		mtts.mergeTreeEngineBuilder(),
 	)
@@ -457,6 +557,10 @@ func fullFieldsDefinition(data reflect.Type, skipPartitioningField bool, engine
 	if data.Kind() != reflect.Struct {
 		return ""
 	}
+	return "(" + strings.Join(fieldsDefinitions(data, skipPartitioningField, engine), ", ") + ")"
+}
+
+func fieldsDefinitions(data reflect.Type, skipPartitioningField bool, engine EngineType) []string {
 	fieldsDef := make([]string, 0, data.NumField())
 	for i := 0; i < data.NumField(); i++ {
 		field := data.Field(i)
@@ -472,7 +576,7 @@ func fullFieldsDefinition(data reflect.Type, skipPartitioningField bool, engine
 		}
 		fieldsDef = append(fieldsDef, fieldDef)
 	}
-	return "(" + strings.Join(fieldsDef, ", ") + ")"
+	return fieldsDef
 }
 
 func createFieldDefinition(field reflect.StructField, engine EngineType) string {
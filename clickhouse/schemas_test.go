@@ -4,6 +4,9 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"kibouse/data/models"
+	"kibouse/db"
 )
 
 type responsesMapping struct {
@@ -178,6 +181,160 @@ func TestCreateMergeTreeTablesScheme(t *testing.T) {
 	}
 }
 
+func TestCreateExpiringMergeTreeTableScheme(t *testing.T) {
+	testData := []struct {
+		caseName      string
+		data          reflect.Type
+		retentionDays uint
+		result        string
+	}{
+		{
+			caseName:      "partitioned table",
+			data:          reflect.TypeOf(clickhouseTables{}),
+			retentionDays: 7,
+			result:        "CREATE TABLE IF NOT EXISTS logs.expiring (day Date DEFAULT today(), _index String)ENGINE = MergeTree() PARTITION BY day ORDER BY (_index) TTL day + toIntervalDay(7) SETTINGS index_granularity=8192;",
+		},
+		{
+			caseName:      "retention disabled",
+			data:          reflect.TypeOf(clickhouseTables{}),
+			retentionDays: 0,
+			result:        "CREATE TABLE IF NOT EXISTS logs.expiring (day Date DEFAULT today(), _index String)ENGINE = MergeTree() PARTITION BY day ORDER BY (_index) SETTINGS index_granularity=8192;",
+		},
+		{
+			caseName:      "table without partitioning",
+			data:          reflect.TypeOf(indexedLogs{}),
+			retentionDays: 7,
+			result:        "CREATE TABLE IF NOT EXISTS logs.expiring (ts UInt64, message String, tags Array(String))ENGINE = MergeTree() ORDER BY (ts) SETTINGS index_granularity=8192;",
+		},
+	}
+
+	for _, test := range testData {
+		scheme := CreateExpiringMergeTreeTableScheme("expiring", test.data, DefaultIndexGranularity, test.retentionDays)
+		result, _ := scheme.BuildScheme("logs")
+		if result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result,
+			)
+		}
+	}
+}
+
+func TestCreateDeclaredModelScheme(t *testing.T) {
+	emptyDefault := ""
+	definition := models.ModelDefinition{
+		Table: "logs_nginx",
+		Columns: []models.ColumnDefinition{
+			{Name: "uuid", Type: "UInt64", UUID: true, MVTransform: "cityHash64(uuid)", BaseType: "String"},
+			{Name: "day", Type: "Date", Partitioning: true, MVTransform: "today()"},
+			{Name: "ts", Type: "UInt64", Timestamp: true, CHIndexPos: 1},
+			{Name: "remote_addr", Type: "String", KibanaName: "client.ip", Default: &emptyDefault},
+			{Name: "request", Type: "String", InvIndex: true},
+			{Name: "tags", Type: "Array(String)"},
+		},
+	}
+
+	model, err := models.CreateModelType(definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		caseName string
+		scheme   db.Scheme
+		result   string
+	}{
+		{
+			caseName: "logs table",
+			scheme:   CreateMergeTreeTableScheme(definition.Table, model, DefaultIndexGranularity),
+			result:   "CREATE TABLE IF NOT EXISTS logs.logs_nginx (uuid UInt64, day Date, ts UInt64, remote_addr String DEFAULT '', request String, tags Array(String))ENGINE = MergeTree() PARTITION BY day ORDER BY (ts) SETTINGS index_granularity=8192;",
+		},
+		{
+			caseName: "kafka table",
+			scheme:   CreateKafkaTableScheme("queue_logs_nginx", model, "kafka:9092", "logs_nginx", "logs_nginx", "JSONEachRow", `\0`, "", 1),
+			result:   "CREATE TABLE IF NOT EXISTS logs.queue_logs_nginx (uuid String, ts UInt64, remote_addr String DEFAULT '', request String, tags Array(String))ENGINE = Kafka SETTINGS kafka_broker_list = 'kafka:9092', kafka_topic_list = 'logs_nginx', kafka_group_name = 'logs_nginx', kafka_format = 'JSONEachRow', kafka_row_delimiter = '\\0', kafka_schema = '', kafka_num_consumers = 1;",
+		},
+	}
+
+	for _, test := range testData {
+		result, err := test.scheme.BuildScheme("logs")
+		if err != nil || result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result, err,
+			)
+		}
+	}
+
+	fields, err := models.CreateDBFieldsInfoMap(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := models.ModelInfo{DBName: definition.Table, DataFields: fields}
+	if ts, ok := info.GetTimestampField(); !ok || ts.CHName != "ts" {
+		t.Error("For declared model timestamp field expected")
+	}
+	if fields["remote_addr"].KibanaName != "client.ip" || !fields["request"].FullTextSearch {
+		t.Error("For declared model kibana name and full text search attributes expected")
+	}
+
+	invalid := []models.ModelDefinition{
+		{Table: "nginx", Columns: definition.Columns},
+		{Table: "logs_empty"},
+		{Table: "logs_duplicated", Columns: []models.ColumnDefinition{{Name: "ts", Type: "UInt64"}, {Name: "ts", Type: "UInt64"}}},
+		{Table: "logs_untyped", Columns: []models.ColumnDefinition{{Name: "ts"}}},
+	}
+	for _, definition := range invalid {
+		if _, err := models.CreateModelType(definition); err == nil {
+			t.Error("For", definition.Table, "error expected")
+		}
+	}
+}
+
+type indexedLogs struct {
+	TS      uint64   `db:"ts" type:"UInt64" ch_index_pos:"1"`
+	Message string   `db:"message" type:"String" inv_index:"true"`
+	Tags    []string `db:"tags" type:"Array(String)" inv_index:"true"`
+}
+
+func TestCreateFullTextIndexedMergeTreeTableScheme(t *testing.T) {
+	testData := []struct {
+		caseName string
+		fts      FullTextIndex
+		result   string
+	}{
+		{
+			caseName: "inverted index",
+			fts:      FullTextIndex{Backend: "inverted_index"},
+			result:   "CREATE TABLE IF NOT EXISTS logs.indexed (ts UInt64, message String, tags Array(String))ENGINE = MergeTree() ORDER BY (ts) SETTINGS index_granularity=8192;",
+		},
+		{
+			caseName: "token bloom filter",
+			fts:      FullTextIndex{Backend: "tokenbf_v1", BloomFilterSize: 32768, HashFunctions: 3, Granularity: 4},
+			result:   "CREATE TABLE IF NOT EXISTS logs.indexed (ts UInt64, message String, tags Array(String), INDEX fts_message lower(message) TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 4, INDEX fts_tags lower(arrayStringConcat(tags, ' ')) TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 4)ENGINE = MergeTree() ORDER BY (ts) SETTINGS index_granularity=8192;",
+		},
+		{
+			caseName: "ngram bloom filter",
+			fts:      FullTextIndex{Backend: "ngrambf_v1", BloomFilterSize: 1024, HashFunctions: 2, Seed: 1, NgramSize: 4, Granularity: 1},
+			result:   "CREATE TABLE IF NOT EXISTS logs.indexed (ts UInt64, message String, tags Array(String), INDEX fts_message lower(message) TYPE ngrambf_v1(4, 1024, 2, 1) GRANULARITY 1, INDEX fts_tags lower(arrayStringConcat(tags, ' ')) TYPE ngrambf_v1(4, 1024, 2, 1) GRANULARITY 1)ENGINE = MergeTree() ORDER BY (ts) SETTINGS index_granularity=8192;",
+		},
+	}
+
+	for _, test := range testData {
+		scheme := CreateFullTextIndexedMergeTreeTableScheme("indexed", reflect.TypeOf(indexedLogs{}), DefaultIndexGranularity, test.fts)
+		result, _ := scheme.BuildScheme("logs")
+		if result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result,
+			)
+		}
+	}
+}
+
 func TestCreateCollapsingMergeTreeTableScheme(t *testing.T) {
 	testData := []struct {
 		dbName      string
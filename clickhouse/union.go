@@ -0,0 +1,176 @@
+package clickhouse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+const (
+	// tableNameColumn is the column with name of the table containing log record.
+	tableNameColumn = "_table"
+	// unionTableAlias is used for referencing columns of the table selected in union.
+	unionTableAlias = "src"
+)
+
+// indexSource describes logs tables read together by the index pattern.
+type indexSource struct {
+	tables []string
+	// model of columns of all tables, columns missing in some tables are filled by default values
+	union models.ModelInfo
+	// expressions selecting union columns from each table
+	columns       map[string][]string
+	sameStructure bool
+}
+
+// newIndexSource builds union model of tables. Columns with different types in some tables are converted
+// to strings, time columns with different types are converted to nanoseconds. Computed fields of tables
+// are calculated in union parts.
+func newIndexSource(index string, tables []string, schemes map[string]*models.ModelInfo) *indexSource {
+	source := &indexSource{
+		tables: tables,
+		union: models.ModelInfo{
+			DBName:     index,
+			DataFields: make(map[string]*models.FieldProps),
+		},
+		columns: make(map[string][]string, len(tables)),
+		// merge table function reads tables of single database
+		sameStructure: sameDatabase(tables),
+	}
+	source.union.JSONPaths = mergeJSONPaths(tables, schemes)
+
+	names := make([]string, 0)
+	for _, table := range tables {
+		for name, field := range schemes[table].DataFields {
+			if _, ok := source.union.DataFields[name]; ok {
+				continue
+			}
+			if name == tableNameColumn {
+				// table name is set for each union part
+				source.union.DataFields[name] = field
+				continue
+			}
+			source.union.DataFields[name] = nil
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, conflict := unionField(name, tables, schemes)
+		source.union.DataFields[name] = field
+		for _, table := range tables {
+			column, ok := schemes[table].DataFields[name]
+			source.columns[table] = append(source.columns[table], unionColumnExpression(field, column, ok, conflict))
+			if !ok || conflict || column.IsComputed() {
+				// computed fields aren't available in merge table function
+				source.sameStructure = false
+			}
+		}
+	}
+	return source
+}
+
+// unionField returns properties of the field in union model, true is returned if its types differ in some tables.
+func unionField(name string, tables []string, schemes map[string]*models.ModelInfo) (*models.FieldProps, bool) {
+	var field models.FieldProps
+	found, conflict, allTime := false, false, true
+	for _, table := range tables {
+		column, ok := schemes[table].DataFields[name]
+		if !ok {
+			continue
+		}
+		if !found {
+			field, found = *column, true
+		} else if column.CHType != field.CHType || column.TimeUnit != field.TimeUnit {
+			conflict = true
+		}
+		allTime = allTime && column.IsTime() && !column.IsArray() && !column.IsNullable()
+	}
+
+	// inverted index is not shared by tables, computed fields are columns of union
+	field.FullTextSearch = false
+	field.Expression = ""
+	if conflict && allTime {
+		field.CHType, field.TimeUnit = "UInt64", models.Nanoseconds
+	} else if conflict {
+		field.CHType, field.TimeUnit, field.IsTimestamp = "String", "", false
+	}
+	return &field, conflict
+}
+
+// unionColumnExpression returns expression selecting union field from the table column.
+func unionColumnExpression(field *models.FieldProps, column *models.FieldProps, exists bool, conflict bool) string {
+	if !exists {
+		return fmt.Sprintf("%s AS %s", field.DefaultValueExpression(), field.CHName)
+	}
+	qualified := column.CHField
+	qualified.CHName = unionTableAlias + "." + column.CHName
+	if column.IsComputed() {
+		qualified.CHName = "(" + column.Expression + ")"
+	}
+	switch {
+	case conflict && field.TimeUnit == models.Nanoseconds:
+		return fmt.Sprintf("toUInt64(%s) AS %s", qualified.TimeNanosExpression(), field.CHName)
+	case conflict && column.IsNullable():
+		return fmt.Sprintf("ifNull(toString(%s), '') AS %s", qualified.CHName, field.CHName)
+	case conflict:
+		return fmt.Sprintf("toString(%s) AS %s", qualified.CHName, field.CHName)
+	case column.IsComputed():
+		return fmt.Sprintf("%s AS %s", qualified.CHName, field.CHName)
+	}
+	return field.CHName
+}
+
+// from returns source of data for select requests.
+func (s *indexSource) from() string {
+	if s.sameStructure {
+		return mergeTables("", s.tables)
+	}
+	selects := make([]string, len(s.tables))
+	for i, table := range s.tables {
+		selects[i] = fmt.Sprintf(
+			"SELECT %s, %s AS %s FROM %s AS %s",
+			strings.Join(s.columns[table], ", "),
+			models.QuoteString(table),
+			tableNameColumn,
+			db.TableFullName(table),
+			unionTableAlias,
+		)
+	}
+	return "(" + strings.Join(selects, " UNION ALL ") + ")"
+}
+
+// what returns list of selected columns.
+func (s *indexSource) what() string {
+	if s.sameStructure {
+		return "*, " + tableNameColumn
+	}
+	return "*"
+}
+
+// mergeTables returns merge table function reading from all listed tables of the same database.
+func mergeTables(prefix string, tables []string) string {
+	database := db.DataBaseName
+	names := make([]string, len(tables))
+	for i := range tables {
+		database, names[i] = db.SplitTableName(tables[i])
+		names[i] = regexp.QuoteMeta(prefix + names[i])
+	}
+	regex := strings.Replace("^("+strings.Join(names, "|")+")$", `\`, `\\`, -1)
+	return fmt.Sprintf("merge(%s, '%s')", database, regex)
+}
+
+// sameDatabase checks that all tables belong to the same database.
+func sameDatabase(tables []string) bool {
+	databases := make(map[string]struct{})
+	for _, table := range tables {
+		database, _ := db.SplitTableName(table)
+		databases[database] = struct{}{}
+	}
+	return len(databases) <= 1
+}
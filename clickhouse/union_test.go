@@ -0,0 +1,158 @@
+package clickhouse
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+func unionTestScheme(table string, columns ...models.CHField) *models.ModelInfo {
+	info := models.CreateModelInfoFromColumns(table, columns)
+	return &info
+}
+
+func unionTestComputed(info *models.ModelInfo, name string, chType string, expression string) *models.ModelInfo {
+	info.DataFields[name] = &models.FieldProps{
+		CHField:    models.CHField{CHName: name, CHType: chType},
+		KibanaName: name,
+		Expression: expression,
+	}
+	return info
+}
+
+func TestIndexSource(t *testing.T) {
+	testData := []struct {
+		caseName string
+		schemes  map[string]*models.ModelInfo
+		from     string
+		what     string
+	}{
+		{
+			caseName: "tables with same structure",
+			schemes: map[string]*models.ModelInfo{
+				"logs_a": unionTestScheme("logs_a", models.CHField{CHName: "ts", CHType: "UInt64"}, models.CHField{CHName: "message", CHType: "String"}),
+				"logs_b": unionTestScheme("logs_b", models.CHField{CHName: "ts", CHType: "UInt64"}, models.CHField{CHName: "message", CHType: "String"}),
+			},
+			from: `merge(logs, '^(logs_a|logs_b)$')`,
+			what: "*, _table",
+		},
+		{
+			caseName: "missing and conflicting columns",
+			schemes: map[string]*models.ModelInfo{
+				"logs_a": unionTestScheme("logs_a", models.CHField{CHName: "ts", CHType: "UInt64"}, models.CHField{CHName: "status", CHType: "UInt16"}),
+				"logs_b": unionTestScheme("logs_b", models.CHField{CHName: "ts", CHType: "DateTime"}, models.CHField{CHName: "status", CHType: "Nullable(String)"}, models.CHField{CHName: "host", CHType: "String"}),
+			},
+			from: "(SELECT CAST('' AS String) AS host, toString(src.status) AS status, toUInt64(src.ts) AS ts, 'logs_a' AS _table FROM logs.logs_a AS src" +
+				" UNION ALL SELECT host, ifNull(toString(src.status), '') AS status, toUInt64((toUInt64(src.ts) * 1000000000)) AS ts, 'logs_b' AS _table FROM logs.logs_b AS src)",
+			what: "*",
+		},
+		{
+			caseName: "computed fields",
+			schemes: map[string]*models.ModelInfo{
+				"logs_a": unionTestComputed(unionTestScheme("logs_a", models.CHField{CHName: "url", CHType: "String"}), "domain", "String", "domain(url)"),
+				"logs_b": unionTestScheme("logs_b", models.CHField{CHName: "url", CHType: "String"}),
+			},
+			from: "(SELECT (domain(url)) AS domain, url, 'logs_a' AS _table FROM logs.logs_a AS src" +
+				" UNION ALL SELECT CAST('' AS String) AS domain, url, 'logs_b' AS _table FROM logs.logs_b AS src)",
+			what: "*",
+		},
+		{
+			caseName: "tables of several databases",
+			schemes: map[string]*models.ModelInfo{
+				"logs_a":       unionTestScheme("logs_a", models.CHField{CHName: "ts", CHType: "UInt64"}),
+				"nginx.logs_b": unionTestScheme("nginx.logs_b", models.CHField{CHName: "ts", CHType: "UInt64"}),
+			},
+			from: "(SELECT ts, 'logs_a' AS _table FROM logs.logs_a AS src UNION ALL SELECT ts, 'nginx.logs_b' AS _table FROM nginx.logs_b AS src)",
+			what: "*",
+		},
+	}
+
+	for _, test := range testData {
+		tables := make([]string, 0, len(test.schemes))
+		for table := range test.schemes {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		source := newIndexSource("logs_*", tables, test.schemes)
+		if from := source.from(); from != test.from {
+			t.Error("For", test.caseName, "\n expected: ", test.from, "\n got: ", from)
+		}
+		if what := source.what(); what != test.what {
+			t.Error("For", test.caseName, "\n expected: ", test.what, "\n got: ", what)
+		}
+	}
+}
+
+func TestMergeTables(t *testing.T) {
+	testData := []struct {
+		caseName string
+		tables   []string
+		result   string
+	}{
+		{
+			caseName: "tables with special characters",
+			tables:   []string{"logs_a+b", "logs_c"},
+			result:   `merge(logs, '^(histogram_logs_a\\+b|histogram_logs_c)$')`,
+		},
+		{
+			caseName: "tables of other database",
+			tables:   []string{"nginx.access_1", "nginx.access_2"},
+			result:   `merge(nginx, '^(histogram_access_1|histogram_access_2)$')`,
+		},
+	}
+
+	for _, test := range testData {
+		result := mergeTables(models.PreparedHistogramDataTablePrefix, test.tables)
+		if result != test.result {
+			t.Error("For", test.caseName, "\n expected: ", test.result, "\n got: ", result)
+		}
+	}
+}
+
+func TestRequestTplOfProviderSource(t *testing.T) {
+	schemes := map[string]*models.ModelInfo{
+		"logs_a": unionTestScheme("logs_a", models.CHField{CHName: "ts", CHType: "UInt64"}),
+		"logs_b": unionTestScheme("logs_b", models.CHField{CHName: "ts", CHType: "UInt64"}),
+	}
+	union := newProvider("logs_*", nil)
+	union.source = newIndexSource("logs_*", []string{"logs_a", "logs_b"}, schemes)
+	// provider created before the tables matched by the pattern are changed
+	stale := newProvider("logs_*", nil)
+	stale.source = newIndexSource("logs_*", []string{"logs_a"}, schemes)
+
+	testData := []struct {
+		caseName string
+		request  *db.Request
+		result   string
+	}{
+		{
+			caseName: "index pattern",
+			request:  NewRequestTpl(union),
+			result:   `SELECT *, _table FROM merge(logs, '^(logs_a|logs_b)$')`,
+		},
+		{
+			caseName: "prepared histogram data of index pattern",
+			request:  NewPreparedHistogramRequestTpl(union),
+			result:   `SELECT *, _table FROM merge(logs, '^(histogram_logs_a|histogram_logs_b)$')`,
+		},
+		{
+			caseName: "source is not shared by providers of the same index",
+			request:  NewRequestTpl(stale),
+			result:   `SELECT *, _table FROM merge(logs, '^(logs_a)$')`,
+		},
+		{
+			caseName: "single table",
+			request:  NewRequestTpl(newProvider("logs_a", nil)),
+			result:   `SELECT *, _table FROM merge(logs, '^logs_a')`,
+		},
+	}
+
+	for _, test := range testData {
+		if result := strings.Join(strings.Fields(test.request.Build()), " "); result != test.result {
+			t.Error("For", test.caseName, "\n expected: ", test.result, "\n got: ", result)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"kibouse/clickhouse"
+	"kibouse/config"
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+var dryRun bool
+
+func init() {
+	RootCmd.AddCommand(housekeepingCmd)
+
+	housekeepingCmd.PersistentFlags().BoolVar(&dryRun, "dry_run", false, "Only list expired partitions without dropping them")
+}
+
+// housekeepingCmd represents the clickhouse tables maintenance command.
+var housekeepingCmd = &cobra.Command{
+	Use:   "housekeeping",
+	Short: "Drop expired partitions",
+	Long:  "Drop partitions of logs, inverted index and histogram tables older than retention period and report tables disk usage",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		if err := models.RegisterModels(cfg.Models()); err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+
+		connection, err := clickhouse.CreateConnection(cfg.GetClickhouseSource())
+		if err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+		if err := db.InitLogsDbConnection(connection, db.DataBaseName); err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+		defer db.CloseLogsDbConnection()
+
+		for logsTable := range models.GetLogsTablesSchemas() {
+			retention := cfg.Retention(logsTable)
+			tables := map[string]uint{
+				logsTable: retention.Logs,
+				models.InvertedIndexTablePrefix + logsTable:         retention.InvertedIndex,
+				models.PreparedHistogramDataTablePrefix + logsTable: retention.Histogram,
+			}
+			for table, days := range tables {
+				if err := dropExpiredPartitions(table, days); err != nil {
+					log.Fatal(fmt.Sprintf("%+v", err))
+				}
+			}
+		}
+
+		usage, err := clickhouse.GetTablesDiskUsage()
+		if err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+		printDiskUsage(usage)
+	},
+}
+
+func dropExpiredPartitions(table string, retentionDays uint) error {
+	if retentionDays == 0 {
+		return nil
+	}
+	if exists, err := db.TableExists(table); err != nil || !exists {
+		return err
+	}
+
+	partitions, err := clickhouse.DropExpiredPartitions(table, retentionDays, dryRun)
+	action := "dropped"
+	if dryRun {
+		action = "expired"
+	}
+	for _, partition := range partitions {
+		fmt.Printf("%s: partition %s %s\n", table, partition, action)
+	}
+	return err
+}
+
+func printDiskUsage(usage []clickhouse.TableDiskUsage) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "table\tpartitions\tparts\trows\tsize\t")
+	for _, table := range usage {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t\n", table.Table, table.Partitions, table.Parts, table.Rows, formatBytes(table.Bytes))
+	}
+	w.Flush()
+}
+
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"kibouse/clickhouse"
+	"kibouse/config"
+	"kibouse/data/models"
+	"kibouse/db"
+)
+
+var planOnly bool
+
+func init() {
+	RootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.PersistentFlags().BoolVar(&planOnly, "dry_run", false, "Only print migration plan without applying it")
+}
+
+// migrateCmd represents the logs tables schema migration command.
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate logs tables",
+	Long:  "Alter existing logs tables according to their models and recreate kafka delivery queues, migration plan is printed before applying",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		if err := models.RegisterModels(cfg.Models()); err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+
+		connection, err := clickhouse.CreateConnection(cfg.GetClickhouseSource())
+		if err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+		if err := db.InitLogsDbConnection(connection, db.DataBaseName); err != nil {
+			log.Fatal(fmt.Sprintf("%+v", err))
+		}
+		defer db.CloseLogsDbConnection()
+
+		plans := make([]*clickhouse.MigrationPlan, 0)
+		for table, dataStruct := range models.GetLogsTablesSchemas() {
+			if exists, err := db.TableExists(table); err != nil || !exists {
+				if err != nil {
+					log.Fatal(fmt.Sprintf("%+v", err))
+				}
+				fmt.Printf("%s: table not exists, skipped\n", table)
+				continue
+			}
+			plan, err := clickhouse.PlanMigration(table, table, dataStruct, cfg.GetKafkaSource())
+			if err != nil {
+				log.Fatal(fmt.Sprintf("%+v", err))
+			}
+			printMigrationPlan(plan)
+			if !plan.Empty() {
+				plans = append(plans, plan)
+			}
+		}
+
+		if planOnly {
+			return
+		}
+		for _, plan := range plans {
+			if err := plan.Apply(); err != nil {
+				log.Fatal(fmt.Sprintf("%+v", err))
+			}
+			fmt.Printf("%s: migrated\n", plan.Table)
+		}
+	},
+}
+
+func printMigrationPlan(plan *clickhouse.MigrationPlan) {
+	for _, column := range plan.UnknownColumns {
+		fmt.Printf("%s: column %s is absent in model, it should be dropped manually\n", plan.Table, column)
+	}
+	if plan.Empty() {
+		fmt.Printf("%s: up to date\n", plan.Table)
+		return
+	}
+	fmt.Printf("%s: migration plan\n", plan.Table)
+	for _, query := range plan.Queries {
+		fmt.Printf("  %s;\n", strings.TrimSuffix(query, ";"))
+	}
+}
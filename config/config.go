@@ -3,9 +3,17 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+
+	"kibouse/adapter/settings"
+	"kibouse/data/models"
+	"kibouse/db"
 )
 
 type sources struct {
@@ -21,15 +29,84 @@ type logging struct {
 	httpTransLogFile string
 }
 
+// IndexedTable contains settings of single logs table processed by indexer.
+type IndexedTable struct {
+	Table           string `mapstructure:"table"`
+	LogsTopic       string `mapstructure:"logs_topic"`
+	IndexTopic      string `mapstructure:"index_topic"`
+	Analyzer        string `mapstructure:"analyzer"`
+	TimestampField  string `mapstructure:"timestamp_field"`
+	TimestampFormat string `mapstructure:"timestamp_format"`
+	DeadLetterTopic string `mapstructure:"dead_letter_topic"`
+}
+
+// openSearch contains settings of OpenSearch Dashboards compatibility profile.
+type openSearch struct {
+	version   string
+	responses map[string]string
+}
+
+type indexer struct {
+	tables          []IndexedTable
+	statsInterval   time.Duration
+	statsPort       string
+	maxRetries      int
+	retryBackoff    time.Duration
+	shutdownTimeout time.Duration
+}
+
+// SkipIndexParams contains parameters of clickhouse data skipping indexes used as full text search backend.
+type SkipIndexParams struct {
+	BloomFilterSize uint
+	HashFunctions   uint
+	Seed            uint
+	NgramSize       uint
+	Granularity     uint
+}
+
+// Retention contains number of days the logs table data is kept, zero value disables data expiration.
+type Retention struct {
+	Logs          uint `mapstructure:"logs"`
+	InvertedIndex uint `mapstructure:"inverted_index"`
+	Histogram     uint `mapstructure:"histogram"`
+}
+
+type retention struct {
+	defaults Retention
+	tables   map[string]Retention
+}
+
+// search contains limits of paging through search hits and caching of their totals.
+type search struct {
+	maxResultWindow    int
+	maxScrollKeepAlive time.Duration
+	totalHitsCacheTTL  time.Duration
+}
+
+type fullTextSearch struct {
+	backend   string
+	tables    map[string]string
+	skipIndex SkipIndexParams
+}
+
 // AppConfig contains application settings
 type AppConfig struct {
 	listeningPort string
 	resetRequired bool
 	responses     map[string]string
 	kibanaVer     string
+	kibanaMajor   int
 	createChTables bool
+	migrateChTables bool
 	sources       *sources
 	logging       *logging
+	indexer       *indexer
+	openSearch    *openSearch
+	fullTextSearch *fullTextSearch
+	search         *search
+	retention      *retention
+	models         []models.ModelDefinition
+	databases      []db.TablesSource
 }
 
 const (
@@ -40,6 +117,9 @@ const (
 
 	StaticResponsesFile = "static_responses.json"
 	KibanaVersion       = "5.6.8"
+
+	// OpenSearch Dashboards static responses are read from static_responses_opensearch.json
+	OpenSearchStaticResponsesSuffix = "opensearch"
 )
 
 // Load reads settings from file to AppConfig structure
@@ -56,7 +136,9 @@ func Load(path string) (*AppConfig, error) {
 	viper.SetDefault("app.reset", true)
 	viper.SetDefault("app.static_responses", StaticResponsesFile)
 	viper.SetDefault("app.kibana_ver", KibanaVersion)
+	viper.SetDefault("app.opensearch_dashboards.version", "")
 	viper.SetDefault("app.create_ch_tables", false)
+	viper.SetDefault("app.migrate_ch_tables", false)
 
 	viper.SetDefault("app.sources.clickhouse", "tcp://127.0.0.1:9000")
 	viper.SetDefault("app.sources.elasticsearch", "http://localhost:9200")
@@ -67,21 +149,88 @@ func Load(path string) (*AppConfig, error) {
 	viper.SetDefault("app.logging.proxy_to_elastic", false)
 	viper.SetDefault("app.logging.log_requests_file", HttpTransactionsLogFile)
 
+	viper.SetDefault("app.indexer.stats_interval", "1m")
+	viper.SetDefault("app.indexer.stats_port", "")
+	viper.SetDefault("app.indexer.max_retries", 5)
+	viper.SetDefault("app.indexer.retry_backoff", "1s")
+	viper.SetDefault("app.indexer.shutdown_timeout", "30s")
+
+	viper.SetDefault("app.search.max_result_window", 10000)
+	viper.SetDefault("app.search.max_scroll_keep_alive", "24h")
+	viper.SetDefault("app.search.total_hits_cache_ttl", "10s")
+
+	viper.SetDefault("app.full_text_search.backend", "inverted_index")
+	viper.SetDefault("app.full_text_search.skip_index.bloom_filter_size", 32768)
+	viper.SetDefault("app.full_text_search.skip_index.hash_functions", 3)
+	viper.SetDefault("app.full_text_search.skip_index.seed", 0)
+	viper.SetDefault("app.full_text_search.skip_index.ngram_size", 4)
+	viper.SetDefault("app.full_text_search.skip_index.granularity", 4)
+
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, errors.New("cannot parse config file - " + err.Error())
 	}
 
-	staticResponses, err := readStaticRespones(viper.GetString("app.static_responses"))
+	indexedTables := make([]IndexedTable, 0)
+	if err := viper.UnmarshalKey("app.indexer.tables", &indexedTables); err != nil {
+		return nil, errors.Wrap(err, "cannot parse list of indexed tables")
+	}
+
+	retentionDefaults := Retention{}
+	if err := viper.UnmarshalKey("app.retention.default", &retentionDefaults); err != nil {
+		return nil, errors.Wrap(err, "cannot parse default retention settings")
+	}
+	tablesRetention := make(map[string]Retention)
+	if err := viper.UnmarshalKey("app.retention.tables", &tablesRetention); err != nil {
+		return nil, errors.Wrap(err, "cannot parse tables retention settings")
+	}
+
+	modelDefinitions := make([]models.ModelDefinition, 0)
+	if err := viper.UnmarshalKey("app.models", &modelDefinitions); err != nil {
+		return nil, errors.Wrap(err, "cannot parse models declarations")
+	}
+	for _, path := range viper.GetStringSlice("app.models_files") {
+		declared, err := readModelsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		modelDefinitions = append(modelDefinitions, declared...)
+	}
+
+	databases := make([]db.TablesSource, 0)
+	if err := viper.UnmarshalKey("app.databases", &databases); err != nil {
+		return nil, errors.Wrap(err, "cannot parse list of logs databases")
+	}
+
+	kibanaMajor, err := settings.ParseMajorVersion(viper.GetString("app.kibana_ver"))
 	if err != nil {
 		return nil, err
 	}
 
+	staticResponses, err := readVersionStaticResponses(viper.GetString("app.static_responses"), strconv.Itoa(kibanaMajor))
+	if err != nil {
+		return nil, err
+	}
+
+	openSearchVer := viper.GetString("app.opensearch_dashboards.version")
+	var openSearchResponses map[string]string
+	if openSearchVer != "" {
+		if _, err := settings.ParseOpenSearchMajorVersion(openSearchVer); err != nil {
+			return nil, err
+		}
+		openSearchResponses, err = readVersionStaticResponses(viper.GetString("app.static_responses"), OpenSearchStaticResponsesSuffix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	config := &AppConfig{
 		listeningPort: viper.GetString("app.listening_port"),
 		resetRequired: viper.GetBool("app.reset"),
 		responses:     staticResponses,
 		kibanaVer:     viper.GetString("app.kibana_ver"),
+		kibanaMajor:   kibanaMajor,
 		createChTables: viper.GetBool("app.create_ch_tables"),
+		migrateChTables: viper.GetBool("app.migrate_ch_tables"),
 
 		sources: &sources{
 			clickhouse:    viper.GetString("app.sources.clickhouse"),
@@ -94,6 +243,40 @@ func Load(path string) (*AppConfig, error) {
 			elasticOnly:      viper.GetBool("app.logging.proxy_to_elastic"),
 			httpTransLogFile: viper.GetString("app.logging.log_requests_file"),
 		},
+		indexer: &indexer{
+			tables:          indexedTables,
+			statsInterval:   viper.GetDuration("app.indexer.stats_interval"),
+			statsPort:       viper.GetString("app.indexer.stats_port"),
+			maxRetries:      viper.GetInt("app.indexer.max_retries"),
+			retryBackoff:    viper.GetDuration("app.indexer.retry_backoff"),
+			shutdownTimeout: viper.GetDuration("app.indexer.shutdown_timeout"),
+		},
+		fullTextSearch: &fullTextSearch{
+			backend: viper.GetString("app.full_text_search.backend"),
+			tables:  viper.GetStringMapString("app.full_text_search.tables"),
+			skipIndex: SkipIndexParams{
+				BloomFilterSize: uint(viper.GetInt("app.full_text_search.skip_index.bloom_filter_size")),
+				HashFunctions:   uint(viper.GetInt("app.full_text_search.skip_index.hash_functions")),
+				Seed:            uint(viper.GetInt("app.full_text_search.skip_index.seed")),
+				NgramSize:       uint(viper.GetInt("app.full_text_search.skip_index.ngram_size")),
+				Granularity:     uint(viper.GetInt("app.full_text_search.skip_index.granularity")),
+			},
+		},
+		search: &search{
+			maxResultWindow:    viper.GetInt("app.search.max_result_window"),
+			maxScrollKeepAlive: viper.GetDuration("app.search.max_scroll_keep_alive"),
+			totalHitsCacheTTL:  viper.GetDuration("app.search.total_hits_cache_ttl"),
+		},
+		retention: &retention{
+			defaults: retentionDefaults,
+			tables:   tablesRetention,
+		},
+		models:    modelDefinitions,
+		databases: databases,
+		openSearch: &openSearch{
+			version:   openSearchVer,
+			responses: openSearchResponses,
+		},
 	}
 
 	return config, nil
@@ -144,10 +327,113 @@ func (cfg *AppConfig) KibanaVersion() string {
 	return cfg.kibanaVer
 }
 
+// OpenSearchEnabled checks that OpenSearch Dashboards could be served along with kibana.
+func (cfg *AppConfig) OpenSearchEnabled() bool {
+	return cfg.openSearch != nil && cfg.openSearch.version != ""
+}
+
+// OpenSearchVersion returns version of served OpenSearch Dashboards.
+func (cfg *AppConfig) OpenSearchVersion() string {
+	return cfg.openSearch.version
+}
+
+// OpenSearchStaticResponse returns predefined response to OpenSearch Dashboards static request.
+func (cfg *AppConfig) OpenSearchStaticResponse(url string) (string, bool) {
+	response, ok := cfg.openSearch.responses[url]
+	return response, ok
+}
+
+// KibanaMajorVersion returns major part of kibana version, it selects format of requests and responses.
+func (cfg *AppConfig) KibanaMajorVersion() int {
+	return cfg.kibanaMajor
+}
+
 func (cfg *AppConfig) CreateChTables() bool {
 	return cfg.createChTables
 }
 
+// MigrateChTables checks that existing logs tables should be altered according to their models at the startup.
+func (cfg *AppConfig) MigrateChTables() bool {
+	return cfg.migrateChTables
+}
+
+// FullTextSearchBackend returns full text search backend of the logs table, table specific backend
+// overrides the default one.
+func (cfg *AppConfig) FullTextSearchBackend(table string) string {
+	if backend, ok := cfg.fullTextSearch.tables[strings.ToLower(table)]; ok {
+		return backend
+	}
+	return cfg.fullTextSearch.backend
+}
+
+// FullTextSearchSkipIndex returns parameters of data skipping indexes used for full text search.
+func (cfg *AppConfig) FullTextSearchSkipIndex() SkipIndexParams {
+	return cfg.fullTextSearch.skipIndex
+}
+
+// Retention returns data retention settings of the logs table, table specific settings override default ones.
+func (cfg *AppConfig) Retention(table string) Retention {
+	if tableRetention, ok := cfg.retention.tables[strings.ToLower(table)]; ok {
+		return tableRetention
+	}
+	return cfg.retention.defaults
+}
+
+// Models returns logs tables models declared in configuration.
+func (cfg *AppConfig) Models() []models.ModelDefinition {
+	return cfg.models
+}
+
+// Databases returns clickhouse databases with logs tables available in kibana besides kibouse database.
+func (cfg *AppConfig) Databases() []db.TablesSource {
+	return cfg.databases
+}
+
+// IndexedTables returns settings of all logs tables processed by indexer.
+func (cfg *AppConfig) IndexedTables() []IndexedTable {
+	return cfg.indexer.tables
+}
+
+// IndexerStatsInterval returns period of indexer statistics reporting.
+func (cfg *AppConfig) IndexerStatsInterval() time.Duration {
+	return cfg.indexer.statsInterval
+}
+
+// IndexerStatsPort returns port for exposing indexer statistics over http, empty value disables it.
+func (cfg *AppConfig) IndexerStatsPort() string {
+	return cfg.indexer.statsPort
+}
+
+// IndexerMaxRetries returns max number of attempts to redeliver inverted index records or reconnect consumer.
+func (cfg *AppConfig) IndexerMaxRetries() int {
+	return cfg.indexer.maxRetries
+}
+
+// IndexerRetryBackoff returns initial delay between retries, it is doubled with every next attempt.
+func (cfg *AppConfig) IndexerRetryBackoff() time.Duration {
+	return cfg.indexer.retryBackoff
+}
+
+// IndexerShutdownTimeout returns max time for flushing produced records on shutdown or rebalancing.
+func (cfg *AppConfig) IndexerShutdownTimeout() time.Duration {
+	return cfg.indexer.shutdownTimeout
+}
+
+// MaxResultWindow returns max value of from + size of search requests.
+func (cfg *AppConfig) MaxResultWindow() int {
+	return cfg.search.maxResultWindow
+}
+
+// MaxScrollKeepAlive returns max time scroll context is kept between requests of its pages.
+func (cfg *AppConfig) MaxScrollKeepAlive() time.Duration {
+	return cfg.search.maxScrollKeepAlive
+}
+
+// TotalHitsCacheTTL returns time number of documents matching the same search query is cached for.
+func (cfg *AppConfig) TotalHitsCacheTTL() time.Duration {
+	return cfg.search.totalHitsCacheTTL
+}
+
 func readStaticRespones(path string) (map[string]string, error) {
 	staticResponses := make(map[string]string)
 
@@ -163,3 +449,44 @@ func readStaticRespones(path string) (map[string]string, error) {
 
 	return staticResponses, nil
 }
+
+// readVersionStaticResponses reads common static responses and responses specific for UI version
+// from the file with version suffix, e.g. static_responses_7.json, if it exists.
+func readVersionStaticResponses(path string, suffix string) (map[string]string, error) {
+	staticResponses, err := readStaticRespones(path)
+	if err != nil {
+		return nil, err
+	}
+
+	versionPath := versionStaticResponsesFile(path, suffix)
+	if _, err := os.Stat(versionPath); os.IsNotExist(err) {
+		return staticResponses, nil
+	}
+	versionResponses, err := readStaticRespones(versionPath)
+	if err != nil {
+		return nil, err
+	}
+	for url, response := range versionResponses {
+		staticResponses[url] = response
+	}
+	return staticResponses, nil
+}
+
+func versionStaticResponsesFile(path string, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_" + suffix + ext
+}
+
+// readModelsFile reads models declarations from yaml or json file with the "models" list.
+func readModelsFile(path string) ([]models.ModelDefinition, error) {
+	reader := viper.New()
+	reader.SetConfigFile(path)
+	if err := reader.ReadInConfig(); err != nil {
+		return nil, errors.Wrap(err, "cannot read models declarations from "+path)
+	}
+	declared := make([]models.ModelDefinition, 0)
+	if err := reader.UnmarshalKey("models", &declared); err != nil {
+		return nil, errors.Wrap(err, "cannot parse models declarations from "+path)
+	}
+	return declared, nil
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ColumnDefinition describes logs table column declared in configuration, its attributes have the same meaning
+// as the corresponding tags of compiled-in models.
+type ColumnDefinition struct {
+	Name         string  `mapstructure:"name"`
+	Type         string  `mapstructure:"type"`
+	KibanaName   string  `mapstructure:"kibana_name"`
+	UUID         bool    `mapstructure:"uuid"`
+	Timestamp    bool    `mapstructure:"timestamp"`
+	TimeUnit     string  `mapstructure:"time_unit"`
+	InvIndex     bool    `mapstructure:"inv_index"`
+	Partitioning bool    `mapstructure:"partitioning"`
+	CHIndexPos   int     `mapstructure:"ch_index_pos"`
+	Default      *string `mapstructure:"default"`
+	MVTransform  string  `mapstructure:"mv_transform"`
+	BaseType     string  `mapstructure:"base_type"`
+	SourcePath   string  `mapstructure:"src_path"`
+	Expression   string  `mapstructure:"expression"`
+	JSONBlob     bool    `mapstructure:"json_blob"`
+}
+
+// ModelDefinition describes logs table model declared in configuration.
+type ModelDefinition struct {
+	Table   string             `mapstructure:"table"`
+	Columns []ColumnDefinition `mapstructure:"columns"`
+}
+
+// RegisterModels adds declared models to the list of logs tables models.
+func RegisterModels(definitions []ModelDefinition) error {
+	for _, definition := range definitions {
+		if _, exists := models[definition.Table]; exists {
+			return errors.New("model is already registered for table " + definition.Table)
+		}
+		model, err := CreateModelType(definition)
+		if err != nil {
+			return err
+		}
+		models[definition.Table] = model
+	}
+	return nil
+}
+
+// CreateModelType builds structure type with the same tags as compiled-in models have,
+// so declared models are processed by the same schema builders.
+func CreateModelType(definition ModelDefinition) (model reflect.Type, err error) {
+	if !strings.HasPrefix(definition.Table, LogsTablePrefix) {
+		return nil, errors.New("logs table name should start with " + LogsTablePrefix + ": " + definition.Table)
+	}
+	if len(definition.Columns) == 0 {
+		return nil, errors.New("columns are not declared for table " + definition.Table)
+	}
+
+	fields := make([]reflect.StructField, 0, len(definition.Columns))
+	fieldNames := make(map[string]struct{}, len(definition.Columns))
+	columnNames := make(map[string]struct{}, len(definition.Columns))
+	timestamps := 0
+
+	for i, column := range definition.Columns {
+		if column.Name == "" || column.Type == "" {
+			return nil, errors.Errorf("name and type are required for column %d of table %s", i, definition.Table)
+		}
+		if _, duplicated := columnNames[column.Name]; duplicated {
+			return nil, errors.Errorf("column %s of table %s is declared twice", column.Name, definition.Table)
+		}
+		columnNames[column.Name] = struct{}{}
+		if column.Expression != "" && (column.Timestamp || column.UUID || column.InvIndex || column.Partitioning ||
+			column.CHIndexPos > 0 || column.Default != nil || column.MVTransform != "" || column.SourcePath != "") {
+			return nil, errors.Errorf("computed column %s of table %s cannot be stored or indexed", column.Name, definition.Table)
+		}
+		if column.Timestamp {
+			timestamps++
+		}
+
+		name := GoFieldName(column.Name)
+		if _, duplicated := fieldNames[name]; duplicated {
+			name += strconv.Itoa(i)
+		}
+		fieldNames[name] = struct{}{}
+
+		fields = append(fields, reflect.StructField{
+			Name: name,
+			Type: GoType(column.Type),
+			Tag:  column.tag(),
+		})
+	}
+
+	if timestamps > 1 {
+		return nil, errors.New("more than one timestamp column declared for table " + definition.Table)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			model = nil
+			err = errors.Errorf("cannot create model for table %s: %v", definition.Table, r)
+		}
+	}()
+	return reflect.StructOf(fields), nil
+}
+
+func (column ColumnDefinition) tag() reflect.StructTag {
+	kibanaName := column.KibanaName
+	if kibanaName == "" {
+		kibanaName = column.Name
+	}
+
+	tags := []string{
+		"db:" + strconv.Quote(column.Name),
+		"json:" + strconv.Quote(kibanaName),
+		"type:" + strconv.Quote(column.Type),
+	}
+	if column.UUID {
+		tags = append(tags, `uuid:"true"`)
+	}
+	if column.Timestamp && column.TimeUnit != "" {
+		tags = append(tags, "timestamp:"+strconv.Quote(column.TimeUnit))
+	} else if column.Timestamp {
+		tags = append(tags, `timestamp:"true"`)
+	}
+	if column.InvIndex {
+		tags = append(tags, `inv_index:"true"`)
+	}
+	if column.Partitioning {
+		tags = append(tags, `partitioning:"true"`)
+	}
+	if column.CHIndexPos > 0 {
+		tags = append(tags, "ch_index_pos:"+strconv.Quote(strconv.Itoa(column.CHIndexPos)))
+	}
+	if column.Default != nil {
+		tags = append(tags, "default:"+strconv.Quote(*column.Default))
+	}
+	if column.MVTransform != "" {
+		tags = append(tags, "mv_transform:"+strconv.Quote(column.MVTransform))
+	}
+	if column.BaseType != "" {
+		tags = append(tags, "base_type:"+strconv.Quote(column.BaseType))
+	}
+	if column.SourcePath != "" {
+		tags = append(tags, "src_path:"+strconv.Quote(column.SourcePath))
+	}
+	if column.JSONBlob {
+		tags = append(tags, `json_blob:"true"`)
+	}
+	if column.Expression != "" {
+		// computed columns are not created in table
+		tags = append(tags, "expr:"+strconv.Quote(column.Expression), `skip:"db"`)
+	}
+	return reflect.StructTag(strings.Join(tags, " "))
+}
+
+// GoFieldName converts column name to exported go identifier, e.g. remote_ip -> RemoteIp.
+func GoFieldName(column string) string {
+	name := strings.Builder{}
+	upper := true
+	for _, r := range column {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		name.WriteRune(r)
+	}
+	if name.Len() == 0 || !unicode.IsLetter([]rune(name.String())[0]) {
+		return "F" + name.String()
+	}
+	return name.String()
+}
+
+// GoType returns go type corresponding to clickhouse column type, unknown types are represented by string.
+// Nullable values are represented by Null* types (except IP addresses, which are nil if null).
+func GoType(chType string) reflect.Type {
+	switch typeName(chType) {
+	case "Array":
+		if params := typeParams(chType); len(params) == 1 {
+			return reflect.SliceOf(GoType(params[0]))
+		}
+	case "Map":
+		if params := typeParams(chType); len(params) == 2 {
+			return reflect.MapOf(GoType(params[0]), GoType(params[1]))
+		}
+	case "LowCardinality":
+		if params := typeParams(chType); len(params) == 1 {
+			return GoType(params[0])
+		}
+	case "Nullable":
+		if params := typeParams(chType); len(params) == 1 {
+			return nullableGoType(params[0])
+		}
+	}
+
+	switch typeName(chType) {
+	case "UInt8":
+		return reflect.TypeOf(uint8(0))
+	case "UInt16":
+		return reflect.TypeOf(uint16(0))
+	case "UInt32":
+		return reflect.TypeOf(uint32(0))
+	case "UInt64":
+		return reflect.TypeOf(uint64(0))
+	case "Int8":
+		return reflect.TypeOf(int8(0))
+	case "Int16":
+		return reflect.TypeOf(int16(0))
+	case "Int32":
+		return reflect.TypeOf(int32(0))
+	case "Int64":
+		return reflect.TypeOf(int64(0))
+	case "Float32":
+		return reflect.TypeOf(float32(0))
+	case "Float64", "Decimal", "Decimal32", "Decimal64", "Decimal128":
+		return reflect.TypeOf(float64(0))
+	case "Date", "DateTime", "DateTime64":
+		return reflect.TypeOf(time.Time{})
+	case "IPv4", "IPv6":
+		return reflect.TypeOf(net.IP{})
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+func nullableGoType(chType string) reflect.Type {
+	switch t := GoType(chType); t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.TypeOf(NullInt64{})
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.TypeOf(NullUint64{})
+	case reflect.Float32, reflect.Float64:
+		return reflect.TypeOf(NullFloat64{})
+	case reflect.String:
+		return reflect.TypeOf(NullString{})
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return reflect.TypeOf(NullTime{})
+		}
+		return t
+	default:
+		return t
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of values found by paths of JSON payloads, they define JSONExtract* function used for reading values.
+const (
+	JSONString = "String"
+	JSONInt    = "Int64"
+	JSONFloat  = "Float64"
+	// objects, arrays, booleans and values of different kinds are read as raw JSON text
+	JSONRaw = "Raw"
+)
+
+// IsJSONObject checks that field is of clickhouse JSON (Object('json')) type.
+func (f CHField) IsJSONObject() bool {
+	switch typeName(unwrapType(f.CHType)) {
+	case "JSON", "Object":
+		return true
+	}
+	return false
+}
+
+// IsJSONBlob checks that field contains JSON payloads, every payload path is exposed as separate sub-field.
+func (f FieldProps) IsJSONBlob() bool {
+	return (f.JSONBlob && f.IsText() && !f.IsArray()) || f.IsJSONObject()
+}
+
+// JSONSource returns clickhouse expression with JSON text of the field.
+func (f CHField) JSONSource() string {
+	if f.IsJSONObject() {
+		return fmt.Sprintf("toJSONString(%s)", f.CHName)
+	}
+	return f.CHName
+}
+
+// JSONSubField returns field for the single path of JSON payload, e.g. "user.id",
+// its name is the clickhouse expression extracting value of the path.
+func (f CHField) JSONSubField(path string, kind string) CHField {
+	args := f.JSONSource() + ", " + JSONPathArgs(path)
+	switch kind {
+	case JSONInt:
+		return CHField{CHName: fmt.Sprintf("JSONExtractInt(%s)", args), CHType: "Int64"}
+	case JSONFloat:
+		return CHField{CHName: fmt.Sprintf("JSONExtractFloat(%s)", args), CHType: "Float64"}
+	case JSONRaw:
+		return CHField{CHName: fmt.Sprintf("JSONExtractRaw(%s)", args), CHType: "String"}
+	}
+	return CHField{CHName: fmt.Sprintf("JSONExtractString(%s)", args), CHType: "String"}
+}
+
+// JSONPathArgs converts dotted path to the list of JSONExtract* keys arguments.
+func JSONPathArgs(path string) string {
+	keys := strings.Split(path, ".")
+	for i := range keys {
+		keys[i] = QuoteString(keys[i])
+	}
+	return strings.Join(keys, ", ")
+}
@@ -4,6 +4,14 @@ import "time"
 
 const SettingsTableName = "kibana"
 
+// OpenSearchSettingsTableName is the table with saved objects of OpenSearch Dashboards (.opensearch_dashboards index).
+const OpenSearchSettingsTableName = "opensearch_dashboards"
+
+// IsSettingsTable checks that table contains saved objects of kibana or OpenSearch Dashboards.
+func IsSettingsTable(table string) bool {
+	return table == SettingsTableName || table == OpenSearchSettingsTableName
+}
+
 type ClickhouseSettings struct {
 	Table                  string    `db:"_table" type:"String" json:"_table" skip:"db"`
 	ID                     string    `db:"_id" type:"String" ch_index_pos:"1"`
@@ -6,7 +6,16 @@ import (
 	"strings"
 )
 
-const timeStampType = "Timestamp"
+const (
+	// LogsTablePrefix is the common prefix of all tables with logs.
+	LogsTablePrefix = "logs_"
+
+	// column treated as logs id in models discovered at runtime.
+	dynamicUUIDColumn = "uuid"
+)
+
+// columns treated as logs timestamp in models discovered at runtime (in order of preference).
+var dynamicTimestampColumns = []string{"ts", "timestamp"}
 
 var models = map[string]reflect.Type{}
 
@@ -19,29 +28,39 @@ func GetLogsTablesSchemas() map[string]reflect.Type {
 type CHField struct {
 	CHName string
 	CHType string
+	// unit of time values stored in integer column (timestamp only)
+	TimeUnit string
 }
 
 func (f CHField) IsArray() bool {
 	return strings.HasPrefix(f.CHType, "Array")
 }
 
+// GetBaseChType returns type of field values (array elements) without Nullable and LowCardinality wrappers.
 func (f CHField) GetBaseChType() string {
-	return strings.Trim(strings.TrimPrefix(f.CHType, "Array"), "()")
+	chType := f.CHType
+	if f.IsArray() {
+		if params := typeParams(chType); len(params) == 1 {
+			chType = params[0]
+		}
+	}
+	return unwrapType(chType)
 }
 
 func (f CHField) IsString() bool {
-	fieldType := f.GetBaseChType()
+	fieldType := typeName(f.GetBaseChType())
 	switch fieldType {
-	case "String":
+	case "String", "FixedString", "Enum8", "Enum16", "UUID", "IPv4", "IPv6":
 		return true
 	}
 	return false
 }
 
 func (f CHField) IsNumeric() bool {
-	fieldType := f.GetBaseChType()
+	fieldType := typeName(f.GetBaseChType())
 	switch fieldType {
-	case "UInt8", "UInt16", "UInt32", "UInt64", "Int8", "Int16", "Int32", "Int64", "Float32", "Float64", "Decimal":
+	case "UInt8", "UInt16", "UInt32", "UInt64", "Int8", "Int16", "Int32", "Int64", "Float32", "Float64",
+		"Decimal", "Decimal32", "Decimal64", "Decimal128":
 		return true
 	}
 	return false
@@ -52,20 +71,49 @@ type FieldProps struct {
 	CHField
 	SourceCodeName string
 	KibanaName     string
+	SourcePath     string
 	IsUUID         bool
+	IsTimestamp    bool
 	FullTextSearch bool
+	// column and key of Map sub-field
+	MapColumn string
+	MapKey    string
+	// String column contains JSON payloads
+	JSONBlob bool
+	// column and path of JSON payload sub-field
+	JSONColumn string
+	JSONPath   string
+	// clickhouse expression of computed field, computed fields are not stored in table
+	Expression string
+}
+
+// IsComputed checks that field values are calculated by expression when data is selected.
+func (f FieldProps) IsComputed() bool {
+	return f.Expression != ""
+}
+
+// HasComputedFields checks that model contains fields calculated by expressions.
+func (mi ModelInfo) HasComputedFields() bool {
+	for _, field := range mi.DataFields {
+		if field.IsComputed() {
+			return true
+		}
+	}
+	return false
 }
 
 // ModelInfo contains logs storage information.
 type ModelInfo struct {
 	DBName     string
 	DataFields map[string]*FieldProps
+	// paths of JSON payloads sampled from table data (column -> path -> kind of values)
+	JSONPaths map[string]map[string]string
 }
 
 // GetTimestampField returns properties of model timestamp attribute.
 func (mi ModelInfo) GetTimestampField() (*FieldProps, bool) {
 	for i := range mi.DataFields {
-		if mi.DataFields[i].CHType == timeStampType {
+		if mi.DataFields[i].IsTimestamp {
 			return mi.DataFields[i], true
 		}
 	}
@@ -82,6 +130,44 @@ func (mi ModelInfo) GetUuidField() (*FieldProps, bool) {
 	return nil, false
 }
 
+// GetField returns properties of model attribute by its name, keys of Map columns are available
+// as dotted sub-fields, e.g. "labels.host" is resolved as labels['host'], paths of JSON payloads
+// are resolved as JSONExtract* expressions, e.g. "payload.user.id" - JSONExtractInt(payload, 'user', 'id').
+func (mi ModelInfo) GetField(name string) (*FieldProps, bool) {
+	if field, ok := mi.DataFields[name]; ok {
+		return field, true
+	}
+	for pos := strings.Index(name, "."); pos != -1; pos = nextDot(name, pos) {
+		column, ok := mi.DataFields[name[:pos]]
+		switch {
+		case ok && column.IsMap():
+			return &FieldProps{
+				CHField:    column.MapSubField(name[pos+1:]),
+				KibanaName: name,
+				MapColumn:  column.CHName,
+				MapKey:     name[pos+1:],
+			}, true
+		case ok && column.IsJSONBlob():
+			// values of paths not found in data sample are read as strings
+			path := name[pos+1:]
+			return &FieldProps{
+				CHField:    column.JSONSubField(path, mi.JSONPaths[column.CHName][path]),
+				KibanaName: name,
+				JSONColumn: column.JSONSource(),
+				JSONPath:   path,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+func nextDot(name string, pos int) int {
+	if next := strings.Index(name[pos+1:], "."); next != -1 {
+		return pos + 1 + next
+	}
+	return -1
+}
+
 // ClickhouseAttrCodeName converts clickhouse attribute name to its corresponding source code variable name.
 func (mi ModelInfo) ClickhouseAttrCodeName(attr string) (string, bool) {
 	if info, ok := mi.DataFields[attr]; ok {
@@ -90,6 +176,39 @@ func (mi ModelInfo) ClickhouseAttrCodeName(attr string) (string, bool) {
 	return "", false
 }
 
+// CreateModelInfoFromColumns builds logs table model from the list of its columns, it is used for tables
+// without compiled-in models. UInt64 column "uuid" is considered as id, "ts" or "timestamp" column
+// of UInt64 (nanoseconds), DateTime or DateTime64 type is considered as timestamp.
+func CreateModelInfoFromColumns(table string, columns []CHField) ModelInfo {
+	info := ModelInfo{
+		DBName:     table,
+		DataFields: make(map[string]*FieldProps, len(columns)),
+	}
+	for _, column := range columns {
+		field := &FieldProps{
+			CHField:        column,
+			SourceCodeName: column.CHName,
+			KibanaName:     column.CHName,
+		}
+		if column.CHType == "UInt64" && column.CHName == dynamicUUIDColumn {
+			field.IsUUID = true
+		}
+		info.DataFields[column.CHName] = field
+	}
+	for _, name := range dynamicTimestampColumns {
+		field, ok := info.DataFields[name]
+		if !ok || field.IsArray() || field.IsNullable() {
+			continue
+		}
+		if field.CHType == "UInt64" || field.IsDate() {
+			field.IsTimestamp = true
+			field.TimeUnit, _ = parseTimeUnit("", field.CHField)
+			break
+		}
+	}
+	return info
+}
+
 // GetStructureTags fetch values of required tag from all fields of structure.
 func GetStructureTags(t reflect.Type, tagName string, skipPartField bool) (tags []string, err error) {
 	defer func() {
@@ -130,9 +249,11 @@ func CreateDBFieldsInfoMap(t reflect.Type) (mapping map[string]*FieldProps, err
 		if tags.CHType == "Uint8" {
 			tags.CHType = "Bool"
 		}
-		_, isTime := field.Tag.Lookup("timestamp")
-		if isTime {
-			tags.CHType = timeStampType
+		if unit, isTime := field.Tag.Lookup("timestamp"); isTime {
+			if tags.TimeUnit, err = parseTimeUnit(unit, tags.CHField); err != nil {
+				return nil, err
+			}
+			tags.IsTimestamp = true
 		}
 		tags.SourceCodeName = field.Name
 		mapping[tags.CHName] = tags
@@ -162,15 +283,38 @@ func getFieldTags(field reflect.StructField) (tags *FieldProps) {
 	tags.CHName = field.Tag.Get("db")
 	tags.KibanaName = field.Tag.Get("json")
 	tags.CHType = field.Tag.Get("type")
+	tags.SourcePath = field.Tag.Get("src_path")
+	tags.Expression = field.Tag.Get("expr")
 	if value, ok := field.Tag.Lookup("inv_index"); ok && value == "true" {
 		tags.FullTextSearch = true
 	}
 	if value, ok := field.Tag.Lookup("uuid"); ok && value == "true" {
 		tags.IsUUID = true
 	}
+	if value, ok := field.Tag.Lookup("json_blob"); ok && value == "true" {
+		tags.JSONBlob = true
+	}
 	return
 }
 
+// GetIndexedFields returns properties of model fields with indexed content.
+func GetIndexedFields(model reflect.Type) (res []*FieldProps) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = nil
+		}
+	}()
+	res = make([]*FieldProps, 0, model.NumField())
+	for i := 0; i < model.NumField(); i++ {
+		tags := getFieldTags(model.Field(i))
+		if tags.CHName != "" && tags.FullTextSearch {
+			tags.SourceCodeName = model.Field(i).Name
+			res = append(res, tags)
+		}
+	}
+	return res
+}
+
 // GetIndexedDbColumns returns list of database table columns with indexed content.
 func GetIndexedDbColumns(model reflect.Type) (res []string) {
 	defer func() {
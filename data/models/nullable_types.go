@@ -2,7 +2,13 @@ package models
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // NullInt64 is an alias for sql.NullInt64 data type
@@ -18,6 +24,58 @@ func (ni NullInt64) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ni.Int64)
 }
 
+// NullUint64 represents uint64 that may be null, UInt64 values don't fit into NullInt64
+type NullUint64 struct {
+	Uint64 uint64
+	Valid  bool
+}
+
+// Scan implements the sql.Scanner interface
+func (nu *NullUint64) Scan(value interface{}) error {
+	nu.Uint64, nu.Valid = 0, false
+	if value == nil {
+		return nil
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		nu.Uint64 = v.Uint()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return errors.Errorf("cannot scan negative value %d into NullUint64", v.Int())
+		}
+		nu.Uint64 = uint64(v.Int())
+	case reflect.String:
+		u, err := strconv.ParseUint(v.String(), 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "cannot scan value into NullUint64")
+		}
+		nu.Uint64 = u
+	default:
+		if b, ok := value.([]byte); ok {
+			return nu.Scan(string(b))
+		}
+		return errors.Errorf("cannot scan %T into NullUint64", value)
+	}
+	nu.Valid = true
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (nu NullUint64) Value() (driver.Value, error) {
+	if !nu.Valid {
+		return nil, nil
+	}
+	return nu.Uint64, nil
+}
+
+// MarshalJSON for NullUint64
+func (nu NullUint64) MarshalJSON() ([]byte, error) {
+	if !nu.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nu.Uint64)
+}
+
 // NullBool is an alias for sql.NullBool data type
 type NullBool struct {
 	sql.NullBool
@@ -56,3 +114,31 @@ func (ns NullString) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(ns.String)
 }
+
+// NullTime represents time.Time that may be null
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface
+func (nt *NullTime) Scan(value interface{}) error {
+	nt.Time, nt.Valid = value.(time.Time)
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (nt NullTime) Value() (driver.Value, error) {
+	if !nt.Valid {
+		return nil, nil
+	}
+	return nt.Time, nil
+}
+
+// MarshalJSON for NullTime
+func (nt NullTime) MarshalJSON() ([]byte, error) {
+	if !nt.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nt.Time)
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Units of time values stored in integer columns.
+const (
+	Nanoseconds  = "ns"
+	Microseconds = "us"
+	Milliseconds = "ms"
+	Seconds      = "s"
+)
+
+var timeUnits = map[string]time.Duration{
+	Nanoseconds:  time.Nanosecond,
+	Microseconds: time.Microsecond,
+	Milliseconds: time.Millisecond,
+	Seconds:      time.Second,
+}
+
+// parseTimeUnit returns unit of timestamp declared by the timestamp tag, "true" means nanoseconds.
+// Date, DateTime and DateTime64 columns don't require any unit, it is defined by their type.
+func parseTimeUnit(tag string, field CHField) (string, error) {
+	if field.IsDate() {
+		return "", nil
+	}
+	if !field.IsNumeric() {
+		return "", errors.Errorf("timestamp column %s should be of integer or date type", field.CHName)
+	}
+	if tag == "" || tag == "true" {
+		return Nanoseconds, nil
+	}
+	if _, ok := timeUnits[tag]; !ok {
+		return "", errors.Errorf("unknown time unit %s of column %s", tag, field.CHName)
+	}
+	return tag, nil
+}
+
+// TimeUnitDuration returns duration of the field numeric value unit, numeric representation of dates is in seconds.
+func (f CHField) TimeUnitDuration() time.Duration {
+	if f.IsDate() {
+		return time.Second
+	}
+	if duration, ok := timeUnits[f.TimeUnit]; ok {
+		return duration
+	}
+	return time.Nanosecond
+}
+
+// IsTime checks that field contains points in time: dates or integer timestamps.
+func (f CHField) IsTime() bool {
+	return f.TimeUnit != "" || f.IsDate()
+}
+
+// TimeLiteral converts time in nanoseconds to clickhouse literal comparable with the field values,
+// time is rounded to the field precision (float boundaries of time ranges are not exact).
+func (f CHField) TimeLiteral(nanos int64) string {
+	switch typeName(f.GetBaseChType()) {
+	case "Date":
+		return fmt.Sprintf("toDate(toDateTime(%d))", roundTime(nanos, int64(time.Second)))
+	case "DateTime":
+		return fmt.Sprintf("toDateTime(%d)", roundTime(nanos, int64(time.Second)))
+	case "DateTime64":
+		precision := f.DateTimePrecision()
+		unit := int64(math.Pow10(9 - precision))
+		nanos = roundTime(nanos, unit) * unit
+		value := fmt.Sprintf("%d.%09d", nanos/int64(time.Second), nanos%int64(time.Second))
+		// sub-second digits beyond the column precision are zeros
+		value = strings.TrimSuffix(value[:len(value)-9+precision], ".")
+		return fmt.Sprintf("toDateTime64(toDecimal64(%s, %d), %d)", QuoteString(value), precision, precision)
+	}
+	return strconv.FormatInt(roundTime(nanos, int64(f.TimeUnitDuration())), 10)
+}
+
+// roundTime converts nanoseconds to the number of units rounded to the nearest one.
+func roundTime(nanos int64, unit int64) int64 {
+	return (nanos + unit/2) / unit
+}
+
+// TimeNanosExpression returns clickhouse expression converting field values to nanoseconds.
+func (f CHField) TimeNanosExpression() string {
+	switch typeName(f.GetBaseChType()) {
+	case "Date":
+		return fmt.Sprintf("(toUInt64(toDateTime(%s)) * %d)", f.CHName, time.Second)
+	case "DateTime":
+		return fmt.Sprintf("(toUInt64(%s) * %d)", f.CHName, time.Second)
+	case "DateTime64":
+		return fmt.Sprintf("toUnixTimestamp64Nano(%s)", f.CHName)
+	}
+	if duration := f.TimeUnitDuration(); duration != time.Nanosecond {
+		return fmt.Sprintf("(%s * %d)", f.CHName, duration)
+	}
+	return f.CHName
+}
+
+// TimeValue converts value loaded from the field to time, false is returned for NULL and non time values.
+func (f CHField) TimeValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case NullTime:
+		return v.Time, v.Valid
+	case NullInt64:
+		if !v.Valid {
+			return time.Time{}, false
+		}
+		value = v.Int64
+	case NullUint64:
+		if !v.Valid {
+			return time.Time{}, false
+		}
+		value = v.Uint64
+	}
+
+	duration := int64(f.TimeUnitDuration())
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return time.Unix(0, v.Int()*duration), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return time.Unix(0, int64(v.Uint())*duration), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// types wrapping other clickhouse types without changing their values representation.
+var wrapperTypes = []string{"Nullable", "LowCardinality"}
+
+// typeName returns clickhouse type name without parameters, e.g. DateTime64(3, 'UTC') -> DateTime64.
+func typeName(chType string) string {
+	if pos := strings.Index(chType, "("); pos != -1 {
+		return strings.TrimSpace(chType[:pos])
+	}
+	return strings.TrimSpace(chType)
+}
+
+// typeParams returns list of clickhouse type parameters, e.g. Map(String, UInt64) -> [String UInt64].
+func typeParams(chType string) []string {
+	start, end := strings.Index(chType, "("), strings.LastIndex(chType, ")")
+	if start == -1 || end < start {
+		return nil
+	}
+	params := make([]string, 0)
+	depth, pos := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch chType[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(chType[pos:i]))
+				pos = i + 1
+			}
+		}
+	}
+	return append(params, strings.TrimSpace(chType[pos:end]))
+}
+
+// unwrapType removes Nullable and LowCardinality wrappers from the clickhouse type.
+func unwrapType(chType string) string {
+	for {
+		unwrapped := false
+		for _, wrapper := range wrapperTypes {
+			if typeName(chType) == wrapper {
+				if params := typeParams(chType); len(params) == 1 {
+					chType, unwrapped = params[0], true
+				}
+			}
+		}
+		if !unwrapped {
+			return chType
+		}
+	}
+}
+
+// IsNullable checks that field (or array elements) could contain NULL values.
+func (f CHField) IsNullable() bool {
+	chType := f.CHType
+	if f.IsArray() {
+		chType = typeParams(chType)[0]
+	}
+	for {
+		switch typeName(chType) {
+		case "Nullable":
+			return true
+		case "LowCardinality":
+			chType = typeParams(chType)[0]
+		default:
+			return false
+		}
+	}
+}
+
+// IsLowCardinality checks that field contains dictionary encoded values.
+func (f CHField) IsLowCardinality() bool {
+	return strings.Contains(f.CHType, "LowCardinality(")
+}
+
+// IsText checks that field contains arbitrary strings, substrings of such values could be searched.
+func (f CHField) IsText() bool {
+	switch typeName(f.GetBaseChType()) {
+	case "String", "FixedString":
+		return true
+	}
+	return false
+}
+
+// IsDate checks that field contains date or time values.
+func (f CHField) IsDate() bool {
+	switch typeName(f.GetBaseChType()) {
+	case "Date", "DateTime", "DateTime64":
+		return true
+	}
+	return false
+}
+
+// IsIP checks that field contains IPv4 or IPv6 addresses.
+func (f CHField) IsIP() bool {
+	switch typeName(f.GetBaseChType()) {
+	case "IPv4", "IPv6":
+		return true
+	}
+	return false
+}
+
+// IsEnum checks that field contains Enum8 or Enum16 values.
+func (f CHField) IsEnum() bool {
+	switch typeName(f.GetBaseChType()) {
+	case "Enum8", "Enum16":
+		return true
+	}
+	return false
+}
+
+// IsMap checks that field contains Map values, every map key is exposed as separate sub-field.
+func (f CHField) IsMap() bool {
+	return typeName(f.CHType) == "Map"
+}
+
+// DateTimePrecision returns number of sub-second digits of DateTime64 field.
+func (f CHField) DateTimePrecision() int {
+	base := f.GetBaseChType()
+	if typeName(base) != "DateTime64" {
+		return 0
+	}
+	if params := typeParams(base); len(params) > 0 {
+		if precision, err := strconv.Atoi(params[0]); err == nil {
+			return precision
+		}
+	}
+	// default DateTime64 precision
+	return 3
+}
+
+// MapSubField returns field for the single map key, its name is the clickhouse expression for accessing the key.
+func (f CHField) MapSubField(key string) CHField {
+	valueType := "String"
+	if params := typeParams(f.CHType); len(params) == 2 {
+		valueType = params[1]
+	}
+	return CHField{
+		CHName: fmt.Sprintf("%s[%s]", f.CHName, QuoteString(key)),
+		CHType: valueType,
+	}
+}
+
+// Literal converts value to clickhouse literal of the field type, false is returned if value cannot be converted.
+func (f CHField) Literal(value interface{}) (string, bool) {
+	str := ""
+	switch v := value.(type) {
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		str = v
+	default:
+		str = fmt.Sprintf("%v", v)
+	}
+
+	base := f.GetBaseChType()
+	if f.IsNumeric() || base == "Bool" {
+		if _, err := strconv.ParseFloat(str, 64); err != nil {
+			return "", false
+		}
+		return str, true
+	}
+
+	switch typeName(base) {
+	case "UUID":
+		return fmt.Sprintf("toUUID(%s)", QuoteString(str)), true
+	case "IPv4":
+		return fmt.Sprintf("toIPv4(%s)", QuoteString(str)), true
+	case "IPv6":
+		return fmt.Sprintf("toIPv6(%s)", QuoteString(str)), true
+	case "Date":
+		return fmt.Sprintf("toDate(%s)", QuoteString(str)), true
+	case "DateTime":
+		return fmt.Sprintf("toDateTime(%s)", QuoteString(str)), true
+	case "DateTime64":
+		return fmt.Sprintf("toDateTime64(%s, %d)", QuoteString(str), f.DateTimePrecision()), true
+	case "Map":
+		return "", false
+	}
+	return QuoteString(str), true
+}
+
+// QuoteString converts string to clickhouse string literal.
+func QuoteString(str string) string {
+	str = strings.Replace(str, `\`, `\\`, -1)
+	return "'" + strings.Replace(str, "'", `\'`, -1) + "'"
+}
+
+// DefaultValueExpression returns clickhouse expression with default value of the field type,
+// it is used for columns missing in some of tables read together.
+func (f CHField) DefaultValueExpression() string {
+	chType := unwrapType(f.CHType)
+	value := "0"
+	switch {
+	case f.IsNullable():
+		value = "NULL"
+	case f.IsArray():
+		value = "[]"
+	case f.IsMap():
+		value = "map()"
+	case typeName(chType) == "UUID":
+		value = "'00000000-0000-0000-0000-000000000000'"
+	case typeName(chType) == "IPv4":
+		value = "'0.0.0.0'"
+	case typeName(chType) == "IPv6":
+		value = "'::'"
+	case f.IsEnum():
+		// the first enum value
+		if params := typeParams(chType); len(params) > 0 {
+			value = strings.TrimSpace(strings.SplitN(params[0], "=", 2)[0])
+		}
+	case f.IsText():
+		value = "''"
+	}
+	return fmt.Sprintf("CAST(%s AS %s)", value, f.CHType)
+}
@@ -0,0 +1,231 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+)
+
+// dynamicModelTTL specifies how long discovered table structure is cached.
+const dynamicModelTTL = time.Minute
+
+// ColumnsLoader returns list of clickhouse table columns.
+type ColumnsLoader func(table string) ([]models.CHField, error)
+
+var columnsLoader ColumnsLoader
+var logsTablesFilter func(table string) bool
+
+type discoveredModel struct {
+	info     models.ModelInfo
+	loadedAt time.Time
+}
+
+var discoveredModels = map[string]discoveredModel{}
+var discoveredModelsMutex = &sync.Mutex{}
+
+// SetLogsTablesFilter sets check of tables, which could be read without compiled-in or declared models.
+func SetLogsTablesFilter(filter func(table string) bool) {
+	discoveredModelsMutex.Lock()
+	logsTablesFilter = filter
+	discoveredModelsMutex.Unlock()
+}
+
+// SetColumnsLoader sets source of tables structure for logs tables without compiled-in models.
+func SetColumnsLoader(loader ColumnsLoader) {
+	discoveredModelsMutex.Lock()
+	columnsLoader = loader
+	discoveredModels = map[string]discoveredModel{}
+	discoveredModelsMutex.Unlock()
+}
+
+// NewDynamicWrapper creates data container for logs table, which model is built from the table structure.
+func NewDynamicWrapper(table string) (ChDataWrapper, error) {
+	info, err := discoverModel(table)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamicWrapper{
+		dataContainer: dataContainer{
+			index:     0,
+			modelInfo: info,
+		},
+		data: nil,
+	}, nil
+}
+
+// NewModelWrapper creates data container for logs table with model declared in configuration.
+func NewModelWrapper(table string, model reflect.Type) (ChDataWrapper, error) {
+	dbFieldsMapping, err := models.CreateDBFieldsInfoMap(model)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamicWrapper{
+		dataContainer: dataContainer{
+			index: 0,
+			modelInfo: models.ModelInfo{
+				DBName:     table,
+				DataFields: dbFieldsMapping,
+			},
+		},
+		data: nil,
+	}, nil
+}
+
+// NewModelInfoWrapper creates data container for rows of the model built in runtime (e.g. union of several tables).
+func NewModelInfoWrapper(info models.ModelInfo) ChDataWrapper {
+	return &DynamicWrapper{
+		dataContainer: dataContainer{
+			index:     0,
+			modelInfo: info,
+		},
+		data: nil,
+	}
+}
+
+func discoverModel(table string) (models.ModelInfo, error) {
+	discoveredModelsMutex.Lock()
+	defer discoveredModelsMutex.Unlock()
+
+	if model, ok := discoveredModels[table]; ok && time.Since(model.loadedAt) < dynamicModelTTL {
+		return model.info, nil
+	}
+	if columnsLoader == nil {
+		return models.ModelInfo{}, errors.New("tables structure loader is not set")
+	}
+
+	columns, err := columnsLoader(table)
+	if err != nil {
+		return models.ModelInfo{}, errors.Wrap(err, "cannot load structure of table "+table)
+	}
+	if len(columns) == 0 {
+		return models.ModelInfo{}, errors.New("table not exists or has no columns: " + table)
+	}
+
+	info := models.CreateModelInfoFromColumns(table, columns)
+	discoveredModels[table] = discoveredModel{info: info, loadedAt: time.Now()}
+	return info, nil
+}
+
+type DynamicItem struct {
+	data      map[string]interface{}
+	modelInfo *models.ModelInfo
+}
+
+// ID returns value of uuid column if exists, otherwise hash of all row values is used.
+func (i DynamicItem) ID() string {
+	if uuid, ok := i.modelInfo.GetUuidField(); ok {
+		if value, ok := i.data[uuid.CHName]; ok {
+			return fmt.Sprintf("%v", value)
+		}
+	}
+
+	columns := make([]string, 0, len(i.data))
+	for column := range i.data {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	hash := fnv.New64a()
+	for _, column := range columns {
+		fmt.Fprintf(hash, "%s=%v;", column, i.data[column])
+	}
+	return strconv.FormatUint(hash.Sum64(), 10)
+}
+
+func (i DynamicItem) Data() interface{} {
+	return i.data
+}
+
+func (i DynamicItem) AttrValue(name string) (*reflect.Value, bool) {
+	value, ok := i.data[name]
+	if !ok || value == nil {
+		return nil, false
+	}
+	reflectValue := reflect.ValueOf(value)
+	return &reflectValue, true
+}
+
+// ChTableName returns name of the table containing the row, rows selected from several tables have it in _table column.
+func (i DynamicItem) ChTableName() string {
+	if table, ok := i.data["_table"].(string); ok && table != "" {
+		return table
+	}
+	return i.modelInfo.DBName
+}
+
+func (i DynamicItem) ModelScheme() *models.ModelInfo {
+	return i.modelInfo
+}
+
+// DynamicWrapper contains logs rows as column name to value maps.
+type DynamicWrapper struct {
+	dataContainer
+	data []map[string]interface{}
+}
+
+func (container *DynamicWrapper) NextItem() DataItem {
+	if container == nil {
+		return nil
+	}
+	if container.index < len(container.data) {
+		container.index++
+		return DynamicItem{
+			data:      container.data[container.index-1],
+			modelInfo: &container.modelInfo,
+		}
+	}
+	return nil
+}
+
+func (container *DynamicWrapper) FetchData(loaderFunc loader) error {
+	if container == nil {
+		return errors.New("data wrapper is not initialized")
+	}
+	container.data = make([]map[string]interface{}, 0)
+	if err := loaderFunc(&container.data); err != nil {
+		return err
+	}
+	container.embedJSONPayloads()
+	return nil
+}
+
+// embedJSONPayloads replaces JSON payloads by raw JSON, so they are returned as objects in documents source.
+func (container *DynamicWrapper) embedJSONPayloads() {
+	for name, field := range container.modelInfo.DataFields {
+		if !field.IsJSONBlob() {
+			continue
+		}
+		for _, row := range container.data {
+			if payload, ok := row[name].(string); ok && json.Valid([]byte(payload)) {
+				row[name] = json.RawMessage(payload)
+			}
+		}
+	}
+}
+
+func (container *DynamicWrapper) Items() int {
+	if container == nil {
+		return 0
+	}
+	return len(container.data)
+}
+
+func isDynamicTable(table string) bool {
+	discoveredModelsMutex.Lock()
+	filter := logsTablesFilter
+	discoveredModelsMutex.Unlock()
+	if filter != nil {
+		return filter(table)
+	}
+	return strings.HasPrefix(table, models.LogsTablePrefix)
+}
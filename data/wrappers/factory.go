@@ -2,15 +2,24 @@ package wrappers
 
 import (
 	"github.com/pkg/errors"
+
+	"kibouse/data/models"
 )
 
 var factories = map[string]func() (ChDataWrapper, error){}
 
-// CreateDataContainer creates container for logs by its db table name
+// CreateDataContainer creates container for logs by its db table name, logs tables without registered
+// factory use models declared in configuration or built from the table structure.
 func CreateDataContainer(table string) (ChDataWrapper, error) {
 	if factory, ok := factories[table]; ok {
 		return factory()
 	}
+	if model, ok := models.GetLogsTablesSchemas()[table]; ok {
+		return NewModelWrapper(table, model)
+	}
+	if isDynamicTable(table) {
+		return NewDynamicWrapper(table)
+	}
 
 	return nil, errors.New("cannot create data container for unknown table: " + table)
 }
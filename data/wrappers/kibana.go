@@ -11,28 +11,41 @@ import (
 
 type KibanaSettings struct {
 	dataContainer
-	data []models.ClickhouseSettings
+	data   []models.ClickhouseSettings
+	format settings.Format
 }
 
 func init() {
 	factories[models.SettingsTableName] = NewKibanaSettings
+	factories[models.OpenSearchSettingsTableName] = NewOpenSearchSettings
 }
 
 // NewKibanaSettings creates data container for kibana settings
 func NewKibanaSettings() (ChDataWrapper, error) {
+	return newSettingsContainer(models.SettingsTableName)
+}
+
+// NewOpenSearchSettings creates data container for OpenSearch Dashboards saved objects.
+func NewOpenSearchSettings() (ChDataWrapper, error) {
+	return newSettingsContainer(models.OpenSearchSettingsTableName)
+}
+
+func newSettingsContainer(table string) (*KibanaSettings, error) {
 	dbFieldsMapping, err := models.CreateDBFieldsInfoMap(reflect.TypeOf(models.ClickhouseSettings{}))
 	if err != nil {
 		return nil, err
 	}
+	format := settings.TableFormat(table)
 	return &KibanaSettings{
 		dataContainer: dataContainer{
 			index: 0,
 			modelInfo: models.ModelInfo{
-				DBName:     models.SettingsTableName,
-				DataFields: dbFieldsMapping,
+				DBName:     table,
+				DataFields: format.DocumentFields(dbFieldsMapping),
 			},
 		},
-		data: nil,
+		data:   nil,
+		format: format,
 	}, nil
 }
 
@@ -40,14 +53,18 @@ type KibanaSettingsItem struct {
 	id string
 	data *settings.ElasticSettings
 	modelInfo *models.ModelInfo
+	format settings.Format
 }
 
 func (i KibanaSettingsItem) ID() string {
-	return i.id
+	if i.data == nil {
+		return i.id
+	}
+	return i.format.DocumentID(i.data.Type, i.id)
 }
 
 func (i KibanaSettingsItem) Data() interface{} {
-	return i.data
+	return i.format.DocumentSource(i.data)
 }
 
 func (i KibanaSettingsItem) AttrValue(name string) (*reflect.Value, bool) {
@@ -58,7 +75,7 @@ func (i KibanaSettingsItem) AttrValue(name string) (*reflect.Value, bool) {
 }
 
 func (i KibanaSettingsItem) ChTableName() string {
-	return models.SettingsTableName
+	return i.modelInfo.DBName
 }
 
 func (i KibanaSettingsItem) ModelScheme() *models.ModelInfo {
@@ -85,6 +102,7 @@ func (container *KibanaSettings) NextItem() DataItem {
 			data: settings.ClickhouseToElastic(clickhouseSettings),
 			modelInfo: &container.modelInfo,
 			id: clickhouseSettings.ID,
+			format: container.format,
 		}
 	}
 	return nil
@@ -13,10 +13,30 @@ type connection struct {
 
 func (c *connection) createDataLoader(query string) func(items interface{}) error {
 	return func(items interface{}) error {
+		if rows, ok := items.(*[]map[string]interface{}); ok {
+			return c.selectMaps(rows, query)
+		}
 		return c.db.Select(items, query)
 	}
 }
 
+// selectMaps loads rows of arbitrary structure as column name to value maps.
+func (c *connection) selectMaps(result *[]map[string]interface{}, query string) error {
+	rows, err := c.db.Queryx(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return errors.Wrap(err, "Data rows scanning error")
+		}
+		*result = append(*result, row)
+	}
+	return rows.Err()
+}
+
 func (c *connection) selectSingleColumn(query string) ([]interface{}, error) {
 	result := make([]interface{}, 0)
 	rows, err := c.db.Query(query)
@@ -34,6 +54,38 @@ func (c *connection) selectSingleColumn(query string) ([]interface{}, error) {
 	return result, nil
 }
 
+// selectRows loads rows of the query result as lists of values, names and types of columns are returned too.
+func (c *connection) selectRows(query string) ([]string, []string, [][]interface{}, error) {
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "SQL data querying failed: "+query)
+	}
+	defer rows.Close()
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "cannot read columns of result: "+query)
+	}
+	names := make([]string, len(columns))
+	types := make([]string, len(columns))
+	for i, column := range columns {
+		names[i], types[i] = column.Name(), column.DatabaseTypeName()
+	}
+
+	result := make([][]interface{}, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "Data rows scanning error")
+		}
+		result = append(result, values)
+	}
+	return names, types, result, rows.Err()
+}
+
 func (c *connection) exec(query string) (sql.Result, error) {
 	res, err := c.db.Exec(query)
 	if err != nil {
@@ -42,6 +94,10 @@ func (c *connection) exec(query string) (sql.Result, error) {
 	return res, err
 }
 
+func (c *connection) ping() error {
+	return errors.Wrap(c.db.Ping(), "clickhouse server is not reachable")
+}
+
 func (c *connection) preparedExec(query string, inserted interface{}) (sql.Result, error) {
 	trans := c.db.MustBegin()
 	result, err := trans.NamedExec(query, inserted)
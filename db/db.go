@@ -3,7 +3,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -16,9 +18,25 @@ import (
 
 const (
 	DataBaseName            = "logs"
-	DataStorageTablesPrefix = "logs_"
+	DataStorageTablesPrefix = models.LogsTablePrefix
 )
 
+// TablesSource describes clickhouse database with logs tables exposed to kibana, tables are selected
+// by name prefix and (optionally) regular expression. Tables of databases other than kibouse database
+// are named as <database>.<table>.
+type TablesSource struct {
+	Database string `mapstructure:"name"`
+	Prefix   string `mapstructure:"prefix"`
+	Regex    string `mapstructure:"regex"`
+
+	regex *regexp.Regexp
+}
+
+// matches checks that table of the source database contains logs.
+func (s TablesSource) matches(table string) bool {
+	return strings.HasPrefix(table, s.Prefix) && (s.regex == nil || s.regex.MatchString(table))
+}
+
 // Scheme declares interface for various db creating objects.
 type Scheme interface {
 	BuildScheme(string) (string, error)
@@ -28,6 +46,7 @@ type Scheme interface {
 type DataProvider interface {
 	DataTable() string
 	DataScheme() *models.ModelInfo
+	TablesSchemes() map[string]*models.ModelInfo
 	FetchData(req *Request) (wrappers.ChDataWrapper, error)
 	CreateDataSelector(req *Request) func(items interface{}) error
 }
@@ -37,9 +56,10 @@ var mutex = &sync.RWMutex{}
 
 var notInitializedErr = errors.New("kibouse db connection is not initialized")
 
-// InitLogsDbConnection creates new instance of logs database manager.
-func InitLogsDbConnection(conn *sqlx.DB, dbName string) error {
-	l, err := newLogsDB(conn, dbName)
+// InitLogsDbConnection creates new instance of logs database manager, tables of kibouse database
+// with logs prefix and tables of all additional sources are available for reading.
+func InitLogsDbConnection(conn *sqlx.DB, dbName string, sources ...TablesSource) error {
+	l, err := newLogsDB(conn, dbName, sources)
 	if err != nil {
 		return err
 	}
@@ -63,12 +83,74 @@ func CloseLogsDbConnection() {
 
 // GetTableByIndexPattern uses for searching db log tables by elasticsearch index pattern.
 func GetTableByIndexPattern(pattern string) (string, error) {
+	tables, err := GetTablesByIndexPattern(pattern)
+	if err != nil {
+		return "", err
+	}
+	return tables[0], nil
+}
+
+// GetTablesByIndexPattern returns all db log tables matching elasticsearch index pattern,
+// pattern could be a comma separated list of indices with wildcards.
+func GetTablesByIndexPattern(pattern string) ([]string, error) {
 	pattern = strings.TrimPrefix(pattern, ".")
-	// index pattern contains wildcard symbol
-	if strings.HasSuffix(pattern, "*") {
-		return GetTableByPattern(pattern)
+	// index pattern doesn't contain wildcard symbols
+	if !strings.ContainsAny(pattern, "*?,") {
+		return []string{pattern}, nil
+	}
+
+	tables := make([]string, 0)
+	found := make(map[string]struct{})
+	for _, index := range strings.Split(pattern, ",") {
+		index = strings.TrimSpace(index)
+		matched := []string{index}
+		if strings.ContainsAny(index, "*?") {
+			var err error
+			if matched, err = GetTablesByPattern(index); err != nil {
+				return nil, err
+			}
+		}
+		for _, table := range matched {
+			if _, ok := found[table]; !ok && table != "" {
+				found[table] = struct{}{}
+				tables = append(tables, table)
+			}
+		}
+	}
+	if len(tables) == 0 {
+		return nil, errors.New("no tables found by pattern " + pattern)
 	}
-	return pattern, nil
+	return tables, nil
+}
+
+// TableFullName returns table name qualified by database, tables without database belong to kibouse database.
+func TableFullName(table string) string {
+	database, name := SplitTableName(table)
+	return database + "." + name
+}
+
+// SplitTableName returns database and name of the table.
+func SplitTableName(table string) (string, string) {
+	if pos := strings.Index(table, "."); pos > 0 {
+		return table[:pos], table[pos+1:]
+	}
+	return DataBaseName, table
+}
+
+// IsLogsTable checks that table belongs to one of logs tables sources.
+func IsLogsTable(table string) bool {
+	database, name := SplitTableName(table)
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if logs == nil {
+		return database == DataBaseName && strings.HasPrefix(name, DataStorageTablesPrefix)
+	}
+	for _, source := range logs.sources {
+		if source.Database == database && source.matches(name) {
+			return true
+		}
+	}
+	return false
 }
 
 // CreateTable adds new table to database.
@@ -103,20 +185,38 @@ func TableExists(table string) (bool, error) {
 	return logs.tableExists(table)
 }
 
-// GetTableByPattern returns data tables matching the pattern.
+// GetTableByPattern returns the first data table matching the pattern.
 func GetTableByPattern(pattern string) (string, error) {
+	tables, err := GetTablesByPattern(pattern)
+	if err != nil {
+		return "", err
+	}
+	return tables[0], nil
+}
+
+// GetTablesByPattern returns all data tables matching the pattern.
+func GetTablesByPattern(pattern string) ([]string, error) {
 	if logs == nil {
-		return "", notInitializedErr
+		return nil, notInitializedErr
 	}
 
 	mutex.RLock()
-	defer mutex.RUnlock()
+	tables := logs.findTables(pattern)
+	mutex.RUnlock()
+	if len(tables) > 0 {
+		return tables, nil
+	}
 
-	if table, ok := logs.findTable(pattern); !ok {
-		return "", errors.New("no tables found by pattern " + pattern)
-	} else {
-		return table, nil
+	// table could be created after the start, so list of tables should be reloaded
+	mutex.Lock()
+	defer mutex.Unlock()
+	if err := logs.updateLogsTablesList(); err != nil {
+		return nil, err
 	}
+	if tables := logs.findTables(pattern); len(tables) > 0 {
+		return tables, nil
+	}
+	return nil, errors.New("no tables found by pattern " + pattern)
 }
 
 // InsertIntoTable adds new entry to the table from kibouse db.
@@ -142,6 +242,31 @@ func Execute(query string) (sql.Result, error) {
 	return logs.conn.exec(query)
 }
 
+// SelectRows performs SQL query and returns names and clickhouse types of result columns
+// with rows of values in order of columns.
+func SelectRows(query string) ([]string, []string, [][]interface{}, error) {
+	if logs == nil {
+		return nil, nil, nil, notInitializedErr
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return logs.conn.selectRows(query)
+}
+
+// Ping checks that clickhouse server is reachable.
+func Ping() error {
+	if logs == nil {
+		return notInitializedErr
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return logs.conn.ping()
+}
+
 // CreateDataSelector returns function for loading data from db to the specific container.
 func CreateDataSelector(req *Request) func(items interface{}) error {
 	if logs == nil {
@@ -159,12 +284,13 @@ func CreateDataSelector(req *Request) func(items interface{}) error {
 
 // logsDB manages logs database structure.
 type logsDB struct {
-	conn   connection
-	tables []string
-	dbName string
+	conn    connection
+	tables  []string
+	dbName  string
+	sources []TablesSource
 }
 
-func newLogsDB(db *sqlx.DB, dbName string) (*logsDB, error) {
+func newLogsDB(db *sqlx.DB, dbName string, sources []TablesSource) (*logsDB, error) {
 	if db == nil {
 		return nil, errors.New("nil db connection")
 	}
@@ -176,8 +302,31 @@ func newLogsDB(db *sqlx.DB, dbName string) (*logsDB, error) {
 		conn: connection{
 			db: db,
 		},
-		dbName: dbName,
-		tables: make([]string, 0),
+		dbName:  dbName,
+		tables:  make([]string, 0),
+		sources: make([]TablesSource, 0, len(sources)+1),
+	}
+
+	// kibouse database could be listed to change its logs tables filter
+	defaultSource := true
+	for _, source := range sources {
+		if source.Database == "" {
+			return nil, errors.New("database name of logs tables source is empty")
+		}
+		if source.Regex != "" {
+			regex, err := regexp.Compile(source.Regex)
+			if err != nil {
+				return nil, errors.Wrap(err, "incorrect tables regex of database "+source.Database)
+			}
+			source.regex = regex
+		}
+		if source.Database == dbName {
+			defaultSource = false
+		}
+		logs.sources = append(logs.sources, source)
+	}
+	if defaultSource {
+		logs.sources = append([]TablesSource{{Database: dbName, Prefix: DataStorageTablesPrefix}}, logs.sources...)
 	}
 
 	return logs, logs.updateLogsTablesList()
@@ -219,13 +368,20 @@ func (l *logsDB) tableExists(table string) (bool, error) {
 func (l *logsDB) updateLogsTablesList() error {
 	l.tables = make([]string, 0)
 
-	tablesExisted, err := l.conn.selectSingleColumn("Show tables from " + l.dbName)
-	if err != nil {
-		return errors.Wrap(err, "cannot read list of registered tables from database")
-	}
+	for _, source := range l.sources {
+		tablesExisted, err := l.conn.selectSingleColumn("Show tables from " + source.Database)
+		if err != nil {
+			return errors.Wrap(err, "cannot read list of registered tables from database "+source.Database)
+		}
 
-	for i := range tablesExisted {
-		if table := tablesExisted[i].(string); strings.HasPrefix(table, DataStorageTablesPrefix) {
+		for i := range tablesExisted {
+			table := tablesExisted[i].(string)
+			if !source.matches(table) {
+				continue
+			}
+			if source.Database != l.dbName {
+				table = source.Database + "." + table
+			}
 			l.tables = append(l.tables, table)
 		}
 	}
@@ -233,13 +389,12 @@ func (l *logsDB) updateLogsTablesList() error {
 	return nil
 }
 
-func (l *logsDB) findTable(pattern string) (string, bool) {
-	pattern = strings.TrimSuffix(pattern, "*")
-
+func (l *logsDB) findTables(pattern string) []string {
+	tables := make([]string, 0)
 	for i := range l.tables {
-		if strings.HasPrefix(l.tables[i], pattern) {
-			return l.tables[i], true
+		if matched, _ := path.Match(pattern, l.tables[i]); matched {
+			tables = append(tables, l.tables[i])
 		}
 	}
-	return "", false
+	return tables
 }
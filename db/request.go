@@ -12,6 +12,8 @@ const (
 	havingTbl  = "HAVING %s"
 	orderByTpl = "ORDER BY %s"
 	limitTpl   = "LIMIT %d"
+	limitByTpl = "LIMIT %d BY %s"
+	offsetTpl  = "OFFSET %d"
 )
 
 type SortOrder string
@@ -29,7 +31,9 @@ type Request struct {
 	sorting string
 	group   string
 	having  string
+	limitBy string
 	limit   string
+	offset  string
 	final   bool
 	isEmpty bool
 }
@@ -137,6 +141,23 @@ func (t *Request) Limit(limit int) *Request {
 	return t
 }
 
+// LimitBy sets maximum number of output data rows with the same values of expressions.
+func (t *Request) LimitBy(limit int, by string) *Request {
+	if by != "" {
+		t.limitBy = fmt.Sprintf(limitByTpl, limit, by)
+	}
+	return t
+}
+
+// Offset sets number of output data rows skipped before the limited ones.
+func (t *Request) Offset(offset int) *Request {
+	t.offset = ""
+	if offset > 0 {
+		t.offset = fmt.Sprintf(offsetTpl, offset)
+	}
+	return t
+}
+
 func (t *Request) IsEmpty() bool {
 	return t.isEmpty
 }
@@ -156,8 +177,16 @@ func (t *Request) Build() string {
 	req.WriteString(" " + t.having)
 	// append sorting sorting
 	req.WriteString(" " + t.sorting)
+	// append rows limit per group of values
+	if t.limitBy != "" {
+		req.WriteString(" " + t.limitBy)
+	}
 	// append rows limit
 	req.WriteString(" " + t.limit)
+	// append number of skipped rows
+	if t.offset != "" {
+		req.WriteString(" " + t.offset)
+	}
 
 	return req.String()
 }
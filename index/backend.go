@@ -0,0 +1,122 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"kibouse/data/models"
+)
+
+// Supported full text search backends.
+const (
+	// InvertedIndexBackend uses separate inverted index table filled by indexer.
+	InvertedIndexBackend = "inverted_index"
+	// TokenBloomFilterBackend uses tokenbf_v1 data skipping indexes of logs table columns.
+	TokenBloomFilterBackend = "tokenbf_v1"
+	// NgramBloomFilterBackend uses ngrambf_v1 data skipping indexes of logs table columns.
+	NgramBloomFilterBackend = "ngrambf_v1"
+)
+
+// matchAllCondition is used when searched text contains no tokens (e.g. only omitted words).
+const matchAllCondition = "1"
+
+var tablesBackends = map[string]string{}
+var backendsMutex = &sync.RWMutex{}
+
+// IsSkipIndexBackend checks that full text search backend is based on clickhouse data skipping indexes.
+func IsSkipIndexBackend(backend string) bool {
+	return backend == TokenBloomFilterBackend || backend == NgramBloomFilterBackend
+}
+
+// SetTableBackend binds full text search backend to the logs table.
+func SetTableBackend(table string, backend string) error {
+	if backend == "" {
+		backend = InvertedIndexBackend
+	}
+	if backend != InvertedIndexBackend && !IsSkipIndexBackend(backend) {
+		return errors.New("unknown full text search backend: " + backend)
+	}
+	backendsMutex.Lock()
+	tablesBackends[table] = backend
+	backendsMutex.Unlock()
+	return nil
+}
+
+// GetTableBackend returns full text search backend bound to the logs table, inverted index is used by default.
+func GetTableBackend(table string) string {
+	backendsMutex.RLock()
+	defer backendsMutex.RUnlock()
+	if backend, ok := tablesBackends[table]; ok {
+		return backend
+	}
+	return InvertedIndexBackend
+}
+
+// SkipIndexExpression returns expression covered by data skipping index,
+// the same expression must be used in search conditions for index utilization.
+func SkipIndexExpression(column models.CHField) string {
+	if column.IsArray() {
+		return fmt.Sprintf("lower(arrayStringConcat(%s, ' '))", column.CHName)
+	}
+	return fmt.Sprintf("lower(%s)", column.CHName)
+}
+
+// CreateFullTextSearchFilter returns condition for full text searching in the column of logs table
+// using backend bound to this table.
+func CreateFullTextSearchFilter(searchedText string, column models.CHField, table string, tsColumn string, timeRange string) string {
+	tokens := GetTableAnalyzer(table)(searchedText)
+	if len(tokens) == 0 {
+		return matchAllCondition
+	}
+
+	switch GetTableBackend(table) {
+	case TokenBloomFilterBackend:
+		return createTokenBloomFilterConditions(tokens, column)
+	case NgramBloomFilterBackend:
+		return createNgramBloomFilterConditions(tokens, column)
+	}
+
+	// Request to inverted index returns timestamps of required log entries,
+	// after that we should remove all inappropriate logs with the same time
+	// using additional filtering conditions
+	invIndexRequest := createInvertedIndexRequest(tokens, column.CHName, GetInvertedIndexTableName(table))
+	// add time range for search optimization
+	invIndexRequest.WhereAnd(timeRange)
+	return fmt.Sprintf("%s IN (%s) AND %s", tsColumn, invIndexRequest.Build(), createAdditionalFilters(tokens, column.CHName))
+}
+
+// createTokenBloomFilterConditions generates hasToken conditions, hasToken treats all non alphanumeric
+// symbols as separators, so analyzer tokens are split by them additionally.
+func createTokenBloomFilterConditions(tokens []string, column models.CHField) string {
+	expr := SkipIndexExpression(column)
+	conds := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		parts := strings.FieldsFunc(token, func(r rune) bool {
+			return !(unicode.IsLetter(r) || unicode.IsDigit(r))
+		})
+		for _, part := range parts {
+			conds = append(conds, fmt.Sprintf("hasToken(%s, '%s')", expr, part))
+		}
+	}
+	if len(conds) == 0 {
+		return matchAllCondition
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// createNgramBloomFilterConditions generates multiSearchAny conditions, each token is required.
+func createNgramBloomFilterConditions(tokens []string, column models.CHField) string {
+	expr := SkipIndexExpression(column)
+	conds := make([]string, len(tokens))
+	for i := range tokens {
+		conds[i] = fmt.Sprintf("multiSearchAny(%s, ['%s'])", expr, tokens[i])
+	}
+	if len(conds) == 0 {
+		return matchAllCondition
+	}
+	return strings.Join(conds, " AND ")
+}
@@ -0,0 +1,77 @@
+package index
+
+import (
+	"testing"
+
+	"kibouse/data/models"
+)
+
+func TestCreateFullTextSearchFilter(t *testing.T) {
+	testData := []struct {
+		backend string
+		table   string
+		text    string
+		column  models.CHField
+		result  string
+	}{
+		{
+			backend: InvertedIndexBackend,
+			table:   "logs_inverted",
+			text:    "Worker ends",
+			column:  models.CHField{CHName: "message", CHType: "String"},
+			result:  "ts IN (SELECT ts FROM logs.inverted_index_logs_inverted WHERE (word_hash IN (cityHash64('worker'),cityHash64('ends')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 10)) GROUP BY ts HAVING uniq(word_hash) = 2 ORDER BY ts DESC ) AND (positionCaseInsensitive(message, 'worker') != 0) AND (positionCaseInsensitive(message, 'ends') != 0)",
+		},
+		{
+			backend: TokenBloomFilterBackend,
+			table:   "logs_tokenbf",
+			text:    "Worker callback_0 ends",
+			column:  models.CHField{CHName: "message", CHType: "String"},
+			result:  "hasToken(lower(message), 'worker') AND hasToken(lower(message), 'callback') AND hasToken(lower(message), '0') AND hasToken(lower(message), 'ends')",
+		},
+		{
+			backend: NgramBloomFilterBackend,
+			table:   "logs_ngrambf",
+			text:    "Worker ends",
+			column:  models.CHField{CHName: "tags", CHType: "Array(String)"},
+			result:  "multiSearchAny(lower(arrayStringConcat(tags, ' ')), ['worker']) AND multiSearchAny(lower(arrayStringConcat(tags, ' ')), ['ends'])",
+		},
+		{
+			backend: InvertedIndexBackend,
+			table:   "logs_inverted",
+			text:    "vendor php",
+			column:  models.CHField{CHName: "message", CHType: "String"},
+			result:  "1",
+		},
+		{
+			backend: TokenBloomFilterBackend,
+			table:   "logs_tokenbf",
+			text:    "__ php",
+			column:  models.CHField{CHName: "message", CHType: "String"},
+			result:  "1",
+		},
+		{
+			backend: NgramBloomFilterBackend,
+			table:   "logs_ngrambf",
+			text:    "src/vendor",
+			column:  models.CHField{CHName: "message", CHType: "String"},
+			result:  "1",
+		},
+	}
+
+	for _, test := range testData {
+		if err := SetTableBackend(test.table, test.backend); err != nil {
+			t.Error("For", test.backend, "unexpected error: ", err)
+			continue
+		}
+		result := CreateFullTextSearchFilter(test.text, test.column, test.table, "ts", "(0 < ts) AND (ts <= 10)")
+		if result != test.result {
+			t.Error("For", test.backend,
+				"\n expected: ", test.result,
+				"\n got: ", result)
+		}
+	}
+
+	if err := SetTableBackend("logs", "unknown"); err == nil {
+		t.Error("error expected for unknown backend")
+	}
+}
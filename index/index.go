@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
 
 	"kibouse/data/models"
 	"kibouse/db"
@@ -11,8 +14,25 @@ import (
 
 const (
 	tokenDelimiter = `\W+`
+
+	DefaultAnalyzer = "default"
+	SimpleAnalyzer  = "simple"
+
+	// InvertedIndexTimestampColumn contains timestamps of indexed logs in nanoseconds.
+	InvertedIndexTimestampColumn = "ts"
 )
 
+// Analyzer splits text into search units.
+type Analyzer func(text string) []string
+
+var analyzers = map[string]Analyzer{
+	DefaultAnalyzer: GetTokens,
+	SimpleAnalyzer:  getSimpleTokens,
+}
+
+var tablesAnalyzers = map[string]Analyzer{}
+var analyzersMutex = &sync.RWMutex{}
+
 // gateLogsWordsToOmits contains most frequent words,
 // these words must be omitted in search request as well!
 var gateLogsWordsToOmits = map[string]struct{}{
@@ -28,14 +48,59 @@ var gateLogsWordsToOmits = map[string]struct{}{
 	"vendor": {},
 }
 
+// GetAnalyzer returns analyzer by its name.
+func GetAnalyzer(name string) (Analyzer, error) {
+	if name == "" {
+		name = DefaultAnalyzer
+	}
+	if analyzer, ok := analyzers[name]; ok {
+		return analyzer, nil
+	}
+	return nil, errors.New("unknown analyzer: " + name)
+}
+
+// SetTableAnalyzer binds analyzer to the logs table, it is used both for indexing and searching table data.
+func SetTableAnalyzer(table string, name string) error {
+	analyzer, err := GetAnalyzer(name)
+	if err != nil {
+		return err
+	}
+	analyzersMutex.Lock()
+	tablesAnalyzers[table] = analyzer
+	analyzersMutex.Unlock()
+	return nil
+}
+
+// GetTableAnalyzer returns analyzer bound to the logs table or default one.
+func GetTableAnalyzer(table string) Analyzer {
+	analyzersMutex.RLock()
+	defer analyzersMutex.RUnlock()
+	if analyzer, ok := tablesAnalyzers[table]; ok {
+		return analyzer
+	}
+	return GetTokens
+}
+
 // GetTokens is used for getting search units from analyzed text.
 func GetTokens(text string) []string {
+	return tokenize(text, gateLogsWordsToOmits)
+}
+
+// getSimpleTokens splits text to unique lowercase words without omitting frequent ones.
+func getSimpleTokens(text string) []string {
+	return tokenize(text, nil)
+}
+
+func tokenize(text string, wordsToOmit map[string]struct{}) []string {
 	tokens := regexp.MustCompile(tokenDelimiter).Split(strings.ToLower(text), -1)
 
 	result := make([]string, 0, len(tokens))
 	uniqWords := make(map[string]int)
 	for i := range tokens {
-		if _, toOmit := gateLogsWordsToOmits[tokens[i]]; toOmit {
+		if tokens[i] == "" {
+			continue
+		}
+		if _, toOmit := wordsToOmit[tokens[i]]; toOmit {
 			continue
 		}
 		if uniqWords[tokens[i]]++; uniqWords[tokens[i]] > 1 {
@@ -67,18 +132,20 @@ func generateWhere(tokens []string, column string) string {
 	return fmt.Sprintf("(%s)", cond.String())
 }
 
-// GetInvertedIndexTableName generates inverted index table name for data table by its name.
+// GetInvertedIndexTableName generates inverted index table name for data table by its name,
+// inverted index is stored in the database of data table.
 func GetInvertedIndexTableName(dataTable string) string {
-	return models.InvertedIndexTablePrefix + dataTable
+	database, name := db.SplitTableName(dataTable)
+	return database + "." + models.InvertedIndexTablePrefix + name
 }
 
 // createInvertedIndexRequest creates request for fetching log timestamps from inverted index.
 func createInvertedIndexRequest(tokens []string, column string, invertedIndexTable string) *db.Request {
-	request := db.NewRequest(db.DataBaseName+"."+invertedIndexTable, "ts")
+	request := db.NewRequest(db.TableFullName(invertedIndexTable), InvertedIndexTimestampColumn)
 	request.Where(generateWhere(tokens, column))
-	request.GroupBy("ts")
+	request.GroupBy(InvertedIndexTimestampColumn)
 	request.Having(fmt.Sprintf("uniq(word_hash) = %d", len(tokens)))
-	request.OrderBy("ts", db.DESC)
+	request.OrderBy(InvertedIndexTimestampColumn, db.DESC)
 	return request
 }
 
@@ -90,10 +157,3 @@ func createAdditionalFilters(tokens []string, column string) string {
 	}
 	return strings.Join(conds, " AND ")
 }
-
-// CreateFullTextSearchConditions returns conditions required for full text searching.
-func CreateFullTextSearchConditions(searchedText string, column string, invertedIndexTable string) (*db.Request, string) {
-	tokens := GetTokens(searchedText)
-	return createInvertedIndexRequest(tokens, column, invertedIndexTable),
-		createAdditionalFilters(tokens, column)
-}
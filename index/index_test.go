@@ -20,28 +20,35 @@ func TestCreateInvertedIndexRequest(t *testing.T) {
 			column:  "message",
 			table:   "logs.inverted_index_logs_2p_gate",
 			tsRange: "(0 < ts) AND (ts <= 1542894389184806000)",
-			result:  "SELECT ts FROM logs.logs.inverted_index_logs_2p_gate WHERE (word_hash IN (cityHash64('worker'),cityHash64('callback_0'),cityHash64('ends'),cityHash64('handling')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389184806000)) GROUP BY ts HAVING uniq(word_hash) = 4 ORDER BY ts DESC",
+			result:  "SELECT ts FROM logs.inverted_index_logs_2p_gate WHERE (word_hash IN (cityHash64('worker'),cityHash64('callback_0'),cityHash64('ends'),cityHash64('handling')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389184806000)) GROUP BY ts HAVING uniq(word_hash) = 4 ORDER BY ts DESC",
 		},
 		{
 			text:    "SQL : UPDATE Shard02.uniques00 SET status = 'update'",
 			column:  "message",
 			table:   "logs.inverted_index_logs_2p_gate",
 			tsRange: "(0 < ts) AND (ts <= 1542894389184806000)",
-			result:  "SELECT ts FROM logs.logs.inverted_index_logs_2p_gate WHERE (word_hash IN (cityHash64('sql'),cityHash64('update'),cityHash64('shard02'),cityHash64('uniques00'),cityHash64('set'),cityHash64('status')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389184806000)) GROUP BY ts HAVING uniq(word_hash) = 6 ORDER BY ts DESC",
+			result:  "SELECT ts FROM logs.inverted_index_logs_2p_gate WHERE (word_hash IN (cityHash64('sql'),cityHash64('update'),cityHash64('shard02'),cityHash64('uniques00'),cityHash64('set'),cityHash64('status')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389184806000)) GROUP BY ts HAVING uniq(word_hash) = 6 ORDER BY ts DESC",
 		},
 		{
 			text:    "#1197-62-1542894388|2117811957304647739:7567544320748365149:4913069837673205509",
 			column:  "message",
 			table:   "logs.inverted_index_logs_2p_gate",
 			tsRange: "(0 < ts) AND (ts <= 1542894389183470000)",
-			result:  "SELECT ts FROM logs.logs.inverted_index_logs_2p_gate WHERE (word_hash IN (cityHash64('1197'),cityHash64('62'),cityHash64('1542894388'),cityHash64('2117811957304647739'),cityHash64('7567544320748365149'),cityHash64('4913069837673205509')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389183470000)) GROUP BY ts HAVING uniq(word_hash) = 6 ORDER BY ts DESC",
+			result:  "SELECT ts FROM logs.inverted_index_logs_2p_gate WHERE (word_hash IN (cityHash64('1197'),cityHash64('62'),cityHash64('1542894388'),cityHash64('2117811957304647739'),cityHash64('7567544320748365149'),cityHash64('4913069837673205509')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389183470000)) GROUP BY ts HAVING uniq(word_hash) = 6 ORDER BY ts DESC",
 		},
 		{
 			text:    "/data/pmx/vendor/eco/connection-manager/src/DB.php",
 			column:  "file",
 			table:   "logs.inverted_index_logs_2p",
 			tsRange: "(0 < ts) AND (ts <= 1542894389181529000)",
-			result:  "SELECT ts FROM logs.logs.inverted_index_logs_2p WHERE (word_hash IN (cityHash64('connection'),cityHash64('manager'),cityHash64('db')) AND column_hash = cityHash64('file')) AND ((0 < ts) AND (ts <= 1542894389181529000)) GROUP BY ts HAVING uniq(word_hash) = 3 ORDER BY ts DESC",
+			result:  "SELECT ts FROM logs.inverted_index_logs_2p WHERE (word_hash IN (cityHash64('connection'),cityHash64('manager'),cityHash64('db')) AND column_hash = cityHash64('file')) AND ((0 < ts) AND (ts <= 1542894389181529000)) GROUP BY ts HAVING uniq(word_hash) = 3 ORDER BY ts DESC",
+		},
+		{
+			text:    "connection refused",
+			column:  "message",
+			table:   GetInvertedIndexTableName("nginx.access"),
+			tsRange: "(0 < ts) AND (ts <= 1542894389181529000)",
+			result:  "SELECT ts FROM nginx.inverted_index_access WHERE (word_hash IN (cityHash64('connection'),cityHash64('refused')) AND column_hash = cityHash64('message')) AND ((0 < ts) AND (ts <= 1542894389181529000)) GROUP BY ts HAVING uniq(word_hash) = 2 ORDER BY ts DESC",
 		},
 	}
 
@@ -92,3 +99,44 @@ func TestPrepareEnvironment(t *testing.T) {
 		}
 	}
 }
+
+func TestAnalyzers(t *testing.T) {
+	testData := []struct {
+		analyzer string
+		text     string
+		result   []string
+	}{
+		{
+			analyzer: DefaultAnalyzer,
+			text:     "/data/pmx/vendor/eco/connection-manager/src/DB.php",
+			result:   []string{"connection", "manager", "db"},
+		},
+		{
+			analyzer: SimpleAnalyzer,
+			text:     "/data/pmx/vendor/eco/connection-manager/src/DB.php",
+			result:   []string{"data", "pmx", "vendor", "eco", "connection", "manager", "src", "db", "php"},
+		},
+		{
+			analyzer: "",
+			text:     "Worker callback_0 ends handling",
+			result:   []string{"worker", "callback_0", "ends", "handling"},
+		},
+	}
+
+	for _, test := range testData {
+		analyzer, err := GetAnalyzer(test.analyzer)
+		if err != nil {
+			t.Error("For", test.analyzer, "unexpected error: ", err)
+			continue
+		}
+		if result := analyzer(test.text); strings.Join(result, ",") != strings.Join(test.result, ",") {
+			t.Error("For", test.analyzer,
+				"\n expected: ", test.result,
+				"\n got: ", result)
+		}
+	}
+
+	if _, err := GetAnalyzer("unknown"); err == nil {
+		t.Error("error expected for unknown analyzer")
+	}
+}
@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"kibouse/data/models"
+	"kibouse/index"
+)
+
+// supported formats of logs timestamps, inverted index always stores timestamps in nanoseconds.
+const (
+	EpochNanos   = "epoch_nanos"
+	EpochMicros  = "epoch_micros"
+	EpochMillis  = "epoch_millis"
+	EpochSeconds = "epoch_seconds"
+	RFC3339      = "rfc3339"
+)
+
+// formats of logs timestamps stored in integer columns of the given units.
+var timeUnitsFormats = map[string]string{
+	models.Nanoseconds:  EpochNanos,
+	models.Microseconds: EpochMicros,
+	models.Milliseconds: EpochMillis,
+	models.Seconds:      EpochSeconds,
+}
+
+// fieldExtractor fetches single model attribute from the logs message.
+type fieldExtractor struct {
+	column string
+	paths  []string
+}
+
+// newFieldExtractor creates extractor for the model field, attribute is searched by src_path tag if set,
+// otherwise by db and json names at the top level of logs message.
+func newFieldExtractor(field *models.FieldProps) fieldExtractor {
+	extractor := fieldExtractor{column: field.CHName}
+	if field.SourcePath != "" {
+		extractor.paths = []string{field.SourcePath}
+		return extractor
+	}
+	extractor.paths = []string{escapePath(field.CHName)}
+	if field.KibanaName != "" && field.KibanaName != field.CHName {
+		extractor.paths = append(extractor.paths, escapePath(field.KibanaName))
+	}
+	return extractor
+}
+
+func (fe fieldExtractor) extract(message gjson.Result) (gjson.Result, bool) {
+	for _, path := range fe.paths {
+		if value := message.Get(path); value.Exists() {
+			return value, true
+		}
+	}
+	return gjson.Result{}, false
+}
+
+// messageParser converts logs messages to inverted index records.
+type messageParser struct {
+	timestamp       fieldExtractor
+	timestampFormat string
+	fields          []fieldExtractor
+	analyzer        index.Analyzer
+}
+
+func newMessageParser(
+	timestamp fieldExtractor,
+	timestampFormat string,
+	fields []*models.FieldProps,
+	analyzer index.Analyzer,
+) (*messageParser, error) {
+	if timestampFormat == "" {
+		timestampFormat = EpochNanos
+	}
+	switch timestampFormat {
+	case EpochNanos, EpochMicros, EpochMillis, EpochSeconds, RFC3339:
+	default:
+		return nil, errors.New("unsupported timestamp format: " + timestampFormat)
+	}
+
+	parser := &messageParser{
+		timestamp:       timestamp,
+		timestampFormat: timestampFormat,
+		fields:          make([]fieldExtractor, 0, len(fields)),
+		analyzer:        analyzer,
+	}
+	for _, field := range fields {
+		parser.fields = append(parser.fields, newFieldExtractor(field))
+	}
+	return parser, nil
+}
+
+// parse prepares inverted index records from logs message.
+func (p *messageParser) parse(source []byte) ([]InvertedIndexRecord, error) {
+	if !json.Valid(source) {
+		return nil, errors.New("message is not a valid json")
+	}
+	message := gjson.ParseBytes(source)
+	if !message.IsObject() {
+		return nil, errors.New("message is not a json object")
+	}
+
+	tsValue, ok := p.timestamp.extract(message)
+	if !ok {
+		return nil, errors.New(p.timestamp.column + " is not set")
+	}
+	timestamp, err := parseTimestamp(tsValue, p.timestampFormat)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse "+p.timestamp.column)
+	}
+
+	var records []InvertedIndexRecord
+
+	for _, field := range p.fields {
+		value, ok := field.extract(message)
+		if !ok {
+			continue
+		}
+
+		uniqWords := make(map[string]struct{})
+		for _, text := range flattenValue(value) {
+			for _, word := range p.analyzer(text) {
+				if _, found := uniqWords[word]; found {
+					continue
+				}
+				uniqWords[word] = struct{}{}
+				records = append(records, InvertedIndexRecord{
+					TS:     timestamp,
+					Word:   word,
+					Column: field.column,
+				})
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// flattenValue returns text representation of all scalar values from json value including nested arrays and objects.
+func flattenValue(value gjson.Result) []string {
+	switch {
+	case value.IsArray() || value.IsObject():
+		texts := make([]string, 0)
+		value.ForEach(func(_, item gjson.Result) bool {
+			texts = append(texts, flattenValue(item)...)
+			return true
+		})
+		return texts
+	case value.Type == gjson.Null:
+		return nil
+	case value.Type == gjson.String:
+		return []string{value.Str}
+	default:
+		return []string{value.Raw}
+	}
+}
+
+// parseTimestamp converts logs timestamp to nanoseconds.
+func parseTimestamp(value gjson.Result, format string) (uint64, error) {
+	var raw string
+	switch value.Type {
+	case gjson.Number:
+		raw = value.Raw
+	case gjson.String:
+		raw = strings.TrimSpace(value.Str)
+	default:
+		return 0, errors.New("timestamp should be a number or a string")
+	}
+
+	if format == RFC3339 {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return 0, err
+		}
+		if t.UnixNano() < 0 {
+			return 0, errors.New("timestamp is out of range")
+		}
+		return uint64(t.UnixNano()), nil
+	}
+
+	var multiplier uint64
+	switch format {
+	case EpochSeconds:
+		multiplier = uint64(time.Second)
+	case EpochMillis:
+		multiplier = uint64(time.Millisecond)
+	case EpochMicros:
+		multiplier = uint64(time.Microsecond)
+	default:
+		multiplier = 1
+	}
+
+	if ts, err := strconv.ParseUint(raw, 10, 64); err == nil {
+		if ts > math.MaxUint64/multiplier {
+			return 0, errors.New("timestamp is out of range")
+		}
+		return ts * multiplier, nil
+	}
+
+	// fractional epoch values, e.g. 1542894389.184
+	ts, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, err
+	}
+	if ts < 0 || ts*float64(multiplier) >= math.MaxUint64 {
+		return 0, errors.New("timestamp is out of range")
+	}
+	return uint64(ts * float64(multiplier)), nil
+}
+
+// escapePath escapes gjson path special characters in attribute name.
+func escapePath(name string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ".", `\.`, "*", `\*`, "?", `\?`)
+	return replacer.Replace(name)
+}
@@ -0,0 +1,100 @@
+package indexer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"kibouse/data/models"
+	"kibouse/index"
+)
+
+type nestedLogs struct {
+	TS      uint64   `db:"ts" json:"@timestamp" type:"UInt64" timestamp:"true"`
+	Message string   `db:"message" json:"message" type:"String" inv_index:"true"`
+	Host    string   `db:"host" json:"host" type:"String" inv_index:"true" src_path:"context.host.name"`
+	Tags    []string `db:"tags" json:"tags" type:"Array(String)" inv_index:"true"`
+	Code    uint64   `db:"code" json:"code" type:"UInt64" inv_index:"true"`
+}
+
+func createTestParser(t *testing.T, format string) *messageParser {
+	model := reflect.TypeOf(nestedLogs{})
+	timestamp, _, err := getModelTimestampExtractor(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := newMessageParser(timestamp, format, models.GetIndexedFields(model), index.GetTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parser
+}
+
+func TestMessageParsing(t *testing.T) {
+	testData := []struct {
+		caseName string
+		format   string
+		message  string
+		result   string
+	}{
+		{
+			caseName: "nanoseconds timestamp, nested path, array and number values",
+			format:   "",
+			message:  `{"ts":1542894389184806000,"message":"Worker ends","context":{"host":{"name":"gate01"}},"tags":["eu","Prod"],"code":502}`,
+			result:   "[{1542894389184806000 worker message} {1542894389184806000 ends message} {1542894389184806000 gate01 host} {1542894389184806000 eu tags} {1542894389184806000 prod tags} {1542894389184806000 502 code}]",
+		},
+		{
+			caseName: "milliseconds timestamp found by json name",
+			format:   EpochMillis,
+			message:  `{"@timestamp":1542894389184,"message":"handling"}`,
+			result:   "[{1542894389184000000 handling message}]",
+		},
+		{
+			caseName: "rfc3339 timestamp, nested object value",
+			format:   RFC3339,
+			message:  `{"ts":"2018-11-22T13:46:29.5Z","message":{"text":"commit","level":3}}`,
+			result:   "[{1542894389500000000 commit message} {1542894389500000000 3 message}]",
+		},
+		{
+			caseName: "fractional seconds timestamp as string, null value",
+			format:   EpochSeconds,
+			message:  `{"ts":"1542894389.5","message":null}`,
+			result:   "[]",
+		},
+	}
+
+	for _, test := range testData {
+		records, err := createTestParser(t, test.format).parse([]byte(test.message))
+		if err != nil {
+			t.Error("For", test.caseName, "unexpected error: ", err)
+			continue
+		}
+		if result := fmt.Sprintf("%v", records); result != test.result {
+			t.Error(
+				"For", test.caseName,
+				"\n expected: ", test.result,
+				"\n got: ", result,
+			)
+		}
+	}
+}
+
+func TestMalformedMessages(t *testing.T) {
+	testData := []struct {
+		caseName string
+		message  string
+	}{
+		{caseName: "invalid json", message: `{"ts":1542894389184806000,"message":`},
+		{caseName: "not an object", message: `["ts", 1542894389184806000]`},
+		{caseName: "timestamp is missing", message: `{"message":"Worker ends"}`},
+		{caseName: "timestamp is not a number", message: `{"ts":"yesterday","message":"Worker ends"}`},
+		{caseName: "negative timestamp", message: `{"ts":-1,"message":"Worker ends"}`},
+	}
+
+	parser := createTestParser(t, "")
+	for _, test := range testData {
+		if _, err := parser.parse([]byte(test.message)); err == nil {
+			t.Error("For", test.caseName, "error expected")
+		}
+	}
+}
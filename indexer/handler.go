@@ -0,0 +1,87 @@
+package indexer
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	log "github.com/sirupsen/logrus"
+)
+
+// InvertedIndexRecord specifies a one-word-record for inverted index.
+type InvertedIndexRecord struct {
+	TS     uint64 `json:"ts"`
+	Word   string `json:"word"`
+	Column string `json:"column"`
+}
+
+type consumerGroupHandler struct {
+	table        *tableIndexer
+	sink         *sink
+	flushTimeout time.Duration
+}
+
+func (consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+func (h consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	tracker := newOffsetTracker(sess)
+
+	for msg := range claim.Messages() {
+		h.table.stats.consumed(msg.Partition, claim.HighWaterMarkOffset()-msg.Offset-1)
+
+		records, err := h.table.parser.parse(msg.Value)
+		if err != nil {
+			h.sendToDeadLetterTopic(msg, err, tracker)
+			continue
+		}
+
+		// produce to inverted index
+		indexMessages := make([]*sarama.ProducerMessage, 0, len(records))
+		for _, record := range records {
+			recordString, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+
+			indexMessages = append(indexMessages, &sarama.ProducerMessage{
+				Topic: h.table.indexTopic,
+				Key:   sarama.StringEncoder(strconv.FormatUint(record.TS, 10)),
+				Value: sarama.ByteEncoder(recordString),
+			})
+		}
+
+		// message is marked as consumed only after all its records are delivered
+		pending := tracker.add(msg, len(indexMessages))
+		for _, indexMessage := range indexMessages {
+			h.sink.send(indexMessage, pending)
+		}
+		h.table.stats.produced(len(indexMessages))
+	}
+
+	// claim is finished due to rebalancing or shutdown, offsets should be marked before session ends
+	return tracker.wait(h.flushTimeout)
+}
+
+// sendToDeadLetterTopic passes malformed logs message to the dead letter topic with failure reason in headers,
+// message is only logged if dead letter topic is not set.
+func (h consumerGroupHandler) sendToDeadLetterTopic(msg *sarama.ConsumerMessage, reason error, tracker *offsetTracker) {
+	h.table.stats.failed()
+	if h.table.deadLetterTopic == "" {
+		log.Errorf("table %s, partition %d, offset %d: %s", h.table.name, msg.Partition, msg.Offset, reason.Error())
+		tracker.add(msg, 0)
+		return
+	}
+
+	h.sink.send(&sarama.ProducerMessage{
+		Topic: h.table.deadLetterTopic,
+		Key:   sarama.ByteEncoder(msg.Key),
+		Value: sarama.ByteEncoder(msg.Value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("error"), Value: []byte(reason.Error())},
+			{Key: []byte("topic"), Value: []byte(msg.Topic)},
+			{Key: []byte("partition"), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+			{Key: []byte("offset"), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		},
+	}, tracker.add(msg, 1))
+}
@@ -0,0 +1,304 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"kibouse/config"
+	"kibouse/data/models"
+	"kibouse/index"
+)
+
+const consumerGroupPrefix = "indexer-"
+
+// Indexer splits logs delivered via kafka by words and passes them further to the inverted index topics.
+// Every indexed table is processed by its own consumer group, all of them share the same producer.
+type Indexer struct {
+	cfg    *config.AppConfig
+	tables []*tableIndexer
+	sink   *sink
+	stats  *statsRegistry
+}
+
+// tableIndexer contains resources required for indexing single logs table.
+type tableIndexer struct {
+	name            string
+	logsTopic       string
+	indexTopic      string
+	deadLetterTopic string
+	parser          *messageParser
+	client          sarama.Client
+	consumer        sarama.ConsumerGroup
+	stats           *tableStats
+}
+
+// New creates indexer for the list of logs tables.
+func New(cfg *config.AppConfig, tables []config.IndexedTable) (*Indexer, error) {
+	if len(tables) == 0 {
+		return nil, errors.New("list of indexed tables is empty")
+	}
+
+	ix := &Indexer{
+		cfg:    cfg,
+		tables: make([]*tableIndexer, 0, len(tables)),
+		stats:  newStatsRegistry(),
+	}
+
+	for _, settings := range tables {
+		table, err := newTableIndexer(settings)
+		if err != nil {
+			ix.Close()
+			return nil, err
+		}
+		table.stats = ix.stats.add(table.name)
+		ix.tables = append(ix.tables, table)
+	}
+
+	if err := ix.connect(); err != nil {
+		ix.Close()
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// Run starts consuming logs from all configured topics and blocks until any consumer group fails
+// or termination signal is received. Records produced before shutdown are flushed before exit.
+func (ix *Indexer) Run() error {
+	defer ix.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ix.stats.serve(ctx, ix.cfg.IndexerStatsInterval(), ix.cfg.IndexerStatsPort())
+	ix.sink.start()
+
+	failures := make(chan error, 1)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Infof("%s received, shutting down indexer", sig)
+		case err := <-ix.sink.failures:
+			failures <- err
+		case <-ctx.Done():
+			return
+		}
+		cancel()
+	}()
+
+	err := consumeAll(ctx, ix.tables, func(ctx context.Context, table *tableIndexer) error {
+		return table.consume(ctx, ix.sink, ix.cfg)
+	})
+	cancel()
+
+	// consumers are stopped, so all records left in producer buffers could be flushed
+	for _, table := range ix.tables {
+		table.close()
+	}
+	ix.sink.close()
+
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-failures:
+		return err
+	default:
+		return nil
+	}
+}
+
+// consumeAll runs consuming of all tables until context is cancelled, consumers of all tables are stopped
+// as soon as consuming of any table fails. The first error is returned after all consumers exit.
+func consumeAll(ctx context.Context, tables []*tableIndexer, consume func(context.Context, *tableIndexer) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(tables))
+	wg := sync.WaitGroup{}
+	for _, table := range tables {
+		wg.Add(1)
+		go func(table *tableIndexer) {
+			defer wg.Done()
+			if err := consume(ctx, table); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(table)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Close releases kafka connections of all tables and flushes shared producer.
+func (ix *Indexer) Close() {
+	if ix == nil {
+		return
+	}
+	for _, table := range ix.tables {
+		table.close()
+	}
+	if ix.sink != nil {
+		ix.sink.close()
+	}
+}
+
+func (ix *Indexer) connect() error {
+	brokers := []string{ix.cfg.GetKafkaSource()}
+
+	producerSettings := newKafkaSettings()
+	producerSettings.Producer.Return.Successes = true
+	producer, err := sarama.NewAsyncProducer(brokers, producerSettings)
+	if err != nil {
+		return errors.Wrap(err, "cannot create inverted index producer")
+	}
+	ix.sink = newSink(producer, ix.cfg.IndexerMaxRetries(), ix.cfg.IndexerRetryBackoff())
+
+	for _, table := range ix.tables {
+		if table.client, err = sarama.NewClient(brokers, newKafkaSettings()); err != nil {
+			return errors.Wrap(err, "cannot create kafka client for table "+table.name)
+		}
+		if table.consumer, err = sarama.NewConsumerGroupFromClient(consumerGroupPrefix+table.logsTopic, table.client); err != nil {
+			return errors.Wrap(err, "cannot create consumer group for table "+table.name)
+		}
+	}
+
+	return nil
+}
+
+func newKafkaSettings() *sarama.Config {
+	settings := sarama.NewConfig()
+	settings.Version = sarama.V1_0_0_0
+	settings.Consumer.Return.Errors = true
+	settings.Producer.Return.Errors = true
+	return settings
+}
+
+func newTableIndexer(settings config.IndexedTable) (*tableIndexer, error) {
+	model, ok := models.GetLogsTablesSchemas()[settings.Table]
+	if !ok {
+		return nil, errors.New("indexed table not exists: " + settings.Table)
+	}
+
+	searchableFields := models.GetIndexedFields(model)
+	if len(searchableFields) == 0 {
+		return nil, errors.New("indexed table doesn't contain searchable fields: " + settings.Table)
+	}
+
+	analyzer, err := index.GetAnalyzer(settings.Analyzer)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create indexer for table "+settings.Table)
+	}
+
+	// timestamp field from config is a path to the timestamp in logs message
+	timestamp := fieldExtractor{column: settings.TimestampField, paths: []string{settings.TimestampField}}
+	timestampFormat := settings.TimestampFormat
+	if settings.TimestampField == "" {
+		var modelFormat string
+		if timestamp, modelFormat, err = getModelTimestampExtractor(model); err != nil {
+			return nil, errors.Wrap(err, "cannot create indexer for table "+settings.Table)
+		}
+		if timestampFormat == "" {
+			timestampFormat = modelFormat
+		}
+	}
+
+	parser, err := newMessageParser(timestamp, timestampFormat, searchableFields, analyzer)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create indexer for table "+settings.Table)
+	}
+
+	table := &tableIndexer{
+		name:            settings.Table,
+		logsTopic:       settings.LogsTopic,
+		indexTopic:      settings.IndexTopic,
+		deadLetterTopic: settings.DeadLetterTopic,
+		parser:          parser,
+	}
+	if table.logsTopic == "" {
+		table.logsTopic = table.name
+	}
+	if table.indexTopic == "" {
+		table.indexTopic = models.InvertedIndexTablePrefix + table.logsTopic
+	}
+
+	return table, nil
+}
+
+// getModelTimestampExtractor returns extractor of the model timestamp and its format in logs messages
+// according to the timestamp unit (empty for date columns).
+func getModelTimestampExtractor(model reflect.Type) (fieldExtractor, string, error) {
+	fields, err := models.CreateDBFieldsInfoMap(model)
+	if err != nil {
+		return fieldExtractor{}, "", err
+	}
+	if timestamp, ok := (models.ModelInfo{DataFields: fields}).GetTimestampField(); ok {
+		return newFieldExtractor(timestamp), timeUnitsFormats[timestamp.TimeUnit], nil
+	}
+	return fieldExtractor{}, "", errors.New("model timestamp field is not set")
+}
+
+// consume processes logs until context is cancelled, temporary consumer group failures are retried with backoff.
+func (t *tableIndexer) consume(ctx context.Context, sink *sink, cfg *config.AppConfig) error {
+	// track errors
+	go func() {
+		for err := range t.consumer.Errors() {
+			log.Errorf("table %s consumer error: %s", t.name, err.Error())
+		}
+	}()
+
+	handler := consumerGroupHandler{table: t, sink: sink, flushTimeout: cfg.IndexerShutdownTimeout()}
+	attempt := 0
+	for {
+		err := t.consumer.Consume(ctx, []string{t.logsTopic}, handler)
+		if ctx.Err() != nil || err == sarama.ErrClosedConsumerGroup {
+			return nil
+		}
+		if err == nil {
+			attempt = 0
+			continue
+		}
+		if attempt >= cfg.IndexerMaxRetries() {
+			return errors.Wrap(err, "logs consuming failed for table "+t.name)
+		}
+
+		delay := backoffDelay(cfg.IndexerRetryBackoff(), attempt)
+		log.Warnf("table %s consuming failed, retry in %s: %s", t.name, delay, err.Error())
+		attempt++
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (t *tableIndexer) close() {
+	if t.consumer != nil {
+		_ = t.consumer.Close()
+		t.consumer = nil
+	}
+	if t.client != nil {
+		_ = t.client.Close()
+		t.client = nil
+	}
+}
@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestConsumeAllStopsOnFailure(t *testing.T) {
+	tables := []*tableIndexer{{name: "logs_healthy"}, {name: "logs_failed"}}
+	failure := errors.New("consumer group failed")
+	stopped := make(chan string, len(tables))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- consumeAll(context.Background(), tables, func(ctx context.Context, table *tableIndexer) error {
+			if table.name == "logs_failed" {
+				return failure
+			}
+			// healthy consumer works until it is stopped
+			<-ctx.Done()
+			stopped <- table.name
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != failure {
+			t.Error("For", "failed table", "\n expected: ", failure, "\n got: ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("For failed table\n expected: all consumers stopped\n got: indexer is still running")
+	}
+	if len(stopped) != 1 {
+		t.Error("For", "healthy table", "\n expected: ", "stopped", "\n got: ", len(stopped))
+	}
+}
+
+func TestConsumeAllCancelled(t *testing.T) {
+	tables := []*tableIndexer{{name: "logs_a"}, {name: "logs_b"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := consumeAll(ctx, tables, func(ctx context.Context, table *tableIndexer) error {
+		<-ctx.Done()
+		return nil
+	})
+	if err != nil {
+		t.Error("For", "cancelled context", "\n expected: ", nil, "\n got: ", err)
+	}
+}
@@ -0,0 +1,237 @@
+package indexer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxBackoff = time.Minute
+
+// backoffDelay returns exponentially growing delay before the next retry.
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// delivery is attached to every produced message as metadata, it is used for acknowledging consumed message.
+type delivery struct {
+	pending *pendingMessage
+	attempt int
+}
+
+// sink delivers inverted index records to kafka, failed records are resent with bounded number of retries.
+type sink struct {
+	producer   sarama.AsyncProducer
+	maxRetries int
+	backoff    time.Duration
+	closing    chan struct{}
+	failures   chan error
+	retries    sync.WaitGroup
+	drainers   sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+func newSink(producer sarama.AsyncProducer, maxRetries int, backoff time.Duration) *sink {
+	return &sink{
+		producer:   producer,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		closing:    make(chan struct{}),
+		failures:   make(chan error, 1),
+	}
+}
+
+// start begins draining producer results.
+func (s *sink) start() {
+	s.drainers.Add(2)
+	go func() {
+		defer s.drainers.Done()
+		for msg := range s.producer.Successes() {
+			if d, ok := msg.Metadata.(*delivery); ok {
+				d.pending.ack()
+			}
+		}
+	}()
+	go func() {
+		defer s.drainers.Done()
+		for producerErr := range s.producer.Errors() {
+			s.retry(producerErr)
+		}
+	}()
+}
+
+// send passes message to the producer, consumed message is acknowledged after successful delivery.
+func (s *sink) send(msg *sarama.ProducerMessage, pending *pendingMessage) {
+	msg.Metadata = &delivery{pending: pending}
+	s.producer.Input() <- msg
+}
+
+func (s *sink) retry(producerErr *sarama.ProducerError) {
+	d, ok := producerErr.Msg.Metadata.(*delivery)
+	if !ok {
+		log.Errorf("cannot deliver message to %s: %s", producerErr.Msg.Topic, producerErr.Err.Error())
+		return
+	}
+
+	select {
+	case <-s.closing:
+		d.pending.fail(producerErr.Err)
+		return
+	default:
+	}
+
+	if d.attempt >= s.maxRetries {
+		err := errors.Wrapf(producerErr.Err, "cannot deliver message to %s after %d retries", producerErr.Msg.Topic, d.attempt)
+		d.pending.fail(err)
+		select {
+		case s.failures <- err:
+		default:
+		}
+		return
+	}
+
+	resent := &sarama.ProducerMessage{
+		Topic:    producerErr.Msg.Topic,
+		Key:      producerErr.Msg.Key,
+		Value:    producerErr.Msg.Value,
+		Headers:  producerErr.Msg.Headers,
+		Metadata: &delivery{pending: d.pending, attempt: d.attempt + 1},
+	}
+	delay := backoffDelay(s.backoff, d.attempt)
+	log.Warnf("delivery to %s failed, retry in %s: %s", resent.Topic, delay, producerErr.Err.Error())
+
+	s.retries.Add(1)
+	go func() {
+		defer s.retries.Done()
+		select {
+		case <-s.closing:
+			d.pending.fail(producerErr.Err)
+		case <-time.After(delay):
+			s.producer.Input() <- resent
+		}
+	}()
+}
+
+// close stops retries and flushes all buffered messages.
+func (s *sink) close() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		s.retries.Wait()
+		s.producer.AsyncClose()
+		s.drainers.Wait()
+	})
+}
+
+// pendingMessage is a consumed logs message waiting for delivery of all its inverted index records.
+type pendingMessage struct {
+	msg       *sarama.ConsumerMessage
+	remaining int
+	tracker   *offsetTracker
+}
+
+func (p *pendingMessage) ack() {
+	p.tracker.ack(p)
+}
+
+func (p *pendingMessage) fail(err error) {
+	p.tracker.fail(err)
+}
+
+// offsetTracker marks consumed messages of single partition claim strictly in order
+// and only after all records produced from them have been delivered.
+type offsetTracker struct {
+	sync.Mutex
+	sess    sarama.ConsumerGroupSession
+	queue   []*pendingMessage
+	err     error
+	drained chan struct{}
+}
+
+func newOffsetTracker(sess sarama.ConsumerGroupSession) *offsetTracker {
+	return &offsetTracker{
+		sess:    sess,
+		queue:   make([]*pendingMessage, 0),
+		drained: make(chan struct{}, 1),
+	}
+}
+
+// add registers consumed message, which produces the required number of records.
+func (t *offsetTracker) add(msg *sarama.ConsumerMessage, records int) *pendingMessage {
+	pending := &pendingMessage{msg: msg, remaining: records, tracker: t}
+
+	t.Lock()
+	t.queue = append(t.queue, pending)
+	t.markDelivered()
+	t.Unlock()
+
+	return pending
+}
+
+func (t *offsetTracker) ack(pending *pendingMessage) {
+	t.Lock()
+	pending.remaining--
+	t.markDelivered()
+	t.Unlock()
+}
+
+func (t *offsetTracker) fail(err error) {
+	t.Lock()
+	if t.err == nil {
+		t.err = err
+	}
+	t.Unlock()
+	t.notify()
+}
+
+// markDelivered marks all delivered messages from the head of queue, must be called under lock.
+func (t *offsetTracker) markDelivered() {
+	if t.err != nil {
+		return
+	}
+	for len(t.queue) > 0 && t.queue[0].remaining <= 0 {
+		t.sess.MarkMessage(t.queue[0].msg, "")
+		t.queue = t.queue[1:]
+	}
+	if len(t.queue) == 0 {
+		t.notify()
+	}
+}
+
+func (t *offsetTracker) notify() {
+	select {
+	case t.drained <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until all registered messages are delivered, delivery failed or timeout expired.
+func (t *offsetTracker) wait(timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		t.Lock()
+		pending, err := len(t.queue), t.err
+		t.Unlock()
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-t.drained:
+		case <-deadline:
+			return errors.Errorf("%d consumed messages are not delivered in %s", pending, timeout)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// testSession records marked offsets.
+type testSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func TestOffsetTracker(t *testing.T) {
+	sess := &testSession{}
+	tracker := newOffsetTracker(sess)
+
+	first := tracker.add(&sarama.ConsumerMessage{Offset: 1}, 2)
+	second := tracker.add(&sarama.ConsumerMessage{Offset: 2}, 1)
+	tracker.add(&sarama.ConsumerMessage{Offset: 3}, 0)
+
+	// later message is delivered first, but offsets must be marked in order
+	second.ack()
+	first.ack()
+	if result := fmt.Sprintf("%v", sess.marked); result != "[]" {
+		t.Error("For partially delivered head message", "\n expected: ", "[]", "\n got: ", result)
+	}
+
+	first.ack()
+	if result := fmt.Sprintf("%v", sess.marked); result != "[1 2 3]" {
+		t.Error("For delivered messages", "\n expected: ", "[1 2 3]", "\n got: ", result)
+	}
+	if err := tracker.wait(time.Second); err != nil {
+		t.Error("For delivered messages unexpected error: ", err)
+	}
+
+	failed := tracker.add(&sarama.ConsumerMessage{Offset: 4}, 1)
+	failed.fail(errors.New("delivery failed"))
+	if err := tracker.wait(time.Second); err == nil {
+		t.Error("For failed delivery error expected")
+	}
+
+	tracker = newOffsetTracker(sess)
+	tracker.add(&sarama.ConsumerMessage{Offset: 5}, 1)
+	if err := tracker.wait(10 * time.Millisecond); err == nil {
+		t.Error("For undelivered message timeout error expected")
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	testData := []struct {
+		attempt int
+		result  time.Duration
+	}{
+		{attempt: 0, result: time.Second},
+		{attempt: 1, result: 2 * time.Second},
+		{attempt: 3, result: 8 * time.Second},
+		{attempt: 100, result: maxBackoff},
+	}
+
+	for _, test := range testData {
+		if result := backoffDelay(time.Second, test.attempt); result != test.result {
+			t.Error("For attempt", test.attempt, "\n expected: ", test.result, "\n got: ", result)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package indexer
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const statsVarName = "indexer"
+
+// TableStats contains indexing statistics of single logs table.
+type TableStats struct {
+	Table            string          `json:"table"`
+	ConsumedMessages int64           `json:"consumed_messages"`
+	ProducedRecords  int64           `json:"produced_records"`
+	FailedMessages   int64           `json:"failed_messages"`
+	MessagesPerSec   float64         `json:"messages_per_sec"`
+	Lag              int64           `json:"lag"`
+	PartitionsLag    map[int32]int64 `json:"partitions_lag"`
+}
+
+type tableStats struct {
+	sync.Mutex
+	name          string
+	messages      int64
+	records       int64
+	failures      int64
+	lastMessages  int64
+	throughput    float64
+	partitionsLag map[int32]int64
+}
+
+func (ts *tableStats) consumed(partition int32, lag int64) {
+	ts.Lock()
+	ts.messages++
+	if lag < 0 {
+		lag = 0
+	}
+	ts.partitionsLag[partition] = lag
+	ts.Unlock()
+}
+
+func (ts *tableStats) produced(records int) {
+	ts.Lock()
+	ts.records += int64(records)
+	ts.Unlock()
+}
+
+func (ts *tableStats) failed() {
+	ts.Lock()
+	ts.failures++
+	ts.Unlock()
+}
+
+// updateThroughput recalculates table messages consuming rate for the last period.
+func (ts *tableStats) updateThroughput(period time.Duration) {
+	ts.Lock()
+	if period > 0 {
+		ts.throughput = float64(ts.messages-ts.lastMessages) / period.Seconds()
+	}
+	ts.lastMessages = ts.messages
+	ts.Unlock()
+}
+
+func (ts *tableStats) snapshot() TableStats {
+	ts.Lock()
+	defer ts.Unlock()
+
+	stats := TableStats{
+		Table:            ts.name,
+		ConsumedMessages: ts.messages,
+		ProducedRecords:  ts.records,
+		FailedMessages:   ts.failures,
+		MessagesPerSec:   ts.throughput,
+		PartitionsLag:    make(map[int32]int64, len(ts.partitionsLag)),
+	}
+	for partition, lag := range ts.partitionsLag {
+		stats.PartitionsLag[partition] = lag
+		stats.Lag += lag
+	}
+	return stats
+}
+
+// statsRegistry collects statistics of all indexed tables.
+type statsRegistry struct {
+	sync.RWMutex
+	tables map[string]*tableStats
+}
+
+func newStatsRegistry() *statsRegistry {
+	return &statsRegistry{tables: make(map[string]*tableStats)}
+}
+
+func (sr *statsRegistry) add(table string) *tableStats {
+	sr.Lock()
+	defer sr.Unlock()
+
+	stats := &tableStats{name: table, partitionsLag: make(map[int32]int64)}
+	sr.tables[table] = stats
+	return stats
+}
+
+// snapshot returns current statistics of all indexed tables sorted by table name.
+func (sr *statsRegistry) snapshot() []TableStats {
+	sr.RLock()
+	defer sr.RUnlock()
+
+	result := make([]TableStats, 0, len(sr.tables))
+	for _, stats := range sr.tables {
+		result = append(result, stats.snapshot())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
+
+func (sr *statsRegistry) updateThroughput(period time.Duration) {
+	sr.RLock()
+	defer sr.RUnlock()
+
+	for _, stats := range sr.tables {
+		stats.updateThroughput(period)
+	}
+}
+
+// serve publishes statistics as expvar variable (available via http on /debug/vars if port is set)
+// and periodically writes it to log.
+func (sr *statsRegistry) serve(ctx context.Context, period time.Duration, port string) {
+	expvar.Publish(statsVarName, expvar.Func(func() interface{} {
+		return sr.snapshot()
+	}))
+
+	if port != "" {
+		go func() {
+			if err := http.ListenAndServe(":"+port, nil); err != nil {
+				log.Errorf("indexer statistics server failed: %s", err.Error())
+			}
+		}()
+	}
+
+	if period <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sr.updateThroughput(period)
+				for _, stats := range sr.snapshot() {
+					log.Infof(
+						"table %s: consumed %d messages (%.2f/sec), produced %d records, failed %d messages, lag %d",
+						stats.Table,
+						stats.ConsumedMessages,
+						stats.MessagesPerSec,
+						stats.ProducedRecords,
+						stats.FailedMessages,
+						stats.Lag,
+					)
+				}
+			}
+		}
+	}()
+}
@@ -16,13 +16,34 @@ func InitialSetup(cfg *config.AppConfig) error {
 		return err
 	}
 
-	return initKibanaSettings(cfg.KibanaVersion())
+	return initKibanaSettings(models.SettingsTableName, cfg.KibanaVersion())
+}
+
+// InitOpenSearchSettings creates table with OpenSearch Dashboards saved objects if it doesn't exist yet,
+// it is called at every startup, so OpenSearch Dashboards could be enabled without reset of kibana configuration.
+func InitOpenSearchSettings(cfg *config.AppConfig) error {
+	exists, err := db.TableExists(models.OpenSearchSettingsTableName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if err := createSettingsTable(models.OpenSearchSettingsTableName); err != nil {
+		return err
+	}
+	return initKibanaSettings(models.OpenSearchSettingsTableName, cfg.OpenSearchVersion())
 }
 
 // CreateKibanaSettingsTable creates clickhouse table with kibana settings.
 func CreateKibanaSettingsTable() error {
+	return createSettingsTable(models.SettingsTableName)
+}
+
+// createSettingsTable creates clickhouse table with saved objects of kibana or OpenSearch Dashboards.
+func createSettingsTable(table string) error {
 	scheme := clickhouse.CreateCollapsingMergeTreeTableScheme(
-		models.SettingsTableName,
+		table,
 		reflect.TypeOf(models.ClickhouseSettings{}),
 		"sign",
 		clickhouse.DefaultIndexGranularity,
@@ -30,10 +51,10 @@ func CreateKibanaSettingsTable() error {
 	return db.CreateTable(scheme)
 }
 
-func initKibanaSettings(version string) error {
+func initKibanaSettings(table string, version string) error {
 	clickhouseCfg := models.NewClickhouseSettings(version, "config")
 
-	if err := db.InsertIntoTable(models.SettingsTableName, *clickhouseCfg); err != nil {
+	if err := db.InsertIntoTable(table, *clickhouseCfg); err != nil {
 		return err
 	}
 
@@ -45,15 +66,19 @@ func initDBStruct(cfg *config.AppConfig) error {
 		return err
 	}
 
-	if cfg.ReinitRequired() {
-		if _, err := db.Execute("DROP TABLE IF EXISTS " + db.DataBaseName + "." + models.SettingsTableName); err != nil {
-			return err
-		}
+	tables := []string{models.SettingsTableName}
+	if cfg.OpenSearchEnabled() {
+		// OpenSearch Dashboards table is recreated by InitOpenSearchSettings
+		tables = append(tables, models.OpenSearchSettingsTableName)
 	}
 
-	if err := CreateKibanaSettingsTable(); err != nil {
-		return err
+	if cfg.ReinitRequired() {
+		for _, table := range tables {
+			if _, err := db.Execute("DROP TABLE IF EXISTS " + db.DataBaseName + "." + table); err != nil {
+				return err
+			}
+		}
 	}
 
-	return nil
+	return createSettingsTable(models.SettingsTableName)
 }